@@ -0,0 +1,113 @@
+package illuminated
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// alertTypes are the GitHub alert types, from blockquotes starting with a [!TYPE] line.
+var alertTypes = []string{"note", "tip", "important", "warning", "caution"}
+
+// KindAlert is the node kind of GitHub alerts.
+var KindAlert = ast.NewNodeKind("Alert")
+
+// alert is a blockquote rendered as a GitHub alert, such as > [!NOTE].
+type alert struct {
+	ast.BaseBlock
+	alertType string
+}
+
+// Kind implements ast.Node.
+func (n *alert) Kind() ast.NodeKind {
+	return KindAlert
+}
+
+// Dump implements ast.Node.
+func (n *alert) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Type": n.alertType}, nil)
+}
+
+// alertTransformer replaces blockquotes starting with an alert marker with alerts.
+type alertTransformer struct{}
+
+// Transform implements parser.ASTTransformer.
+func (t *alertTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var quotes []*ast.Blockquote
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if q, ok := n.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, q)
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, q := range quotes {
+		para, ok := q.FirstChild().(*ast.Paragraph)
+		if !ok || para.Lines().Len() == 0 {
+			continue
+		}
+		marker := para.Lines().At(0)
+		alertType := alertMarker(marker.Value(source))
+		if alertType == "" {
+			continue
+		}
+		// remove the marker line, and the paragraph if nothing follows it
+		for c := para.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			if t, ok := c.(*ast.Text); ok && t.Segment.Stop <= marker.Stop {
+				para.RemoveChild(para, c)
+			}
+			c = next
+		}
+		if !para.HasChildren() {
+			q.RemoveChild(q, para)
+		}
+
+		a := &alert{alertType: alertType}
+		for c := q.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			a.AppendChild(a, c)
+			c = next
+		}
+		q.Parent().ReplaceChild(q.Parent(), q, a)
+	}
+}
+
+// alertMarker returns the alert type of a marker line, [!TYPE], or an empty string.
+func alertMarker(line []byte) string {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("[!")) || !bytes.HasSuffix(line, []byte("]")) {
+		return ""
+	}
+	alertType := strings.ToLower(string(line[2 : len(line)-1]))
+	for _, t := range alertTypes {
+		if t == alertType {
+			return t
+		}
+	}
+	return ""
+}
+
+// alertRenderer renders alerts with the same markup as GitHub.
+type alertRenderer struct{}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (r *alertRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindAlert, r.render)
+}
+
+func (r *alertRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	a := n.(*alert)
+	if entering {
+		_, _ = w.WriteString(`<div class="markdown-alert markdown-alert-` + a.alertType + `">` + "\n")
+		_, _ = w.WriteString(`<p class="markdown-alert-title">` + strings.ToUpper(a.alertType[:1]) + a.alertType[1:] + "</p>\n")
+	} else {
+		_, _ = w.WriteString("</div>\n")
+	}
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,156 @@
+package illuminated
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// headingLevels maps heading elements to their level.
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// headings returns the heading elements of doc, in document order.
+func headings(doc *html.Node) []*html.Node {
+	var found []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if _, ok := headingLevels[n.DataAtom]; ok {
+				found = append(found, n)
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return found
+}
+
+// attr returns the value of attribute key of n, or an empty string.
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// setAttr sets attribute key of n to val.
+func setAttr(n *html.Node, key, val string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+// textContent returns the text of n and its descendants.
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+// KeepHeadingIDs sets the heading IDs of the translated HTML document to those of the base document,
+// so links to sections are the same in every language.
+// Headings are matched in document order, as translation doesn't add or remove headings.
+func KeepHeadingIDs(base, translated string) (string, error) {
+	baseDoc, err := html.Parse(strings.NewReader(base))
+	if err != nil {
+		return "", fmt.Errorf("parse base HTML: %w", err)
+	}
+	doc, err := html.Parse(strings.NewReader(translated))
+	if err != nil {
+		return "", fmt.Errorf("parse translated HTML: %w", err)
+	}
+	baseHeadings, txHeadings := headings(baseDoc), headings(doc)
+	if len(baseHeadings) != len(txHeadings) {
+		slog.Warn("translation has a different number of headings, anchors may differ from the base language",
+			"base", len(baseHeadings),
+			"translated", len(txHeadings),
+		)
+	}
+	for i := 0; i < len(baseHeadings) && i < len(txHeadings); i++ {
+		if id := attr(baseHeadings[i], "id"); id != "" {
+			setAttr(txHeadings[i], "id", id)
+		}
+	}
+	var b strings.Builder
+	err = html.Render(&b, doc)
+	if err != nil {
+		return "", fmt.Errorf("render HTML: %w", err)
+	}
+	return b.String(), nil
+}
+
+// HeadingTOC returns a navigation block linking to the headings of doc
+// with IDs, down to heading level depth, as nested lists.
+// Nil is returned if doc has no such headings.
+func HeadingTOC(doc *html.Node, depth int) *html.Node {
+	type list struct {
+		level int
+		node  *html.Node
+	}
+	root := &html.Node{Type: html.ElementNode, Data: "ul", DataAtom: atom.Ul}
+	stack := []list{{node: root}}
+	for _, h := range headings(doc) {
+		level, id := headingLevels[h.DataAtom], attr(h, "id")
+		if level > depth || id == "" {
+			continue
+		}
+		top := &stack[len(stack)-1]
+		if top.level == 0 {
+			top.level = level
+		}
+		for len(stack) > 1 && level < stack[len(stack)-1].level {
+			stack = stack[:len(stack)-1]
+		}
+		top = &stack[len(stack)-1]
+		if level > top.level && top.node.LastChild != nil {
+			nested := &html.Node{Type: html.ElementNode, Data: "ul", DataAtom: atom.Ul}
+			top.node.LastChild.AppendChild(nested)
+			stack = append(stack, list{level: level, node: nested})
+			top = &stack[len(stack)-1]
+		}
+
+		a := &html.Node{
+			Type:     html.ElementNode,
+			Data:     "a",
+			DataAtom: atom.A,
+			Attr:     []html.Attribute{{Key: "href", Val: "#" + id}},
+		}
+		a.AppendChild(&html.Node{Type: html.TextNode, Data: strings.TrimSpace(textContent(h))})
+		li := &html.Node{Type: html.ElementNode, Data: "li", DataAtom: atom.Li}
+		li.AppendChild(a)
+		top.node.AppendChild(li)
+	}
+	if root.FirstChild == nil {
+		return nil
+	}
+	nav := &html.Node{
+		Type:     html.ElementNode,
+		Data:     "nav",
+		DataAtom: atom.Nav,
+		Attr: []html.Attribute{
+			{Key: "class", Val: "toc"},
+			{Key: "role", Val: "doc-toc"},
+		},
+	}
+	nav.AppendChild(root)
+	return nav
+}
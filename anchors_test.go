@@ -0,0 +1,45 @@
+package illuminated
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestKeepHeadingIDs(t *testing.T) {
+	base := `<h1 id="install">Install</h1><p>Text</p><h2 id="windows">Windows</h2>`
+	translated := `<h1 id="安装">安装</h1><p>文本</p><h2>视窗</h2>`
+	doc, err := KeepHeadingIDs(base, translated)
+	require.NoError(t, err)
+	require.Contains(t, doc, `<h1 id="install">安装</h1>`)
+	require.Contains(t, doc, `<h2 id="windows">视窗</h2>`)
+}
+
+func TestHeadingTOC(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`
+<h1 id="install">Install</h1>
+<h2 id="windows">Windows</h2>
+<h3 id="silent">Silent <code>install</code></h3>
+<h4 id="flags">Flags</h4>
+<h2 id="macos">macOS</h2>
+<h2>No ID</h2>
+<h1 id="usage">Usage</h1>`))
+	require.NoError(t, err)
+
+	toc := HeadingTOC(doc, 3)
+	require.NotNil(t, toc)
+	var b strings.Builder
+	require.NoError(t, html.Render(&b, toc))
+	require.Equal(t, `<nav class="toc" role="doc-toc"><ul>`+
+		`<li><a href="#install">Install</a><ul>`+
+		`<li><a href="#windows">Windows</a><ul><li><a href="#silent">Silent install</a></li></ul></li>`+
+		`<li><a href="#macos">macOS</a></li></ul></li>`+
+		`<li><a href="#usage">Usage</a></li>`+
+		`</ul></nav>`, b.String())
+
+	empty, err := html.Parse(strings.NewReader(`<p>No headings</p>`))
+	require.NoError(t, err)
+	require.Nil(t, HeadingTOC(empty, 3))
+}
@@ -0,0 +1,201 @@
+package illuminated
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// StdinSource is the source name which stages a tar stream from stdin.
+const StdinSource = "-"
+
+// MaxArchiveEntrySize limits the size of any single file extracted from an archive source.
+var MaxArchiveEntrySize int64 = 64 << 20 // 64 MiB
+
+var (
+	ErrArchiveEntryUnsafe   = errors.New("archive entry path escapes destination")
+	ErrArchiveEntryTooLarge = errors.New("archive entry exceeds maximum size")
+)
+
+// archiveExtension returns the archive extension of source, if any.
+func archiveExtension(source string) string {
+	lower := strings.ToLower(source)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// extractArchive extracts an archive of the given extension into dst, replacing its content,
+// and returns the directory holding the archive content.
+func extractArchive(r io.Reader, ext string, dst string) (string, error) {
+	err := os.RemoveAll(dst)
+	if err != nil {
+		return "", fmt.Errorf("remove previous extraction: %w", err)
+	}
+	err = os.MkdirAll(dst, 0o750)
+	if err != nil {
+		return "", fmt.Errorf("create extraction directory: %w", err)
+	}
+
+	switch ext {
+	case ".zip":
+		err = extractZip(r, dst)
+	default:
+		err = extractTar(r, dst)
+	}
+	if err != nil {
+		return "", fmt.Errorf("extract archive: %w", err)
+	}
+	return archiveRoot(dst)
+}
+
+// archiveRoot returns the directory holding the archive content.
+// Archives commonly wrap their content in a single top-level directory,
+// which is descended into.
+func archiveRoot(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("read extracted archive: %w", err)
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return archiveRoot(path.Join(dir, entries[0].Name()))
+	}
+	return dir, nil
+}
+
+// extractTar extracts a tar stream, which may be gzip compressed, into dst.
+func extractTar(r io.Reader, dst string) error {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("open gzip: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+			err = extractFile(tr, header.Name, header.Size, dst)
+			if err != nil {
+				return err
+			}
+		default:
+			slog.Debug("ignoring archive entry which is not a regular file", "name", header.Name)
+		}
+	}
+}
+
+// extractZip extracts a zip archive into dst.
+// Zip archives are read from the end, so r is buffered in full.
+func extractZip(r io.Reader, dst string) error {
+	var ra io.ReaderAt
+	var size int64
+	if f, ok := r.(*os.File); ok {
+		info, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("stat zip: %w", err)
+		}
+		ra, size = f, info.Size()
+	} else {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("read zip: %w", err)
+		}
+		ra, size = bytes.NewReader(b), int64(len(b))
+	}
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return fmt.Errorf("open zip: %w", err)
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			slog.Debug("ignoring archive entry which is not a regular file", "name", f.Name)
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("open zip entry %q: %w", f.Name, err)
+		}
+		err = extractFile(rc, f.Name, int64(f.UncompressedSize64), dst)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractFile writes a single archive entry named name into dst,
+// refusing entries which would be written outside of dst or exceed MaxArchiveEntrySize.
+func extractFile(r io.Reader, name string, size int64, dst string) error {
+	target, err := safeJoin(dst, name)
+	if err != nil {
+		return err
+	}
+	if size > MaxArchiveEntrySize {
+		return fmt.Errorf("%w: %q (%d bytes)", ErrArchiveEntryTooLarge, name, size)
+	}
+	err = os.MkdirAll(filepath.Dir(target), 0o750)
+	if err != nil {
+		return fmt.Errorf("create directory for %q: %w", name, err)
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return fmt.Errorf("create file %q: %w", name, err)
+	}
+	defer f.Close()
+	// declared sizes can't be trusted, so never read more than the limit
+	n, err := io.Copy(f, io.LimitReader(r, MaxArchiveEntrySize+1))
+	if err != nil {
+		return fmt.Errorf("extract %q: %w", name, err)
+	}
+	if n > MaxArchiveEntrySize {
+		return fmt.Errorf("%w: %q", ErrArchiveEntryTooLarge, name)
+	}
+	return nil
+}
+
+// safeJoin joins an archive entry name to dst, ensuring the result remains within dst.
+func safeJoin(dst string, name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%w: %q", ErrArchiveEntryUnsafe, name)
+	}
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: %q", ErrArchiveEntryUnsafe, name)
+	}
+	return filepath.Join(dst, filepath.FromSlash(cleaned)), nil
+}
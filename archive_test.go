@@ -0,0 +1,123 @@
+package illuminated
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testArchiveFiles maps archive entry names to content.
+var testArchiveFiles = map[string]string{
+	"guide/Install.md":        "# Install",
+	"guide/Usage.md":          "# Usage",
+	"guide/images/picture.md": "# Nested",
+}
+
+func writeTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		require.NoError(t, err)
+		_, err = tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func writeZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestStageArchive(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		archive []byte
+	}{
+		{"guide.tar.gz", writeTarGz(t, testArchiveFiles)},
+		{"guide.zip", writeZip(t, testArchiveFiles)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			source := path.Join(dir, tc.name)
+			err := os.WriteFile(source, tc.archive, 0o644)
+			require.NoError(t, err)
+
+			projectDir := path.Join(dir, "project")
+			err = Stage("file://"+source, projectDir)
+			require.NoError(t, err)
+
+			entries, err := os.ReadDir(path.Join(projectDir, DefaultDirNameStaging))
+			require.NoError(t, err)
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			// top-level directory is unwrapped, nested directories are ignored
+			require.Equal(t, []string{"Install.md", "Usage.md"}, names)
+		})
+	}
+}
+
+func TestStageArchiveNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	guide, faq := path.Join(dir, "guide.zip"), path.Join(dir, "faq.zip")
+	require.NoError(t, os.WriteFile(guide, writeZip(t, map[string]string{"Install.md": "# Install"}), 0o644))
+	require.NoError(t, os.WriteFile(faq, writeZip(t, map[string]string{"Usage.md": "# Usage"}), 0o644))
+
+	// a namespace of digits doesn't share the directory of a source without a namespace
+	projectDir := path.Join(dir, "project")
+	pages, err := StageSources([]Source{{Location: guide}, {Namespace: "0", Location: faq}}, projectDir)
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+	require.NotEqual(t, pages[0].Origin, pages[1].Origin)
+	require.FileExists(t, path.Join(pages[0].Origin, "Install.md"))
+	require.FileExists(t, path.Join(pages[1].Origin, "Usage.md"))
+}
+
+func TestStageArchiveUnsafe(t *testing.T) {
+	for _, name := range []string{"../escape.md", "guide/../../escape.md", "/etc/escape.md"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := writeTarGz(t, map[string]string{name: "# Escape"})
+			_, err := extractArchive(bytes.NewReader(archive), ".tar.gz", dir)
+			require.ErrorIs(t, err, ErrArchiveEntryUnsafe)
+		})
+	}
+}
+
+func TestStageArchiveTooLarge(t *testing.T) {
+	limit := MaxArchiveEntrySize
+	MaxArchiveEntrySize = 4
+	defer func() { MaxArchiveEntrySize = limit }()
+
+	dir := t.TempDir()
+	archive := writeZip(t, map[string]string{"Large.md": "# Too large"})
+	_, err := extractArchive(bytes.NewReader(archive), ".zip", dir)
+	require.ErrorIs(t, err, ErrArchiveEntryTooLarge)
+}
@@ -0,0 +1,127 @@
+package illuminated
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// BannerData is the data available to the banner template.
+type BannerData struct {
+	Lang       string // language of the page
+	Dir        string // text direction of the language, ltr or rtl
+	Text       string // translated statement of how the page was translated
+	Link       string // translated text of the link to the page in the base language
+	Href       string // link to the page in the base language, empty if the page isn't generated in it
+	BaseLang   string // BCP 47 tag of the base language
+	Translator string // name of the translation service
+	Review     string // ReviewMachine or ReviewReviewed
+	Build      BuildInfo
+}
+
+// bannerFunc renders the banner of a translated page, linking to href in the base language.
+type bannerFunc func(page StagedPage, href string) (template.HTML, error)
+
+// banners returns the function rendering banners of the pages of documents in lang,
+// or nil if they have none: the banner is not enabled, lang is the base language or nothing was translated.
+// The texts of the banners are translated once, for every page.
+func (o RenderOptions) banners(lang string) (bannerFunc, error) {
+	base := Language(o.Build.BaseLang)
+	info := Language(lang)
+	if !o.Banner || o.Build.Translator == "" || info.Tag == base.Tag {
+		return nil, nil
+	}
+	texts := map[string]string{}
+	for _, text := range []string{DefaultBannerMachine, DefaultBannerReviewed, DefaultBannerLink} {
+		translated, err := o.translateTitle(lang, text)
+		if err != nil {
+			return nil, fmt.Errorf("translate banner: %w", err)
+		}
+		texts[text] = translated
+	}
+	return func(page StagedPage, href string) (template.HTML, error) {
+		// pages kept in the base language aren't translated
+		if !page.Meta.Translated() {
+			return "", nil
+		}
+		data := BannerData{
+			Lang:       info.Tag,
+			Dir:        info.Direction,
+			Text:       texts[DefaultBannerMachine],
+			Link:       texts[DefaultBannerLink],
+			Href:       href,
+			BaseLang:   base.Tag,
+			Translator: o.Build.Translator,
+			Review:     ReviewMachine,
+			Build:      o.Build,
+		}
+		if page.Meta.ReviewedIn(lang) {
+			data.Text, data.Review = texts[DefaultBannerReviewed], ReviewReviewed
+		}
+		return o.Templates.RenderBanner(data)
+	}, nil
+}
+
+// RenderBanner renders the banner of a translated page with the banner template.
+func (t *Templates) RenderBanner(data BannerData) (template.HTML, error) {
+	var out bytes.Buffer
+	err := t.banner.Execute(&out, data)
+	if err != nil {
+		return "", fmt.Errorf("execute template %q: %w", t.banner.Name(), err)
+	}
+	return template.HTML(out.String()), nil
+}
+
+// insertBanner inserts banner at the start of the body of doc.
+func insertBanner(doc *html.Node, banner template.HTML) error {
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		return errors.New("no body")
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(string(banner)), body)
+	if err != nil {
+		return fmt.Errorf("parse banner: %w", err)
+	}
+	first := body.FirstChild
+	for _, n := range nodes {
+		body.InsertBefore(n, first)
+	}
+	return nil
+}
+
+// addBanner inserts the banner of page, linking to href, at the start of the body of the HTML file at filePath.
+func addBanner(filePath string, banner bannerFunc, page StagedPage, href string) error {
+	b, err := banner(page, href)
+	if err != nil || b == "" {
+		return err
+	}
+	doc, err := readHTML(filePath)
+	if err != nil {
+		return err
+	}
+	err = insertBanner(doc, b)
+	if err != nil {
+		return fmt.Errorf("%q: %w", filePath, err)
+	}
+	return writeHTML(filePath, doc)
+}
+
+// originalHref returns the link from HTML output to page in the base language,
+// its anchor in the joined document when joining, or an empty string if it isn't generated in the base language.
+func (o RenderOptions) originalHref(page StagedPage) string {
+	if !page.Meta.Includes(o.Build.BaseLang) {
+		return ""
+	}
+	u := url.URL{Path: fmt.Sprintf("%s.%s.html", o.Build.BaseLang, PageAnchor(page.Name))}
+	if o.Join {
+		u = url.URL{Path: fmt.Sprintf("%s.%s.html", o.Build.BaseLang, path.Base(o.ProjectDir)), Fragment: PageAnchor(page.Name)}
+	}
+	return u.String()
+}
@@ -0,0 +1,96 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBannerHTML(t *testing.T) {
+	pages := map[string]string{
+		"Home":    `<html lang="fa"><body><h1>خانه</h1></body></html>`,
+		"Install": `<html lang="fa"><body><h1>نصب</h1></body></html>`,
+		"License": `<html lang="en"><body><h1>License</h1></body></html>`,
+	}
+	doc, opts := renderTestOptions(t, "fa", pages, "Home", "Install", "License")
+	// the second page is reviewed and the third kept in the base language
+	keep := false
+	doc.Pages[1].Page.Meta.Reviewed = []string{"fa"}
+	doc.Pages[2].Page.Meta.Translate = &keep
+	opts.Banner = true
+
+	output := path.Join(opts.ProjectDir, DefaultDirNameOutput)
+	_, err := HTMLRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path.Join(output, "fa.Home.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(content),
+		`<div class="translation-banner" role="note" lang="fa" dir="rtl" data-translator="mock" data-review="machine">`)
+	require.Contains(t, string(content),
+		`<p>fa:`+DefaultBannerMachine+` <a href="en.Home.html" hreflang="en">fa:`+DefaultBannerLink+`</a></p>`)
+
+	content, err = os.ReadFile(path.Join(output, "fa.Install.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), `data-review="reviewed"`)
+	require.Contains(t, string(content), `fa:`+DefaultBannerReviewed)
+
+	content, err = os.ReadFile(path.Join(output, "fa.License.html"))
+	require.NoError(t, err)
+	require.NotContains(t, string(content), `<div class="translation-banner"`)
+
+	// joined documents link to the anchor of the page in the joined document of the base language
+	opts.Join = true
+	_, err = HTMLRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	name := path.Base(opts.ProjectDir)
+	content, err = os.ReadFile(JoinedHTMLPath("fa", opts.ProjectDir, name))
+	require.NoError(t, err)
+	require.Regexp(t, `<a id="Install"></a>\s*<div class="translation-banner"[^>]*>\s*<p>[^<]*<a href="en\.`+name+
+		`\.html#Install" hreflang="en">`, string(content))
+
+	// documents in the base language have no banner
+	opts.Build.BaseLang = "fa"
+	opts.Join = false
+	_, err = HTMLRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	content, err = os.ReadFile(path.Join(output, "fa.Home.html"))
+	require.NoError(t, err)
+	require.NotContains(t, string(content), `<div class="translation-banner"`)
+}
+
+func TestBannerSite(t *testing.T) {
+	pages := map[string]string{
+		"Home":    `<html lang="fa"><body><h1>خانه</h1></body></html>`,
+		"Install": `<html lang="fa"><body><h1>نصب</h1></body></html>`,
+	}
+	doc, opts := renderTestOptions(t, "fa", pages, "Home", "Install")
+	doc.Pages[1].Page.Meta.Reviewed = []string{"fa"}
+	opts.Banner = true
+	base := Document{Lang: "en"}
+	for _, p := range doc.Pages[:1] {
+		file := path.Join(opts.ProjectDir, DefaultDirNameBuild, "en."+PageAnchor(p.Page.Name)+".html")
+		require.NoError(t, os.WriteFile(file, []byte(`<html lang="en"><body><h1>Home</h1></body></html>`), 0o644))
+		base.Pages = append(base.Pages, DocumentPage{Page: p.Page, Path: file})
+	}
+	_, err := SiteRenderer{}.Render([]Document{base, doc}, opts)
+	require.NoError(t, err)
+	site := path.Join(opts.outputDir(), DefaultDirNameSite)
+
+	content, err := os.ReadFile(path.Join(site, "fa", "Home.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), `<a href="../en/Home.html" hreflang="en">fa:`+DefaultBannerLink+`</a>`)
+	// pages not generated in the base language have nothing to link to
+	content, err = os.ReadFile(path.Join(site, "fa", "Install.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), `<p>fa:`+DefaultBannerReviewed+`</p>`)
+	content, err = os.ReadFile(path.Join(site, "en", "Home.html"))
+	require.NoError(t, err)
+	require.NotContains(t, string(content), `<div class="translation-banner"`)
+	// banners aren't indexed for search
+	index, err := os.ReadFile(path.Join(site, "fa", DefaultFileNameSearchIndex))
+	require.NoError(t, err)
+	require.NotContains(t, string(index), DefaultBannerMachine)
+}
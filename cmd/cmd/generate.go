@@ -1,12 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/getlantern/illuminated"
@@ -16,15 +18,33 @@ import (
 
 var (
 	projectDir    string
-	source        string   // source document(s): directory or GitHub wiki URL
-	targetLangs   []string // target languages (ISO 639-1 codes)
-	baseLang      string   // base language of source files (ISO 639-1 code)
-	translator    string   // translator to use, e.g. "google", "mock"
-	overridesPath string   // path to yaml file defining overrides
-	join          bool     // join HTML files into single document or split into individual files?
-	html          bool     // generate HTML output
-	pdf           bool     // generate PDF output
-	title         string   // title of the document in base language
+	sources       []string              // source document(s): [namespace=]directory, GitHub wiki URL, archive or stdin
+	targetLangs   []string              // target languages (ISO 639-1 codes)
+	baseLang      string                // base language of source files (ISO 639-1 code)
+	translator    string                // translator to use, e.g. "google", "mock"
+	overridesPath string                // path to yaml file defining overrides
+	profilesPath  string                // path to yaml file defining language profiles for PDF
+	layout        illuminated.PDFLayout // page layout of PDF output
+	metadata      illuminated.Metadata  // document properties of PDF, DOCX and ODT output
+	pdfEngine     string                // PDF engine replacing those of language profiles
+	referenceDoc  string                // DOCX or ODT file whose styles word processor output uses
+	frontPath     string                // path to yaml file describing front pages of joined documents
+	banner        bool                  // add a banner to translated pages
+	join          bool                  // join HTML files into single document or split into individual files?
+	formats       []string              // output formats, by the names of registered renderers
+	html          bool                  // generate HTML output
+	pdf           bool                  // generate PDF output
+	title         string                // title of the document in base language
+	rebuild       bool                  // ignore build state and regenerate everything
+	toc           bool                  // add a table of contents to HTML
+	tocDepth      int                   // deepest heading level in the table of contents
+	site          bool                  // generate a static site
+	siteURL       string                // base URL the site is served from
+	epub          bool                  // generate EPUB output
+	markdown      bool                  // generate translated markdown output
+	epubFonts     []string              // font files embedded in EPUB output
+	templateDir   string                // directory of custom HTML templates
+	stylesheets   []string              // CSS files added to HTML
 )
 
 // generateCmd represents the generate command
@@ -33,28 +53,42 @@ var generateCmd = &cobra.Command{
 	Short:  "generate documents from source files",
 	PreRun: func(cmd *cobra.Command, args []string) { Init() },
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// stage files from remote or outside dir to projectDir
-		err := illuminated.Stage(source, projectDir)
+		selected, err := selectedFormats()
 		if err != nil {
-			slog.Error("unable to stage selected source", "error", err)
-			os.Exit(1)
+			return err
 		}
-		slog.Debug("source files staged", "source", source, "projectDir", projectDir)
 
-		// process the markdown into html
-		files, err := os.ReadDir(path.Join(projectDir, illuminated.DefaultDirNameStaging))
+		// stage files from remote or outside dirs to projectDir
+		pages, err := illuminated.StageSources(parseSources(sources), projectDir)
 		if err != nil {
-			return fmt.Errorf("read staging directory: %w", err)
+			slog.Error("unable to stage selected sources", "error", err)
+			os.Exit(1)
+		}
+		slog.Debug("source files staged", "sources", sources, "projectDir", projectDir)
+
+		// ensure build and output directories exist
+		buildDir := path.Join(projectDir, illuminated.DefaultDirNameBuild)
+		for _, dir := range []string{buildDir, path.Join(projectDir, illuminated.DefaultDirNameOutput)} {
+			err = os.MkdirAll(dir, illuminated.DefaultFilePermissions)
+			if err != nil {
+				return fmt.Errorf("create directory %q: %w", dir, err)
+			}
 		}
 
-		// ensure output directory exists
-		err = os.MkdirAll(
-			path.Join(projectDir, illuminated.DefaultDirNameOutput),
-			illuminated.DefaultFilePermissions,
-		)
+		// previous build state allows skipping unchanged pages
+		state, err := illuminated.ReadBuildState(projectDir)
 		if err != nil {
-			return fmt.Errorf("create output directory: %w", err)
+			return fmt.Errorf("read build state: %w", err)
 		}
+		if rebuild {
+			slog.Debug("rebuild requested, ignoring previous build state")
+		}
+		defer func() {
+			err := state.Write()
+			if err != nil {
+				slog.Error("unable to write build state", "error", err)
+			}
+		}()
 
 		var g translators.Translator
 		if len(targetLangs) > 0 {
@@ -65,86 +99,129 @@ var generateCmd = &cobra.Command{
 			defer g.Close(cmd.Context())
 		}
 
-		// generate HTML from markdown
-		for _, file := range files {
-			if file.IsDir() || !strings.HasSuffix(file.Name(), ".md") {
-				slog.Debug("skipping dir (expects only markdown files)", "name", file.Name())
-				continue
+		// apply any overrides
+		if overridesPath == "" {
+			overridesPath = path.Join(illuminated.DefaultFileNameOverrides)
+		}
+		overrides, err := illuminated.ReadOverrideFile(overridesPath)
+		if err != nil {
+			if os.IsNotExist(err) || strings.Contains(err.Error(), "no such file") {
+				slog.Debug("no override file found",
+					"expected", overridesPath,
+				)
+			} else {
+				return fmt.Errorf("read override file %q: %w", overridesPath, err)
 			}
-			sourcePath := filepath.Join(projectDir, illuminated.DefaultDirNameStaging, file.Name())
-			slog.Debug("reading markdown file", "path", sourcePath)
-			outName := strings.TrimSuffix(file.Name(), ".md")
-			outName = fmt.Sprintf("%s.%s.%s", baseLang, outName, "html")
-			outPath := path.Join(projectDir, illuminated.DefaultDirNameOutput, outName)
+		}
+		// overrides change translated output, so they are part of its inputs
+		overridesHash, _ := illuminated.HashFile(overridesPath)
+
+		templates, err := illuminated.LoadTemplates(templateDir, stylesheets)
+		if err != nil {
+			return fmt.Errorf("load templates: %w", err)
+		}
+		if toc {
+			templates.TOCDepth = tocDepth
+		}
+		// date outputs from their sources, so the same sources build the same outputs
+		buildDate, err := illuminated.BuildDate(pages)
+		if err != nil {
+			return fmt.Errorf("determine build date: %w", err)
+		}
+		buildInfo := illuminated.BuildInfo{
+			Generator:  "illuminated",
+			Date:       buildDate,
+			BaseLang:   baseLang,
+			Translator: translator,
+		}
 
-			err := illuminated.MarkdownToHTML(sourcePath, outPath)
+		// build HTML for each language, keyed by language
+		built := map[string][]illuminated.DocumentPage{}
+		// base HTML of every page, which element IDs of joined documents are reserved from
+		var base []illuminated.DocumentPage
+		keep := map[string]bool{}
+		staged := map[string]bool{}
+
+		// generate HTML from markdown, in page order
+		for _, page := range pages {
+			sourcePath := filepath.Join(projectDir, illuminated.DefaultDirNameStaging, page.Name)
+			sourceHash, err := illuminated.HashFile(sourcePath)
 			if err != nil {
-				return fmt.Errorf("reading markdown file %q: %w", sourcePath, err)
+				return fmt.Errorf("hash markdown file %q: %w", sourcePath, err)
+			}
+			state.Sources[page.Name] = sourceHash
+			staged[page.Name] = true
+
+			outName := strings.TrimSuffix(page.Name, ".md")
+			outName = fmt.Sprintf("%s.%s.%s", baseLang, outName, "html")
+			outPath := path.Join(buildDir, outName)
+			// base HTML is always built, as it is the source of translations
+			keep[outPath] = true
+			base = append(base, illuminated.DocumentPage{Page: page, Path: outPath})
+			if page.Meta.Includes(baseLang) {
+				built[baseLang] = append(built[baseLang], illuminated.DocumentPage{Page: page, Path: outPath})
+			}
+
+			inputs := illuminated.Digest(sourceHash, illuminated.MarkdownVersion, baseLang)
+			if !rebuild && state.Fresh(outPath, inputs) {
+				slog.Debug("skipping unchanged HTML in base lang", "source", sourcePath, "out", outPath)
+			} else {
+				slog.Debug("reading markdown file", "path", sourcePath)
+				err := illuminated.MarkdownToHTML(sourcePath, outPath, baseLang)
+				if err != nil {
+					return fmt.Errorf("reading markdown file %q: %w", sourcePath, err)
+				}
+				err = state.Record(outPath, inputs)
+				if err != nil {
+					return fmt.Errorf("record %q in build state: %w", outPath, err)
+				}
+				slog.Debug("created HTML in base lang",
+					"source", sourcePath,
+					"lang", baseLang,
+					"out", outPath,
+				)
 			}
-			slog.Debug("created HTML in base lang",
-				"source", sourcePath,
-				"lang", baseLang,
-				"out", outPath,
-			)
 
 			// also generate HTML for each target language
 			for _, lang := range targetLangs {
 				if lang == baseLang {
 					continue
 				}
-				outName = strings.TrimSuffix(file.Name(), ".md")
+				if !page.Meta.Includes(lang) {
+					slog.Debug("skipping page excluded from language", "page", page.Name, "lang", lang)
+					continue
+				}
+				outName = strings.TrimSuffix(page.Name, ".md")
 				outName = strings.TrimPrefix(outName, baseLang+".")
 				txOutName := fmt.Sprintf("%s.%s.%s", lang, outName, "html")
-				txOutPath := path.Join(projectDir, illuminated.DefaultDirNameOutput, txOutName)
+				txOutPath := path.Join(buildDir, txOutName)
+				built[lang] = append(built[lang], illuminated.DocumentPage{Page: page, Path: txOutPath})
+				keep[txOutPath] = true
 
-				baseLangFileData, err := os.ReadFile(outPath)
-				if err != nil {
-					return fmt.Errorf("read base language file %q: %w", outPath, err)
-				}
-				tx, err := g.Translate(cmd.Context(), lang, []string{string(baseLangFileData)})
-				if err != nil || len(tx) == 0 {
-					return fmt.Errorf("translate file %q to language %q: %w", outPath, lang, err)
+				// front matter of the source affects translation, so it is included with the base HTML
+				inputs := illuminated.Digest(
+					state.Hash(outPath), sourceHash, lang, translator, overridesHash, illuminated.ProvenanceVersion,
+				)
+				if !rebuild && state.Fresh(txOutPath, inputs) {
+					slog.Debug("skipping unchanged HTML in target language", "source", outPath, "target", txOutPath)
+					err = state.RecordTranslation(lang, page)
+					if err != nil {
+						return fmt.Errorf("record translation of %q: %w", page.Name, err)
+					}
+					continue
 				}
 
-				// apply any overrides
-				if overridesPath == "" {
-					overridesPath = path.Join(illuminated.DefaultFileNameOverrides)
-				}
-				overrides, err := illuminated.ReadOverrideFile(overridesPath)
+				err = translatePage(cmd.Context(), g, page, lang, outPath, txOutPath, overrides)
 				if err != nil {
-					if os.IsNotExist(err) || strings.Contains(err.Error(), "no such file") {
-						slog.Debug("no override file found",
-							"expected", overridesPath,
-						)
-					} else {
-						return fmt.Errorf("read override file %q: %w", overridesPath, err)
-					}
+					return err
 				}
-				for _, override := range overrides {
-					if override.Language != lang {
-						continue
-					}
-					if override.Original == "" || override.Replacement == "" {
-						slog.Warn("skipping override with empty original or replacement",
-							"override", override,
-						)
-						continue
-					}
-					for i, t := range tx {
-						tx[i] = strings.ReplaceAll(t, override.Original, override.Replacement)
-						slog.Debug("applied override",
-							"title", override.Title,
-							"original", override.Original,
-							"replacement", override.Replacement,
-							"lang", override.Language,
-							"file", txOutName,
-						)
-					}
+				err = state.Record(txOutPath, inputs)
+				if err != nil {
+					return fmt.Errorf("record %q in build state: %w", txOutPath, err)
 				}
-
-				err = os.WriteFile(txOutPath, []byte(tx[0]), illuminated.DefaultFilePermissions)
+				err = state.RecordTranslation(lang, page)
 				if err != nil {
-					return fmt.Errorf("write translated file %q: %w", txOutPath, err)
+					return fmt.Errorf("record translation of %q: %w", page.Name, err)
 				}
 				slog.Debug("created HTML in target language",
 					"source", outPath,
@@ -154,86 +231,69 @@ var generateCmd = &cobra.Command{
 			}
 		}
 
-		// join all files for a language into one HTML
-		if join {
-			// join all HTML files into one
-			for _, lang := range targetLangs {
-				joinedFile, err := illuminated.JoinHTML(lang, projectDir, projectDir)
-				if err != nil {
-					return fmt.Errorf("join HTML files for language %q: %w", lang, err)
-				}
-				slog.Debug("joined HTML files", "file", joinedFile)
-			}
+		err = pruneBuild(state, buildDir, keep, staged)
+		if err != nil {
+			return fmt.Errorf("prune build directory: %w", err)
 		}
 
-		// generate PDF files from HTML
-		if pdf {
-			slog.Debug("generating pdf")
-			files, err := os.ReadDir(path.Join(projectDir, illuminated.DefaultDirNameOutput))
+		// documents of each language with pages, the base language first
+		var docs []illuminated.Document
+		for _, lang := range append([]string{baseLang}, targetLangs...) {
+			if len(built[lang]) == 0 || slices.ContainsFunc(docs, func(d illuminated.Document) bool { return d.Lang == lang }) {
+				continue
+			}
+			docs = append(docs, illuminated.Document{Lang: lang, Pages: built[lang]})
+		}
+		var front *illuminated.FrontPages
+		if frontPath != "" {
+			front, err = illuminated.ReadFrontPages(frontPath)
 			if err != nil {
-				return fmt.Errorf("read output directory: %w", err)
+				return err
 			}
-			for _, file := range files {
-				if file.IsDir() || !strings.HasSuffix(file.Name(), ".html") {
-					slog.Debug("skipping dir (expects only HTML files)", "name", file.Name())
-					continue
-				}
-				sourcePath := filepath.Join(projectDir, illuminated.DefaultDirNameOutput, file.Name())
-				// generate PDF files from HTML
-				parts := strings.Split(file.Name(), ".")
-				if len(parts) < 3 {
-					return fmt.Errorf("invalid file name %q, expected format: <lang>.<name>.html", file.Name())
-				}
-				lang := parts[0]
-				var name string
-				if join {
-					if title != "" {
-						name = strings.ReplaceAll(title, " ", "_")
-					} else {
-						name = projectDir
-					}
-				} else {
-					name = file.Name()
-				}
-				outName := fmt.Sprintf("%s.%s.pdf", lang, name)
-				outPath := path.Join(projectDir, illuminated.DefaultDirNameOutput, outName)
-				if _, err := os.Stat(outPath); !os.IsNotExist(err) {
-					if !force {
-						// TODO: this may be throwing false positives
-						slog.Info("skipping file to avoid clobber, set -f/--force to overwrite", "file", outPath)
-						continue
-					}
-				}
-				resources := path.Join(projectDir, illuminated.DefaultDirNameStaging)
-
-				// translate the title
-				var translatedTitle string
-				if lang != baseLang && title != "" {
-					tx, err := g.Translate(cmd.Context(), lang, []string{title})
-					if err != nil || len(tx) == 0 {
-						return fmt.Errorf("translate title %q to language %q: %w", title, lang, err)
-					}
-					fmt.Println("tx: ", tx)
-					translatedTitle = regexp.MustCompile("<[^>]*>").ReplaceAllString(tx[0], "")
-
-				}
-				if translatedTitle == "" {
-					translatedTitle = title
-				}
-
-				err := illuminated.WritePDF(sourcePath, outPath, resources, translatedTitle)
-				if err != nil {
-					return fmt.Errorf("generate PDF for lang %q: %w", lang, err)
-				}
-				// remove HTML if only used as intermediate file for PDF generation
-				if !html {
-					slog.Debug("removing HTML file after PDF generation", "file", sourcePath)
-					err = os.Remove(sourcePath)
-					if err != nil {
-						return fmt.Errorf("remove HTML file after PDF generation: %w", err)
-					}
-				}
+			if !join {
+				slog.Warn("front pages are only added to joined documents", "file", frontPath)
+			}
+		}
+		layout.TOCDepth = tocDepth
+		opts := illuminated.RenderOptions{
+			ProjectDir: projectDir,
+			Title:      title,
+			Join:       join,
+			Base:       base,
+			Templates:  templates,
+			Build:      buildInfo,
+			State:      state,
+			Rebuild:    rebuild,
+			Force:      force,
+			TranslateTitle: func(lang, title string) (string, error) {
+				return translateTitle(cmd.Context(), g, lang, title)
+			},
+			Front:        front,
+			Banner:       banner,
+			ProfilesPath: profilesPath,
+			PDFEngine:    pdfEngine,
+			Layout:       layout,
+			Metadata:     metadata,
+			ReferenceDoc: referenceDoc,
+			SiteURL:      siteURL,
+			EPUBFonts:    epubFonts,
+		}
+		missing := map[illuminated.MissingLink]bool{}
+		for _, format := range selected {
+			r, err := illuminated.LookupRenderer(format)
+			if err != nil {
+				return err
+			}
+			m, err := r.Render(docs, opts)
+			if err != nil {
+				return fmt.Errorf("render %s output: %w", format, err)
 			}
+			for _, link := range m {
+				missing[link] = true
+			}
+		}
+		for link := range missing {
+			slog.Warn("link to page which does not exist", "page", link.Page, "href", link.Href)
 		}
 		slog.Info("document generation complete")
 		return nil
@@ -244,9 +304,10 @@ func init() {
 	rootCmd.AddCommand(generateCmd)
 
 	// source
-	generateCmd.PersistentFlags().StringVarP(
-		&source, "source", "s", "",
-		"source document(s) location, can be: directory, or GitHub wiki URL",
+	generateCmd.PersistentFlags().StringArrayVarP(
+		&sources, "source", "s", []string{},
+		"source document(s) location, can be: directory, GitHub wiki URL, .zip or .tar.gz archive, or - for a tar stream on stdin; "+
+			"repeat to merge sources in order, optionally prefixing page names with a namespace as namespace=location",
 	)
 	generateCmd.MarkPersistentFlagRequired("source")
 
@@ -268,15 +329,227 @@ func init() {
 		"path to yaml file defining overrides, see readme for example",
 	)
 
+	generateCmd.PersistentFlags().StringVar(
+		&profilesPath, "profiles", illuminated.DefaultFileNameProfiles,
+		"path to yaml file defining PDF fonts, direction and engine per language, see readme for example",
+	)
+
 	generateCmd.PersistentFlags().StringVarP(&title, "title", "T", "", "title of the document (in base language)")
 
 	// output
 	generateCmd.PersistentFlags().BoolVarP(&join, "join", "j", false, "join all documents into one")
+	generateCmd.PersistentFlags().StringSliceVarP(&formats, "format", "F", []string{},
+		"output formats to generate, any of: "+strings.Join(illuminated.Formats(), ", "),
+	)
 	generateCmd.PersistentFlags().BoolVarP(&html, "html", "H", false, "generate HTML output")
 	generateCmd.PersistentFlags().BoolVarP(&pdf, "pdf", "P", false, "generate PDF output")
-	generateCmd.MarkFlagsOneRequired("html", "pdf")
+	generateCmd.PersistentFlags().StringVar(&pdfEngine, "pdf-engine", "",
+		"engine typesetting PDF output, replacing those of language profiles: "+illuminated.PDFEngineNative+
+			" to typeset in Go without pandoc, or a LaTeX engine pandoc runs such as xelatex",
+	)
+	generateCmd.PersistentFlags().StringVar(&frontPath, "front-pages", "",
+		"yaml file describing the cover, disclaimer and license pages at the start of joined documents",
+	)
+	generateCmd.PersistentFlags().BoolVar(&banner, "banner", false,
+		"add a banner to translated pages of HTML and site output, stating how they were translated and linking to the original",
+	)
+	generateCmd.PersistentFlags().StringVar(&metadata.Author, "author", "", "author of PDF, DOCX and ODT output")
+	generateCmd.PersistentFlags().StringVar(&metadata.Subject, "subject", "",
+		"subject of PDF, DOCX and ODT output (in base language)",
+	)
+	generateCmd.PersistentFlags().StringSliceVar(&metadata.Keywords, "keywords", []string{},
+		"comma-separated keywords of PDF, DOCX and ODT output",
+	)
+	generateCmd.PersistentFlags().StringVar(&referenceDoc, "reference-doc", "",
+		"DOCX or ODT file whose styles DOCX or ODT output of the same format uses, with fonts replaced by those of language profiles",
+	)
+	generateCmd.PersistentFlags().BoolVar(&site, "site", false,
+		"generate a static site with a directory per language, in the site directory of the output",
+	)
+	generateCmd.PersistentFlags().StringVar(&siteURL, "site-url", "",
+		"base URL the site is served from, for language alternates and the sitemap",
+	)
+	generateCmd.PersistentFlags().BoolVar(&epub, "epub", false, "generate an EPUB 3 book per language")
+	generateCmd.PersistentFlags().StringArrayVar(&epubFonts, "epub-font", []string{},
+		"font file (.ttf, .otf, .woff or .woff2) embedded in EPUB output, repeat for several",
+	)
+	generateCmd.PersistentFlags().BoolVarP(&markdown, "markdown", "M", false,
+		"generate translated markdown, in a directory per language of the markdown directory of the output",
+	)
+	generateCmd.MarkFlagsOneRequired("format", "html", "pdf", "site", "epub", "markdown")
 	generateCmd.PersistentFlags().BoolVarP(&force, "force", "f",
 		false,
 		"overwrite existing files",
 	)
+	generateCmd.PersistentFlags().StringVar(&layout.Paper, "paper", "", "paper size of PDF output, such as a4 or letter")
+	generateCmd.PersistentFlags().StringVar(&layout.Margins, "margins", "", "page margins of PDF output, such as 2cm or 1in")
+	generateCmd.PersistentFlags().BoolVar(&layout.NumberSections, "number-sections", false, "number sections of PDF output")
+	generateCmd.PersistentFlags().StringVar(&layout.Header, "header", "",
+		"text at the top of each page of PDF output, {page} is replaced by the page number",
+	)
+	generateCmd.PersistentFlags().StringVar(&layout.Footer, "footer", "",
+		"text at the bottom of each page of PDF output, {page} is replaced by the page number (default: the page number)",
+	)
+	generateCmd.PersistentFlags().BoolVar(&layout.Cover, "cover", false, "place the title of PDF output on a cover page")
+	generateCmd.PersistentFlags().StringVar(&layout.CoverImage, "cover-image", "", "image on the cover page of PDF output")
+	generateCmd.PersistentFlags().BoolVar(&toc, "toc", false, "add a table of contents to HTML output")
+	generateCmd.PersistentFlags().IntVar(&tocDepth, "toc-depth", illuminated.DefaultTOCDepth,
+		"deepest heading level included in the table of contents of HTML (with --toc) and PDF output, 0 for none in PDF",
+	)
+	generateCmd.PersistentFlags().StringVar(&templateDir, "templates", "",
+		"directory of HTML templates replacing the default theme: page.html, joined.html and style.css",
+	)
+	generateCmd.PersistentFlags().StringArrayVar(&stylesheets, "css", []string{},
+		"CSS file added to HTML output after the theme stylesheet, repeat for several",
+	)
+	generateCmd.PersistentFlags().BoolVar(&rebuild, "rebuild", false,
+		"ignore previous build state and regenerate all files",
+	)
+}
+
+// parseSources parses each [namespace=]location source flag.
+func parseSources(specs []string) []illuminated.Source {
+	var parsed []illuminated.Source
+	for _, spec := range specs {
+		parsed = append(parsed, illuminated.ParseSource(spec))
+	}
+	return parsed
+}
+
+// selectedFormats returns the formats selected with --format or the flag of each format,
+// in the order their renderers were registered.
+func selectedFormats() ([]string, error) {
+	requested := slices.Clone(formats)
+	for format, ok := range map[string]bool{"html": html, "pdf": pdf, "site": site, "epub": epub, "markdown": markdown} {
+		if ok {
+			requested = append(requested, format)
+		}
+	}
+	for _, format := range requested {
+		_, err := illuminated.LookupRenderer(format)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var selected []string
+	for _, format := range illuminated.Formats() {
+		if slices.Contains(requested, format) {
+			selected = append(selected, format)
+		}
+	}
+	return selected, nil
+}
+
+// translatePage translates the base language HTML of page at sourcePath into lang,
+// writing the translation to outPath.
+func translatePage(
+	ctx context.Context,
+	g translators.Translator,
+	page illuminated.StagedPage,
+	lang string,
+	sourcePath string,
+	outPath string,
+	overrides []illuminated.Override,
+) error {
+	if !page.Meta.Translated() {
+		slog.Debug("copying page marked not to be translated", "page", page.Name, "lang", lang)
+		err := illuminated.CopyFile(sourcePath, outPath)
+		if err != nil {
+			return fmt.Errorf("copy untranslated file %q: %w", sourcePath, err)
+		}
+		return nil
+	}
+
+	baseLangFileData, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("read base language file %q: %w", sourcePath, err)
+	}
+	doc, err := illuminated.ProtectPhrases(string(baseLangFileData), page.Meta.NoTranslate)
+	if err != nil {
+		return fmt.Errorf("protect untranslated phrases in %q: %w", sourcePath, err)
+	}
+	tx, err := g.Translate(ctx, lang, []string{doc})
+	if err != nil || len(tx) == 0 {
+		return fmt.Errorf("translate file %q to language %q: %w", sourcePath, lang, err)
+	}
+
+	// page overrides apply in addition to those from the override file
+	translated := illuminated.ApplyOverrides(tx[0], lang, slices.Concat(overrides, page.Meta.Overrides))
+	// anchors stay the same as the base language, so links to sections work in every language
+	translated, err = illuminated.KeepHeadingIDs(string(baseLangFileData), translated)
+	if err != nil {
+		return fmt.Errorf("keep heading IDs of %q: %w", outPath, err)
+	}
+	// blocks record how they were translated, for readers and reviewers
+	translated, err = illuminated.MarkProvenance(string(baseLangFileData), translated, illuminated.Provenance{
+		Translator: translator,
+		Reviewed:   page.Meta.ReviewedIn(lang),
+	})
+	if err != nil {
+		return fmt.Errorf("mark provenance of %q: %w", outPath, err)
+	}
+	translated, err = illuminated.SetLanguage(translated, lang)
+	if err != nil {
+		return fmt.Errorf("set language of %q: %w", outPath, err)
+	}
+	err = os.WriteFile(outPath, []byte(translated), illuminated.DefaultFilePermissions)
+	if err != nil {
+		return fmt.Errorf("write translated file %q: %w", outPath, err)
+	}
+	return nil
+}
+
+// translateTitle translates a document title from the base language into lang, without markup.
+func translateTitle(ctx context.Context, g translators.Translator, lang, title string) (string, error) {
+	if lang == baseLang || title == "" {
+		return title, nil
+	}
+	tx, err := g.Translate(ctx, lang, []string{title})
+	if err != nil || len(tx) == 0 {
+		return "", fmt.Errorf("translate title %q to language %q: %w", title, lang, err)
+	}
+	translated := regexp.MustCompile("<[^>]*>").ReplaceAllString(tx[0], "")
+	if translated == "" {
+		return title, nil
+	}
+	return translated, nil
+}
+
+// pruneBuild removes built files and build state entries
+// which no longer correspond to a staged source or selected language.
+func pruneBuild(
+	state *illuminated.BuildState,
+	buildDir string,
+	keep map[string]bool,
+	staged map[string]bool,
+) error {
+	entries, err := os.ReadDir(buildDir)
+	if err != nil {
+		return fmt.Errorf("read build directory: %w", err)
+	}
+	for _, entry := range entries {
+		p := path.Join(buildDir, entry.Name())
+		if keep[p] {
+			continue
+		}
+		slog.Debug("removing stale build file", "path", p)
+		err = os.RemoveAll(p)
+		if err != nil {
+			return fmt.Errorf("remove stale build file: %w", err)
+		}
+		state.Forget(p)
+	}
+	for name := range state.Sources {
+		if !staged[name] {
+			delete(state.Sources, name)
+		}
+	}
+	for _, pages := range state.Translations {
+		for name := range pages {
+			if !staged[name] {
+				delete(pages, name)
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/getlantern/illuminated"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/spf13/cobra"
+)
+
+var (
+	remotes     map[string]string // language to git remote published to
+	publishFrom string            // output published: markdown or html
+	dryRun      bool              // report changes without committing or pushing
+)
+
+// publishCmd represents the publish command
+var publishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "push generated output into a git repository per language, such as wikis",
+	Long: "clones the repository of each language, writes its generated pages, " +
+		"then commits referencing the source commit and pushes.",
+	PreRun: func(cmd *cobra.Command, args []string) { Init() },
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, err := illuminated.ReadBuildState(projectDir)
+		if err != nil {
+			return fmt.Errorf("read build state: %w", err)
+		}
+		langs := make([]string, 0, len(remotes))
+		for lang := range remotes {
+			langs = append(langs, lang)
+		}
+		slices.Sort(langs)
+		for _, lang := range langs {
+			files, err := illuminated.PublishedFiles(projectDir, lang, publishFrom)
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("no %s output for language %q, generate it first", publishFrom, lang)
+			}
+			commits, err := sourceCommits(state, lang)
+			if err != nil {
+				return err
+			}
+			result, err := illuminated.Publish(illuminated.Publication{
+				Remote:  remotes[lang],
+				Files:   files,
+				Message: illuminated.PublishMessage(lang, commits),
+				Auth:    publishAuth(remotes[lang]),
+				DryRun:  dryRun,
+			}, path.Join(projectDir, illuminated.DefaultDirNamePublish, lang))
+			if err != nil {
+				return fmt.Errorf("publish language %q: %w", lang, err)
+			}
+			w := cmd.OutOrStdout()
+			fmt.Fprintf(w, "%s\t%s\n", lang, remotes[lang])
+			for _, name := range result.Added {
+				fmt.Fprintf(w, "  added\t%s\n", name)
+			}
+			for _, name := range result.Modified {
+				fmt.Fprintf(w, "  modified\t%s\n", name)
+			}
+			if result.Commit != "" {
+				fmt.Fprintf(w, "  pushed\t%s\n", result.Commit[:7])
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(publishCmd)
+
+	publishCmd.PersistentFlags().StringToStringVarP(&remotes, "remote", "r", map[string]string{},
+		"git remote to publish each language to, as lang=url, repeat or separate with commas for several",
+	)
+	publishCmd.MarkPersistentFlagRequired("remote")
+	publishCmd.PersistentFlags().StringVar(&publishFrom, "from", "markdown",
+		"output to publish: markdown (generated with --markdown) or html (generated with --html)",
+	)
+	publishCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "n", false,
+		"list the files which would change without committing or pushing",
+	)
+}
+
+// sourceCommits returns the commits checked out in the sources lang was translated from.
+func sourceCommits(state *illuminated.BuildState, lang string) ([]string, error) {
+	var origins []string
+	for _, t := range state.Translations[lang] {
+		if t.Origin != "" && !slices.Contains(origins, t.Origin) {
+			origins = append(origins, t.Origin)
+		}
+	}
+	slices.Sort(origins)
+	var commits []string
+	for _, origin := range origins {
+		history, err := illuminated.OpenHistory(origin)
+		if err != nil {
+			return nil, err
+		}
+		commit, err := history.Head()
+		if err != nil {
+			return nil, fmt.Errorf("source commit of %q: %w", origin, err)
+		}
+		if commit != "" {
+			commits = append(commits, commit)
+		}
+	}
+	return commits, nil
+}
+
+// publishAuth returns credentials for pushing to remote over HTTPS from GITHUB_TOKEN, if set.
+// SSH remotes authenticate with the SSH agent.
+func publishAuth(remote string) transport.AuthMethod {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" || !strings.HasPrefix(remote, "https://") {
+		return nil
+	}
+	return &http.BasicAuth{Username: "x-access-token", Password: token}
+}
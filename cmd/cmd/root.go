@@ -12,9 +12,8 @@ var verbose, quiet bool
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	// TODO: output markdown, too
-	Short:  "make translated html or pdf from wiki or markdown",
-	Long:   "fetches a local or remote source to generate HTML and/or PDF files in multiple languages.",
+	Short:  "make translated html, pdf, docx, odt, epub or markdown from wiki or markdown",
+	Long:   "fetches a local or remote source to generate HTML, PDF, DOCX, ODT, EPUB and/or markdown files in multiple languages.",
 	PreRun: func(cmd *cobra.Command, args []string) { Init() },
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cmd.Usage()
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/getlantern/illuminated"
+	"github.com/spf13/cobra"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:    "status",
+	Short:  "list translated pages which are up to date, stale or missing",
+	Long:   "compares staged source files with the revisions previously translated by generate.",
+	PreRun: func(cmd *cobra.Command, args []string) { Init() },
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// optionally stage the latest source to compare against
+		if len(sources) > 0 {
+			_, err := illuminated.StageSources(parseSources(sources), projectDir)
+			if err != nil {
+				slog.Error("unable to stage selected sources", "error", err)
+				os.Exit(1)
+			}
+		}
+
+		statuses, err := illuminated.Status(projectDir, targetLangs)
+		if err != nil {
+			return fmt.Errorf("translation status: %w", err)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		var lang string
+		for _, s := range statuses {
+			if s.Language != lang {
+				lang = s.Language
+				fmt.Fprintf(w, "%s\n", lang)
+			}
+			if s.Status == illuminated.StatusStale && len(s.Commits) > 0 {
+				fmt.Fprintf(w, "  %s\t%s\t(%d commits since)\n", s.Status, s.Page, len(s.Commits))
+				for _, c := range s.Commits {
+					fmt.Fprintf(w, "    %s\t%s\t%s\n",
+						c.Hash.String()[:7],
+						c.Author.When.Format("2006-01-02"),
+						firstLine(c.Message),
+					)
+				}
+				continue
+			}
+			fmt.Fprintf(w, "  %s\t%s\n", s.Status, s.Page)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.PersistentFlags().StringArrayVarP(
+		&sources, "source", "s", []string{},
+		"optional source(s) to stage before comparing, as with generate",
+	)
+	statusCmd.PersistentFlags().StringSliceVarP(
+		&targetLangs, "languages", "l", []string{},
+		"languages to report (ISO 639-1 codes), defaults to all previously translated",
+	)
+}
+
+// firstLine returns the first line of a commit message.
+func firstLine(message string) string {
+	for i, r := range message {
+		if r == '\n' {
+			return message[:i]
+		}
+	}
+	return message
+}
@@ -5,9 +5,22 @@ import (
 )
 
 var (
-	DefaultDirProject        = "docs"
-	DefaultDirNameStaging    = "staging"
-	DefaultDirNameOutput     = "output"
-	DefaultFileNameOverrides = "overrides.yml"
-	DefaultFilePermissions   = os.FileMode(0o750)
+	DefaultDirProject         = "docs"
+	DefaultDirNameSources     = "sources"
+	DefaultDirNameStaging     = "staging"
+	DefaultDirNameBuild       = "build"
+	DefaultDirNameOutput      = "output"
+	DefaultDirNameSite        = "site"
+	DefaultDirNameMarkdown    = "markdown"
+	DefaultDirNamePublish     = "publish"
+	DefaultFileNameOverrides  = "overrides.yml"
+	DefaultFileNameProfiles   = "languages.yml"
+	DefaultFileNameBuildState = "build.json"
+	DefaultFileNameTOC        = "_Sidebar.md"
+	DefaultFilePermissions    = os.FileMode(0o750)
+	DefaultTOCDepth           = 3
+	DefaultDisclaimer         = "This document was translated by machine translation and may contain errors."
+	DefaultBannerMachine      = "This page was translated by machine translation and may contain errors."
+	DefaultBannerReviewed     = "This page was translated by machine translation and reviewed by a person."
+	DefaultBannerLink         = "Read the original page."
 )
@@ -0,0 +1,513 @@
+package illuminated
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// epubMediaTypes are the media types of images and fonts embedded in EPUBs, by extension.
+var epubMediaTypes = map[string]string{
+	".png":   "image/png",
+	".jpg":   "image/jpeg",
+	".jpeg":  "image/jpeg",
+	".gif":   "image/gif",
+	".svg":   "image/svg+xml",
+	".webp":  "image/webp",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+// EPUB is an EPUB 3 book of pages in one language, each page a chapter.
+type EPUB struct {
+	Lang      string
+	Title     string    // title of the book, shown on its cover
+	Modified  time.Time // time the book was last modified
+	Resources string    // directory of files linked from pages, such as images
+	Fonts     []string  // font files embedded in the book, used for its text
+	Front     string    // HTML of the front pages, shown in place of the cover if set
+
+	chapters []epubChapter
+}
+
+// epubChapter is a page of an EPUB.
+type epubChapter struct {
+	page StagedPage
+	file string
+}
+
+// epubItem is a file of an EPUB publication, listed in its manifest.
+type epubItem struct {
+	ID         string
+	Href       string
+	MediaType  string
+	Properties string
+	content    []byte
+}
+
+// epubNavPoint is an entry of the navigation document, with nested entries.
+type epubNavPoint struct {
+	Href     string
+	Title    string
+	Children []epubNavPoint
+}
+
+// NewEPUB returns an EPUB in language lang with title.
+func NewEPUB(lang, title string) *EPUB {
+	return &EPUB{Lang: lang, Title: title, Modified: time.Now()}
+}
+
+// EPUBRenderer writes an EPUB book per language, titled with the translated title.
+type EPUBRenderer struct{}
+
+// Render writes the books of docs to the output directory.
+func (EPUBRenderer) Render(docs []Document, opts RenderOptions) ([]MissingLink, error) {
+	name := path.Base(opts.ProjectDir)
+	if opts.Title != "" {
+		name = strings.ReplaceAll(opts.Title, " ", "_")
+	}
+	var missing []MissingLink
+	for _, doc := range docs {
+		if len(doc.Pages) == 0 {
+			continue
+		}
+		outPath := path.Join(opts.outputDir(), fmt.Sprintf("%s.%s.epub", doc.Lang, name))
+		hashes := []string{opts.Title, name}
+		for _, p := range doc.Pages {
+			hashes = append(hashes, p.Page.Name, opts.State.Hash(p.Path))
+		}
+		hashes = append(hashes, opts.frontDigest())
+		for _, font := range opts.EPUBFonts {
+			fontHash, err := HashFile(font)
+			if err != nil {
+				return nil, fmt.Errorf("hash font %q: %w", font, err)
+			}
+			hashes = append(hashes, fontHash)
+		}
+		inputs := Digest(hashes...)
+		if opts.fresh(outPath, inputs) {
+			slog.Debug("skipping unchanged EPUB", "file", outPath)
+			continue
+		}
+		bookTitle, err := opts.translateTitle(doc.Lang, opts.title())
+		if err != nil {
+			return nil, err
+		}
+		e := NewEPUB(doc.Lang, bookTitle)
+		e.Resources = path.Join(opts.ProjectDir, DefaultDirNameStaging)
+		e.Fonts = opts.EPUBFonts
+		if !opts.Build.Date.IsZero() {
+			e.Modified = opts.Build.Date
+		}
+		front, err := opts.frontPages(doc.Lang)
+		if err != nil {
+			return nil, fmt.Errorf("front pages for language %q: %w", doc.Lang, err)
+		}
+		e.Front = string(front)
+		for _, p := range doc.Pages {
+			e.AddChapter(p.Page, p.Path)
+		}
+		m, err := e.Write(outPath)
+		if err != nil {
+			return nil, fmt.Errorf("write EPUB for language %q: %w", doc.Lang, err)
+		}
+		missing = append(missing, m...)
+		err = opts.State.Record(outPath, inputs)
+		if err != nil {
+			return nil, fmt.Errorf("record %q in build state: %w", outPath, err)
+		}
+		slog.Info("EPUB written", "file", outPath)
+	}
+	return missing, nil
+}
+
+// AddChapter adds the HTML file of page as the next chapter of the book.
+func (e *EPUB) AddChapter(page StagedPage, file string) {
+	e.chapters = append(e.chapters, epubChapter{page: page, file: file})
+}
+
+// Identifier returns the unique identifier of the book, derived from its language and title.
+func (e *EPUB) Identifier() string {
+	h := sha256.Sum256([]byte(e.Lang + "\x00" + e.Title))
+	// format as a version 5 (name based) UUID
+	h[6] = h[6]&0x0f | 0x50
+	h[8] = h[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+// Write writes the book to filePath.
+// Links between chapters are resolved, and images linked from chapters are embedded.
+// Links to pages which aren't chapters are returned.
+func (e *EPUB) Write(filePath string) ([]MissingLink, error) {
+	info := Language(e.Lang)
+	style, err := DefaultTemplates.ReadFile(path.Join("templates", "epub.css"))
+	if err != nil {
+		return nil, fmt.Errorf("read EPUB stylesheet: %w", err)
+	}
+	var items []epubItem
+	var fontFaces strings.Builder
+	for i, font := range e.Fonts {
+		b, err := os.ReadFile(font)
+		if err != nil {
+			return nil, fmt.Errorf("read font %q: %w", font, err)
+		}
+		ext := strings.ToLower(path.Ext(font))
+		mediaType, ok := epubMediaTypes[ext]
+		if !ok || !strings.HasPrefix(mediaType, "font/") {
+			return nil, fmt.Errorf("unsupported font %q", font)
+		}
+		href := fmt.Sprintf("fonts/font-%d%s", i+1, ext)
+		items = append(items, epubItem{ID: fmt.Sprintf("font-%d", i+1), Href: href, MediaType: mediaType, content: b})
+		fmt.Fprintf(&fontFaces, "@font-face {\n    font-family: \"font-%d\";\n    src: url(\"%s\");\n}\n\n", i+1, href)
+	}
+	if len(e.Fonts) > 0 {
+		var families []string
+		for i := range e.Fonts {
+			families = append(families, fmt.Sprintf("\"font-%d\"", i+1))
+		}
+		fmt.Fprintf(&fontFaces, "body {\n    font-family: %s, serif;\n}\n\n", strings.Join(families, ", "))
+	}
+	items = append(items, epubItem{
+		ID:        "style",
+		Href:      "style.css",
+		MediaType: "text/css",
+		content:   append([]byte(fontFaces.String()), style...),
+	})
+
+	chapterFiles := map[string]string{}
+	var pages []StagedPage
+	for i, c := range e.chapters {
+		chapterFiles[c.page.Name] = fmt.Sprintf("chapter-%03d.xhtml", i+1)
+		pages = append(pages, c.page)
+	}
+	links := NewPageLinks(pages)
+	links.Files = func(page StagedPage) string { return chapterFiles[page.Name] }
+
+	images := map[string]string{} // resource file to its href in the book
+	var missing []MissingLink
+	var nav []epubNavPoint
+	var spine []string
+	for _, c := range e.chapters {
+		doc, err := readHTML(c.file)
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, links.Resolve(doc, c.page, e.Lang, false)...)
+		for _, img := range e.embedImages(doc, images) {
+			items = append(items, img)
+		}
+
+		href := chapterFiles[c.page.Name]
+		title := documentTitle(doc)
+		if title == "" {
+			title = strings.ReplaceAll(PageAnchor(c.page.Name), "-", " ")
+		}
+		point := epubNavPoint{Href: href, Title: title}
+		for _, h := range headings(doc) {
+			if h.DataAtom == atom.H2 && attr(h, "id") != "" {
+				point.Children = append(point.Children, epubNavPoint{
+					Href:  href + "#" + attr(h, "id"),
+					Title: strings.TrimSpace(textContent(h)),
+				})
+			}
+		}
+		nav = append(nav, point)
+
+		body, err := xhtmlBody(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", c.file, err)
+		}
+		// pages kept in the base language declare so
+		chapterLang := Language(e.Lang)
+		if root := findElement(doc, atom.Html); root != nil && attr(root, "lang") != "" {
+			chapterLang = Language(attr(root, "lang"))
+		}
+		var content bytes.Buffer
+		err = epubChapterTemplate.Execute(&content, map[string]any{
+			"Lang":  chapterLang,
+			"Title": title,
+			"Body":  body,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("execute chapter template: %w", err)
+		}
+		id := strings.TrimSuffix(href, ".xhtml")
+		items = append(items, epubItem{ID: id, Href: href, MediaType: "application/xhtml+xml", content: content.Bytes()})
+		spine = append(spine, id)
+	}
+
+	var front string
+	if e.Front != "" {
+		doc, err := html.Parse(strings.NewReader(e.Front))
+		if err != nil {
+			return nil, fmt.Errorf("parse front pages: %w", err)
+		}
+		for _, img := range e.embedImages(doc, images) {
+			items = append(items, img)
+		}
+		front, err = xhtmlBody(doc)
+		if err != nil {
+			return nil, fmt.Errorf("front pages: %w", err)
+		}
+	}
+
+	data := map[string]any{
+		"Lang":       info,
+		"Title":      e.Title,
+		"Front":      front,
+		"Identifier": e.Identifier(),
+		"Modified":   e.Modified.UTC().Format(time.RFC3339),
+		"Nav":        nav,
+	}
+	for _, doc := range []struct {
+		id, href string
+		tmpl     *template.Template
+		props    string
+	}{
+		{"cover", "cover.xhtml", epubCoverTemplate, ""},
+		{"nav", "nav.xhtml", epubNavTemplate, "nav"},
+	} {
+		var content bytes.Buffer
+		err = doc.tmpl.Execute(&content, data)
+		if err != nil {
+			return nil, fmt.Errorf("execute %s template: %w", doc.id, err)
+		}
+		items = append(items, epubItem{
+			ID:         doc.id,
+			Href:       doc.href,
+			MediaType:  "application/xhtml+xml",
+			Properties: doc.props,
+			content:    content.Bytes(),
+		})
+	}
+	data["Items"] = items
+	data["Spine"] = append([]string{"cover", "nav"}, spine...)
+	var opf bytes.Buffer
+	err = epubPackageTemplate.Execute(&opf, data)
+	if err != nil {
+		return nil, fmt.Errorf("execute package template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name string, content []byte, method uint16) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: e.Modified})
+		if err != nil {
+			return fmt.Errorf("create %q in EPUB: %w", name, err)
+		}
+		_, err = io.Copy(w, bytes.NewReader(content))
+		if err != nil {
+			return fmt.Errorf("write %q in EPUB: %w", name, err)
+		}
+		return nil
+	}
+	// the mimetype must be first and uncompressed, identifying the file as an EPUB
+	err = write("mimetype", []byte("application/epub+zip"), zip.Store)
+	if err != nil {
+		return nil, err
+	}
+	err = write("META-INF/container.xml", []byte(epubContainer), zip.Deflate)
+	if err != nil {
+		return nil, err
+	}
+	err = write("OEBPS/content.opf", opf.Bytes(), zip.Deflate)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		err = write("OEBPS/"+item.Href, item.content, zip.Deflate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = zw.Close()
+	if err != nil {
+		return nil, fmt.Errorf("close EPUB: %w", err)
+	}
+	err = os.WriteFile(filePath, buf.Bytes(), DefaultFilePermissions)
+	if err != nil {
+		return nil, fmt.Errorf("write %q: %w", filePath, err)
+	}
+	return missing, nil
+}
+
+// embedImages rewrites relative image sources in doc to images embedded in the book,
+// returning the items of images not already embedded.
+func (e *EPUB) embedImages(doc *html.Node, images map[string]string) []epubItem {
+	var items []epubItem
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Img {
+			for i, a := range n.Attr {
+				if a.Key != "src" || strings.Contains(a.Val, ":") || strings.HasPrefix(a.Val, "/") {
+					continue
+				}
+				name := path.Clean(a.Val)
+				if href, ok := images[name]; ok {
+					n.Attr[i].Val = href
+					continue
+				}
+				mediaType, ok := epubMediaTypes[strings.ToLower(path.Ext(name))]
+				if !ok || !strings.HasPrefix(mediaType, "image/") {
+					continue
+				}
+				b, err := os.ReadFile(path.Join(e.Resources, name))
+				if err != nil {
+					slog.Warn("image not embedded in EPUB", "src", a.Val, "error", err)
+					continue
+				}
+				href := fmt.Sprintf("images/image-%d%s", len(images)+1, strings.ToLower(path.Ext(name)))
+				items = append(items, epubItem{
+					ID:        strings.TrimSuffix(path.Base(href), path.Ext(href)),
+					Href:      href,
+					MediaType: mediaType,
+					content:   b,
+				})
+				images[name] = href
+				n.Attr[i].Val = href
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return items
+}
+
+// xhtmlBody returns the content of the body of doc as XHTML, without scripts.
+func xhtmlBody(doc *html.Node) (string, error) {
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		return "", fmt.Errorf("no body")
+	}
+	var b strings.Builder
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Script {
+			continue
+		}
+		if c.Type == html.CommentNode {
+			continue
+		}
+		// void elements are rendered self-closing, so the HTML is also well-formed XML
+		err := html.Render(&b, c)
+		if err != nil {
+			return "", fmt.Errorf("render body: %w", err)
+		}
+	}
+	return b.String(), nil
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+var epubPackageTemplate = template.Must(template.New("package").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{.Lang.Tag}}" dir="{{.Lang.Direction}}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{.Identifier}}</dc:identifier>
+    <dc:title>{{html .Title}}</dc:title>
+    <dc:language>{{.Lang.Tag}}</dc:language>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+  </metadata>
+  <manifest>
+{{- range .Items}}
+    <item id="{{.ID}}" href="{{.Href}}" media-type="{{.MediaType}}"{{with .Properties}} properties="{{.}}"{{end}}/>
+{{- end}}
+  </manifest>
+  <spine page-progression-direction="{{.Lang.Direction}}">
+{{- range .Spine}}
+    <itemref idref="{{.}}"/>
+{{- end}}
+  </spine>
+</package>
+`))
+
+var epubCoverTemplate = template.Must(template.New("cover").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{.Lang.Tag}}" lang="{{.Lang.Tag}}" dir="{{.Lang.Direction}}">
+<head>
+  <meta charset="UTF-8"/>
+  <title>{{html .Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body epub:type="frontmatter">
+{{- if .Front}}
+{{.Front}}
+{{- else}}
+  <section class="cover" epub:type="cover">
+    <h1 class="cover-title">{{html .Title}}</h1>
+  </section>
+{{- end}}
+</body>
+</html>
+`))
+
+var epubNavTemplate = template.Must(template.New("nav").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{.Lang.Tag}}" lang="{{.Lang.Tag}}" dir="{{.Lang.Direction}}">
+<head>
+  <meta charset="UTF-8"/>
+  <title>{{html .Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>{{html .Title}}</h1>
+    <ol>
+{{- range .Nav}}
+      <li><a href="{{html .Href}}">{{html .Title}}</a>
+{{- if .Children}}
+        <ol>
+{{- range .Children}}
+          <li><a href="{{html .Href}}">{{html .Title}}</a></li>
+{{- end}}
+        </ol>
+{{- end}}
+      </li>
+{{- end}}
+    </ol>
+  </nav>
+  <nav epub:type="landmarks" hidden="">
+    <ol>
+      <li><a epub:type="cover" href="cover.xhtml">{{html .Title}}</a></li>
+      <li><a epub:type="toc" href="#toc">{{html .Title}}</a></li>
+{{- with .Nav}}
+      <li><a epub:type="bodymatter" href="{{html (index . 0).Href}}">{{html (index . 0).Title}}</a></li>
+{{- end}}
+    </ol>
+  </nav>
+</body>
+</html>
+`))
+
+var epubChapterTemplate = template.Must(template.New("chapter").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{.Lang.Tag}}" lang="{{.Lang.Tag}}" dir="{{.Lang.Direction}}">
+<head>
+  <meta charset="UTF-8"/>
+  <title>{{html .Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body epub:type="bodymatter">
+{{.Body}}
+</body>
+</html>
+`))
@@ -0,0 +1,110 @@
+package illuminated
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readZip returns the content of each entry of the zip file at path, and the entries in order.
+func readZip(t *testing.T, file string) (map[string]string, []*zip.File) {
+	t.Helper()
+	r, err := zip.OpenReader(file)
+	require.NoError(t, err)
+	t.Cleanup(func() { r.Close() })
+	files := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		files[f.Name] = string(b)
+	}
+	return files, r.File
+}
+
+func TestEPUBWrite(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(dir, "logo.png"), []byte("png"), 0o644))
+	require.NoError(t, os.WriteFile(path.Join(dir, "font.ttf"), []byte("ttf"), 0o644))
+	build := func(name, content string) string {
+		file := path.Join(dir, name)
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+		return file
+	}
+
+	e := NewEPUB("fa", "راهنما")
+	e.Modified = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	e.Resources = dir
+	e.Fonts = []string{path.Join(dir, "font.ttf")}
+	e.AddChapter(StagedPage{Name: "Install.md"}, build("fa.Install.html",
+		`<html lang="fa"><head><title>نصب</title></head><body><h1 id="install">نصب</h1>`+
+			`<h2 id="run">اجرا</h2><p><a href="Usage#run">usage</a><br><img src="logo.png"></p>`+
+			`<script>alert(1)</script></body></html>`))
+	e.AddChapter(StagedPage{Name: "Usage.md"}, build("fa.Usage.html",
+		`<html lang="fa"><body><h1 id="usage">استفاده</h1><a href="Missing">missing</a></body></html>`))
+	file := path.Join(dir, "fa.epub")
+	missing, err := e.Write(file)
+	require.NoError(t, err)
+	require.Equal(t, []MissingLink{{Page: "Usage.md", Href: "Missing"}}, missing)
+
+	files, entries := readZip(t, file)
+	// the mimetype is the first entry and uncompressed
+	require.Equal(t, "mimetype", entries[0].Name)
+	require.Equal(t, zip.Store, entries[0].Method)
+	require.Equal(t, "application/epub+zip", files["mimetype"])
+	for _, name := range []string{
+		"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/cover.xhtml", "OEBPS/style.css",
+		"OEBPS/chapter-001.xhtml", "OEBPS/chapter-002.xhtml", "OEBPS/images/image-1.png", "OEBPS/fonts/font-1.ttf",
+	} {
+		require.Contains(t, files, name)
+	}
+
+	opf := files["OEBPS/content.opf"]
+	require.Contains(t, opf, `<dc:language>fa</dc:language>`)
+	require.Contains(t, opf, `<dc:title>راهنما</dc:title>`)
+	require.Contains(t, opf, `<meta property="dcterms:modified">2024-01-02T03:04:05Z</meta>`)
+	require.Contains(t, opf, `<spine page-progression-direction="rtl">`)
+	require.Contains(t, opf, `<item id="image-1" href="images/image-1.png" media-type="image/png"/>`)
+	require.Contains(t, opf, `<item id="font-1" href="fonts/font-1.ttf" media-type="font/ttf"/>`)
+	require.Contains(t, opf, `<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>`)
+	require.Contains(t, files["OEBPS/cover.xhtml"], `<h1 class="cover-title">راهنما</h1>`)
+	require.Contains(t, files["OEBPS/nav.xhtml"], `<li><a href="chapter-002.xhtml">استفاده</a>`)
+	require.Contains(t, files["OEBPS/nav.xhtml"], `<li><a href="chapter-001.xhtml#run">اجرا</a></li>`)
+	require.Contains(t, files["OEBPS/style.css"], `src: url("fonts/font-1.ttf");`)
+
+	chapter := files["OEBPS/chapter-001.xhtml"]
+	require.Contains(t, chapter, `xml:lang="fa" lang="fa" dir="rtl"`)
+	require.Contains(t, chapter, `<a href="chapter-002.xhtml#run">usage</a><br/><img src="images/image-1.png"/>`)
+	require.NotContains(t, chapter, "<script>")
+
+	// every XML document of the book is well-formed
+	for name, content := range files {
+		if path.Ext(name) == ".xhtml" || path.Ext(name) == ".opf" || path.Ext(name) == ".xml" {
+			d := xml.NewDecoder(strings.NewReader(content))
+			d.Strict = true
+			for {
+				_, err := d.Token()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err, name)
+			}
+		}
+	}
+}
+
+func TestEPUBIdentifier(t *testing.T) {
+	require.Equal(t, NewEPUB("en", "Guide").Identifier(), NewEPUB("en", "Guide").Identifier())
+	require.NotEqual(t, NewEPUB("en", "Guide").Identifier(), NewEPUB("fa", "Guide").Identifier())
+	require.Regexp(t, `^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
+		NewEPUB("en", "Guide").Identifier())
+}
@@ -0,0 +1,211 @@
+package illuminated
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"codeberg.org/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+// FontDirs are the directories searched for the fonts named in language profiles by the native PDF engine.
+var FontDirs = fontDirs()
+
+// fontDirs returns the directories fonts are installed in on Linux, macOS and Windows.
+func fontDirs() []string {
+	dirs := []string{"/usr/share/fonts", "/usr/local/share/fonts", "/Library/Fonts", "/System/Library/Fonts", `C:\Windows\Fonts`}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs,
+			filepath.Join(home, ".fonts"),
+			filepath.Join(home, ".local", "share", "fonts"),
+			filepath.Join(home, "Library", "Fonts"),
+		)
+	}
+	return dirs
+}
+
+// fontStyles are the styles fonts are registered in, as named by fpdf,
+// with the suffixes of the file names of fonts in each style.
+var fontStyles = map[string][]string{
+	"":   {"-regular", ""},
+	"B":  {"-bold"},
+	"I":  {"-italic", "-oblique"},
+	"BI": {"-bolditalic", "-boldoblique"},
+}
+
+// goFonts are the Go fonts, by style, used when fonts can't be found.
+var (
+	goFonts = map[string][]byte{
+		"": goregular.TTF, "B": gobold.TTF, "I": goitalic.TTF, "BI": gobolditalic.TTF,
+	}
+	goMonoFonts = map[string][]byte{
+		"": gomono.TTF, "B": gomonobold.TTF, "I": gomonoitalic.TTF, "BI": gomonobolditalic.TTF,
+	}
+)
+
+// fontIndex maps the file names of fonts, in lower case without extensions, to their paths.
+type fontIndex map[string]string
+
+// indexFonts finds the font files in dirs, the first found of each name kept.
+// Variable fonts, such as NotoSansSC[wght].ttf, are indexed without their axes.
+// OpenType CFF fonts and collections are indexed too, so they are reported as fonts that can't be used.
+func indexFonts(dirs []string) fontIndex {
+	index := fontIndex{}
+	for _, dir := range dirs {
+		_ = filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			ext := strings.ToLower(path.Ext(d.Name()))
+			if ext != ".ttf" && ext != ".otf" && ext != ".ttc" {
+				return nil
+			}
+			name := strings.ToLower(strings.TrimSuffix(d.Name(), path.Ext(d.Name())))
+			if i := strings.Index(name, "["); i > 0 && strings.HasSuffix(name, "]") {
+				name = name[:i]
+			}
+			if _, ok := index[name]; !ok {
+				index[name] = filePath
+			}
+			return nil
+		})
+	}
+	return index
+}
+
+// find returns the file of the font named name in style, where name is a file or the name of a font family
+// such as Noto Sans, found as NotoSans-Regular.ttf. Other styles of a file are found in its directory.
+func (x fontIndex) find(name, style string) (string, bool) {
+	if ext := path.Ext(name); ext != "" {
+		if _, err := os.Stat(name); err != nil {
+			return "", false
+		}
+		if style == "" {
+			return name, true
+		}
+		base := strings.TrimSuffix(strings.ToLower(path.Base(name)), strings.ToLower(ext))
+		base = strings.TrimSuffix(base, "-regular")
+		siblings := indexFonts([]string{path.Dir(name)})
+		for _, suffix := range fontStyles[style] {
+			if file, ok := siblings[base+suffix]; ok {
+				return file, true
+			}
+		}
+		return "", false
+	}
+	family := strings.ToLower(strings.ReplaceAll(name, " ", ""))
+	for _, suffix := range fontStyles[style] {
+		if file, ok := x[family+suffix]; ok {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// pdfFont is a font registered with a PDF in each style.
+type pdfFont struct {
+	family string     // name the font is registered by
+	face   *sfnt.Font // regular style of the font, telling which characters it has
+	buf    sfnt.Buffer
+}
+
+// has reports whether the font has a glyph for r.
+func (f *pdfFont) has(r rune) bool {
+	i, err := f.face.GlyphIndex(&f.buf, r)
+	return err == nil && i != 0
+}
+
+// readFont reads the font file at filePath, if it is a TrueType font fpdf can embed.
+func readFont(filePath string) ([]byte, *sfnt.Font, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read font: %w", err)
+	}
+	if bytes.HasPrefix(b, []byte("OTTO")) || bytes.HasPrefix(b, []byte("ttcf")) {
+		return nil, nil, fmt.Errorf("font %q is an OpenType CFF font or collection, use a TrueType font", filePath)
+	}
+	face, err := sfnt.Parse(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse font %q: %w", filePath, err)
+	}
+	return b, face, nil
+}
+
+// fontFiles are the files of a font in each style.
+type fontFiles struct {
+	name   string
+	styles map[string][]byte
+	face   *sfnt.Font // regular style of the font
+}
+
+// findFont reads the files of the font named name in each style found in index,
+// with its regular style standing in for styles not found.
+// If the font can't be found or used, the fonts of fallback are used instead, or nil returned if none.
+func findFont(index fontIndex, name string, fallback map[string][]byte) (*fontFiles, error) {
+	font := &fontFiles{name: name, styles: map[string][]byte{}}
+	if file, ok := index.find(name, ""); ok {
+		b, face, err := readFont(file)
+		if err != nil {
+			slog.Warn("font can't be used", "font", name, "error", err)
+		} else {
+			font.styles[""], font.face = b, face
+			for style := range fontStyles {
+				if style == "" {
+					continue
+				}
+				if file, ok := index.find(name, style); ok {
+					if b, _, err := readFont(file); err == nil {
+						font.styles[style] = b
+					}
+				}
+			}
+		}
+	} else if name != "" {
+		slog.Warn("font not found", "font", name, "dirs", FontDirs)
+	}
+	if font.face == nil {
+		if fallback == nil {
+			return nil, nil
+		}
+		face, err := sfnt.Parse(fallback[""])
+		if err != nil {
+			return nil, fmt.Errorf("parse Go font: %w", err)
+		}
+		font.styles, font.face = fallback, face
+	}
+	for style := range fontStyles {
+		if _, ok := font.styles[style]; !ok {
+			font.styles[style] = font.styles[""]
+		}
+	}
+	return font, nil
+}
+
+// register registers the font with pdf as family, in each style.
+func (f *fontFiles) register(pdf *fpdf.Fpdf, family string) (*pdfFont, error) {
+	for _, style := range slices.Sorted(maps.Keys(f.styles)) {
+		// fpdf writes into the bytes of fonts when subsetting them, which would change
+		// the Go fonts and fonts shared between documents, and so the output of later documents
+		pdf.AddUTF8FontFromBytes(family, style, slices.Clone(f.styles[style]))
+	}
+	if err := pdf.Error(); err != nil {
+		return nil, fmt.Errorf("add font %q: %w", f.name, err)
+	}
+	return &pdfFont{family: family, face: f.face}, nil
+}
@@ -0,0 +1,71 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestFontIndex(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"NotoSans-Regular.ttf", "NotoSans-Bold.ttf", "sub/NotoSans-Italic.ttf", "Vazirmatn.ttf", "NotoSansSC[wght].ttf", "notes.txt"} {
+		require.NoError(t, os.MkdirAll(path.Dir(path.Join(dir, name)), 0o755))
+		require.NoError(t, os.WriteFile(path.Join(dir, name), goregular.TTF, 0o644))
+	}
+	index := indexFonts([]string{dir, path.Join(dir, "missing")})
+	require.Len(t, index, 5)
+
+	for _, tc := range []struct {
+		name, style, want string
+	}{
+		{"Noto Sans", "", "NotoSans-Regular.ttf"},
+		{"Noto Sans", "B", "NotoSans-Bold.ttf"},
+		{"Noto Sans", "I", "sub/NotoSans-Italic.ttf"},
+		{"Noto Sans", "BI", ""},
+		{"Vazirmatn", "", "Vazirmatn.ttf"},
+		{"Noto Sans SC", "", "NotoSansSC[wght].ttf"}, // variable fonts
+		{"Noto Sans Arabic", "", ""},
+		// files, with their other styles beside them
+		{path.Join(dir, "NotoSans-Regular.ttf"), "", "NotoSans-Regular.ttf"},
+		{path.Join(dir, "NotoSans-Regular.ttf"), "B", "NotoSans-Bold.ttf"},
+		{path.Join(dir, "NotoSans-Regular.ttf"), "BI", ""},
+		{path.Join(dir, "NotoSans-Regular.ttf"), "I", "sub/NotoSans-Italic.ttf"},
+		{path.Join(dir, "Missing.ttf"), "", ""},
+	} {
+		file, ok := index.find(tc.name, tc.style)
+		if tc.want == "" {
+			require.False(t, ok, "%s %q", tc.name, tc.style)
+			continue
+		}
+		require.True(t, ok, "%s %q", tc.name, tc.style)
+		require.Equal(t, path.Join(dir, tc.want), file)
+	}
+}
+
+func TestFindFont(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(dir, "Go-Regular.ttf"), goregular.TTF, 0o644))
+	require.NoError(t, os.WriteFile(path.Join(dir, "Fake-Regular.otf"), []byte("OTTO not a font"), 0o644))
+	require.NoError(t, os.WriteFile(path.Join(dir, "NotoSansCJK-Regular.ttc"), []byte("ttcf not a font"), 0o644))
+	index := indexFonts([]string{dir})
+
+	font, err := findFont(index, "Go", nil)
+	require.NoError(t, err)
+	require.Equal(t, goregular.TTF, font.styles["B"]) // the regular style stands in for the others
+	require.Len(t, font.styles, 4)
+
+	// fonts not found or not TrueType are replaced by the fallback, if any
+	font, err = findFont(index, "Missing", goMonoFonts)
+	require.NoError(t, err)
+	require.Equal(t, goMonoFonts[""], font.styles[""])
+	font, err = findFont(index, "Fake", nil)
+	require.NoError(t, err)
+	require.Nil(t, font)
+	font, err = findFont(index, "Noto Sans CJK", nil)
+	require.NoError(t, err)
+	require.Nil(t, font)
+	require.Contains(t, index, "notosanscjk-regular")
+}
@@ -0,0 +1,122 @@
+package illuminated
+
+import (
+	"cmp"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"path"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"gopkg.in/yaml.v3"
+)
+
+// FrontPages describes the pages before the content of joined documents in each language:
+// a cover with the translated title, a logo, the version and the build date,
+// a notice that the document was machine translated, and a license.
+type FrontPages struct {
+	// Logo is an image on the cover, found in the sources as images linked from pages are.
+	Logo    string `yaml:"logo,omitempty"`
+	Version string `yaml:"version,omitempty"` // version of the document, shown on the cover
+	// Disclaimer is the notice of translated documents in the base language, DefaultDisclaimer if empty.
+	Disclaimer string `yaml:"disclaimer,omitempty"`
+	// License is a markdown file of the license, relative to the file the front pages are read from.
+	License string `yaml:"license,omitempty"`
+}
+
+// FrontData is the data available to the front pages template.
+type FrontData struct {
+	Lang        string        // language of the document
+	Dir         string        // text direction of the language, ltr or rtl
+	Title       string        // translated title of the document
+	Logo        string        // source of the logo image, if any
+	Version     string        // version of the document, if any
+	Date        time.Time     // date the document was built
+	Disclaimer  string        // translated notice that the document was machine translated, empty in the base language
+	License     template.HTML // license, in the base language
+	LicenseLang string        // language of the license
+	LicenseDir  string        // text direction of the license
+	Build       BuildInfo
+}
+
+// ReadFrontPages reads the description of front pages from a YAML file at filePath.
+func ReadFrontPages(filePath string) (*FrontPages, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("read front pages: %w", err)
+	}
+	var f FrontPages
+	err = yaml.Unmarshal(b, &f)
+	if err != nil {
+		return nil, fmt.Errorf("decode front pages %q: %w", filePath, err)
+	}
+	if f.License != "" && !path.IsAbs(f.License) {
+		f.License = path.Join(path.Dir(filePath), f.License)
+	}
+	slog.Debug("front pages read from file", "path", filePath)
+	return &f, nil
+}
+
+// frontPages renders the front pages of documents in lang with the front pages template,
+// or returns nothing if the documents have none.
+func (o RenderOptions) frontPages(lang string) (template.HTML, error) {
+	if o.Front == nil {
+		return "", nil
+	}
+	info := Language(lang)
+	title, err := o.translateTitle(lang, o.title())
+	if err != nil {
+		return "", err
+	}
+	data := FrontData{
+		Lang:    info.Tag,
+		Dir:     info.Direction,
+		Title:   title,
+		Logo:    o.Front.Logo,
+		Version: o.Front.Version,
+		Date:    o.Build.Date,
+		Build:   o.Build,
+	}
+	if info.Tag != Language(o.Build.BaseLang).Tag && o.Build.Translator != "" {
+		data.Disclaimer, err = o.translateTitle(lang, cmp.Or(o.Front.Disclaimer, DefaultDisclaimer))
+		if err != nil {
+			return "", err
+		}
+	}
+	if o.Front.License != "" {
+		license, err := markdownToRawHTML(o.Front.License)
+		if err != nil {
+			return "", fmt.Errorf("read license: %w", err)
+		}
+		base := Language(cmp.Or(o.Build.BaseLang, lang))
+		data.License = template.HTML(license)
+		data.LicenseLang, data.LicenseDir = base.Tag, base.Direction
+	}
+	return o.Templates.RenderFront(data)
+}
+
+// frontDigest identifies the front pages of documents, so documents are regenerated when they change.
+func (o RenderOptions) frontDigest() string {
+	if o.Front == nil {
+		return ""
+	}
+	licenseHash, _ := HashFile(o.Front.License)
+	return Digest(fmt.Sprint(*o.Front), licenseHash, o.Build.Date.String())
+}
+
+// detachFrontPages removes the front pages from doc, returning them, or nil if doc has none.
+func detachFrontPages(doc *html.Node) *html.Node {
+	var front *html.Node
+	walkElements(doc, func(n *html.Node) {
+		if front == nil && n.DataAtom == atom.Div && attr(n, "class") == frontPagesClass {
+			front = n
+		}
+	})
+	if front != nil {
+		front.Parent.RemoveChild(front)
+	}
+	return front
+}
@@ -0,0 +1,111 @@
+package illuminated
+
+import (
+	"archive/zip"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFrontPages(t *testing.T) {
+	dir := t.TempDir()
+	file := path.Join(dir, "front.yml")
+	require.NoError(t, os.WriteFile(file, []byte("logo: images/logo.png\nversion: \"2.1\"\nlicense: LICENSE.md\n"), 0o644))
+	front, err := ReadFrontPages(file)
+	require.NoError(t, err)
+	require.Equal(t, &FrontPages{Logo: "images/logo.png", Version: "2.1", License: path.Join(dir, "LICENSE.md")}, front)
+
+	_, err = ReadFrontPages(path.Join(dir, "missing.yml"))
+	require.Error(t, err)
+}
+
+func TestFrontPages(t *testing.T) {
+	_, opts := renderTestOptions(t, "fa", nil)
+	license := path.Join(opts.ProjectDir, "LICENSE.md")
+	require.NoError(t, os.WriteFile(license, []byte("# License\n\nLicensed under CC BY 4.0.\n"), 0o644))
+	opts.Front = &FrontPages{Logo: "logo.png", Version: "2.1", License: license}
+	opts.Build.Date = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	front, err := opts.frontPages("fa")
+	require.NoError(t, err)
+	html := string(front)
+	require.True(t, strings.HasPrefix(html, `<div class="front-pages">`))
+	require.Contains(t, html, `<img class="cover-logo" src="logo.png" alt="">`)
+	require.Contains(t, html, `<p class="cover-title">fa:User Guide</p>`)
+	require.Contains(t, html, `<p class="cover-version">2.1</p>`)
+	require.Contains(t, html, `<time datetime="2024-03-01">2024-03-01</time>`)
+	require.Contains(t, html, `<p>fa:`+DefaultDisclaimer+`</p>`)
+	// the license is kept in the base language
+	require.Contains(t, html, `<section class="license" lang="en" dir="ltr">`)
+	require.Contains(t, html, `<p>Licensed under CC BY 4.0.</p>`)
+
+	// documents in the base language aren't machine translated
+	front, err = opts.frontPages("en")
+	require.NoError(t, err)
+	require.NotContains(t, string(front), "disclaimer")
+
+	opts.Front = nil
+	front, err = opts.frontPages("fa")
+	require.NoError(t, err)
+	require.Empty(t, front)
+}
+
+func TestFrontPagesJoined(t *testing.T) {
+	pages := map[string]string{
+		"Home":    `<html lang="fa"><body><h1>خانه</h1><p>سلام</p></body></html>`,
+		"Install": `<html lang="fa"><body><h1>نصب</h1></body></html>`,
+	}
+	doc, opts := renderTestOptions(t, "fa", pages, "Home", "Install")
+	license := path.Join(opts.ProjectDir, "LICENSE.md")
+	require.NoError(t, os.WriteFile(license, []byte("# License\n\nLicensed under CC BY 4.0.\n"), 0o644))
+	opts.Front = &FrontPages{Logo: "logo.png", Version: "2.1", License: license}
+	opts.Join = true
+	opts.PDFEngine = PDFEngineNative
+	opts.Layout = PDFLayout{TOCDepth: 1, Cover: true}
+	logo, err := os.Create(path.Join(opts.ProjectDir, DefaultDirNameStaging, "logo.png"))
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(logo, image.NewRGBA(image.Rect(0, 0, 40, 20))))
+	require.NoError(t, logo.Close())
+
+	_, err = HTMLRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	joined, err := os.ReadFile(JoinedHTMLPath("fa", opts.ProjectDir, path.Base(opts.ProjectDir)))
+	require.NoError(t, err)
+	require.Regexp(t, `(?s)<main>\s*<div class="front-pages">.*</div>.*خانه</h1>`, string(joined))
+
+	_, err = PDFRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	b, err := os.ReadFile(path.Join(opts.outputDir(), "fa.User_Guide.pdf"))
+	require.NoError(t, err)
+	pdf := string(b)
+	// the cover, disclaimer and license, then the table of contents and a page for each chapter
+	require.Len(t, regexp.MustCompile(`/Type /Page\b`).FindAllString(pdf, -1), 6)
+	require.Contains(t, pdf, "/Subtype /Image")
+
+	_, err = EPUBRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	r, err := zip.OpenReader(path.Join(opts.outputDir(), "fa.User_Guide.epub"))
+	require.NoError(t, err)
+	defer r.Close()
+	files := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+	cover := files["OEBPS/cover.xhtml"]
+	require.Contains(t, cover, `<p class="cover-title">fa:User Guide</p>`)
+	require.Contains(t, cover, `<img class="cover-logo" src="images/image-1.png" alt=""/>`)
+	require.NotContains(t, cover, `<h1 class="cover-title">`)
+	require.Contains(t, files, "OEBPS/images/image-1.png")
+}
@@ -0,0 +1,236 @@
+package illuminated
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter is optional page metadata, defined as YAML between "---" lines
+// at the start of a markdown page.
+type FrontMatter struct {
+	// Title of the page, used in place of the document title for individual pages.
+	Title string `yaml:"title,omitempty"`
+	// Order sorts pages within their source, after the table of contents.
+	// Pages without an order have order 0, so negative values sort first.
+	Order int `yaml:"order,omitempty"`
+	// Languages, if set, limits the languages the page is generated in.
+	Languages []string `yaml:"languages,omitempty"`
+	// ExcludeLanguages lists languages the page is not generated in.
+	ExcludeLanguages []string `yaml:"exclude_languages,omitempty"`
+	// Translate set to false keeps the page in the base language for all languages.
+	Translate *bool `yaml:"translate,omitempty"`
+	// NoTranslate lists phrases which are never translated, such as product names.
+	NoTranslate []string `yaml:"notranslate,omitempty"`
+	// Overrides apply to translations of this page, in addition to the override file.
+	Overrides []Override `yaml:"overrides,omitempty"`
+	// Reviewed lists languages whose translation of the page was reviewed by a person.
+	Reviewed []string `yaml:"reviewed,omitempty"`
+}
+
+// frontMatterKeys are the recognized front matter keys.
+var frontMatterKeys = []string{
+	"title",
+	"order",
+	"languages",
+	"exclude_languages",
+	"translate",
+	"notranslate",
+	"overrides",
+	"reviewed",
+}
+
+var (
+	frontMatterDelimiter = []byte("---")
+	frontMatterEnd       = []byte("...")
+)
+
+// Includes reports whether the page should be generated in language.
+func (f FrontMatter) Includes(language string) bool {
+	if len(f.Languages) > 0 && !slices.Contains(f.Languages, language) {
+		return false
+	}
+	return !slices.Contains(f.ExcludeLanguages, language)
+}
+
+// Translated reports whether the page should be translated.
+func (f FrontMatter) Translated() bool {
+	return f.Translate == nil || *f.Translate
+}
+
+// ReviewedIn reports whether the translation of the page into language was reviewed by a person.
+func (f FrontMatter) ReviewedIn(language string) bool {
+	return slices.Contains(f.Reviewed, language)
+}
+
+// ParseFrontMatter separates front matter from the markdown body of data.
+// Data without front matter is returned unchanged with empty FrontMatter.
+func ParseFrontMatter(data []byte) (FrontMatter, []byte, error) {
+	var fm FrontMatter
+	rest := bytes.TrimPrefix(data, []byte("\ufeff")) // byte order mark
+	first, rest, ok := cutLine(rest)
+	if !ok || !bytes.Equal(bytes.TrimSpace(first), frontMatterDelimiter) {
+		return fm, data, nil
+	}
+	var raw []byte
+	for {
+		line, after, ok := cutLine(rest)
+		trimmed := bytes.TrimSpace(line)
+		if bytes.Equal(trimmed, frontMatterDelimiter) || bytes.Equal(trimmed, frontMatterEnd) {
+			rest = after
+			break
+		}
+		if !ok {
+			// no closing delimiter, so this is not front matter
+			return fm, data, nil
+		}
+		raw = append(raw, line...)
+		raw = append(raw, '\n')
+		rest = after
+	}
+
+	// a block which isn't a YAML mapping is markdown, such as a thematic break followed by a setext heading
+	var node yaml.Node
+	err := yaml.Unmarshal(raw, &node)
+	if err != nil {
+		slog.Debug("leading block is not YAML, so not front matter", "error", err)
+		return fm, data, nil
+	}
+	if len(node.Content) == 0 {
+		return fm, rest, nil
+	}
+	mapping := node.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		slog.Debug("leading block is not a YAML mapping, so not front matter", "tag", mapping.Tag)
+		return fm, data, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key := mapping.Content[i].Value
+		if !slices.Contains(frontMatterKeys, key) {
+			slog.Debug("ignoring unknown front matter key", "key", key)
+		}
+	}
+	err = mapping.Decode(&fm)
+	if err != nil {
+		return fm, data, fmt.Errorf("decode front matter: %w", err)
+	}
+	return fm, rest, nil
+}
+
+// ReadFrontMatter reads the front matter of the markdown file at path.
+func ReadFrontMatter(path string) (FrontMatter, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return FrontMatter{}, fmt.Errorf("read file %q: %w", path, err)
+	}
+	fm, _, err := ParseFrontMatter(b)
+	if err != nil {
+		return FrontMatter{}, fmt.Errorf("%q: %w", path, err)
+	}
+	return fm, nil
+}
+
+// cutLine returns the first line of b, without its line ending, and the remainder.
+// ok is false if b has no line ending.
+func cutLine(b []byte) (line, rest []byte, ok bool) {
+	line, rest, ok = bytes.Cut(b, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r")), rest, ok
+}
+
+// ProtectPhrases marks each occurrence of phrases in the text of an HTML document
+// so translators leave them untranslated.
+func ProtectPhrases(doc string, phrases []string) (string, error) {
+	if len(phrases) == 0 {
+		return doc, nil
+	}
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		return "", fmt.Errorf("parse HTML: %w", err)
+	}
+	// match longer phrases first, so they aren't split by shorter phrases they contain
+	phrases = slices.Clone(phrases)
+	slices.SortFunc(phrases, func(a, b string) int { return len(b) - len(a) })
+
+	var protect func(*html.Node)
+	protect = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style" || noTranslate(n)) {
+			return
+		}
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			if c.Type == html.TextNode {
+				splitPhrases(c, phrases)
+			} else {
+				protect(c)
+			}
+			c = next
+		}
+	}
+	protect(root)
+
+	var b strings.Builder
+	err = html.Render(&b, root)
+	if err != nil {
+		return "", fmt.Errorf("render HTML: %w", err)
+	}
+	return b.String(), nil
+}
+
+// splitPhrases replaces text node n with text and untranslated elements for each phrase found.
+func splitPhrases(n *html.Node, phrases []string) {
+	text := n.Data
+	for text != "" {
+		index, phrase := -1, ""
+		for _, p := range phrases {
+			if p == "" {
+				continue
+			}
+			i := strings.Index(text, p)
+			if i >= 0 && (index < 0 || i < index) {
+				index, phrase = i, p
+			}
+		}
+		if index < 0 {
+			break
+		}
+		if index > 0 {
+			n.Parent.InsertBefore(&html.Node{Type: html.TextNode, Data: text[:index]}, n)
+		}
+		span := &html.Node{
+			Type:     html.ElementNode,
+			Data:     "span",
+			DataAtom: atom.Span,
+			Attr: []html.Attribute{
+				{Key: "class", Val: "notranslate"},
+				{Key: "translate", Val: "no"},
+			},
+		}
+		span.AppendChild(&html.Node{Type: html.TextNode, Data: phrase})
+		n.Parent.InsertBefore(span, n)
+		text = text[index+len(phrase):]
+	}
+	n.Data = text
+	if text == "" {
+		n.Parent.RemoveChild(n)
+	}
+}
+
+// noTranslate reports whether element n is marked to be left untranslated.
+func noTranslate(n *html.Node) bool {
+	for _, a := range n.Attr {
+		if a.Key == "translate" && a.Val == "no" {
+			return true
+		}
+		if a.Key == "class" && slices.Contains(strings.Fields(a.Val), "notranslate") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package illuminated
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	fm, body, err := ParseFrontMatter([]byte(`---
+title: Install Lantern
+order: -1
+languages: [en, zh, fa]
+exclude_languages: [fa]
+translate: false
+notranslate: [Lantern]
+overrides:
+  - language: zh
+    original: 灯笼
+    replacement: 蓝灯
+reviewed: [zh]
+layout: unknown
+---
+# Install
+`))
+	require.NoError(t, err)
+	require.Equal(t, "# Install\n", string(body))
+	require.Equal(t, "Install Lantern", fm.Title)
+	require.Equal(t, -1, fm.Order)
+	require.True(t, fm.Includes("zh"))
+	require.False(t, fm.Includes("fa"), "excluded language")
+	require.False(t, fm.Includes("ru"), "language not included")
+	require.False(t, fm.Translated())
+	require.Equal(t, []string{"Lantern"}, fm.NoTranslate)
+	require.Len(t, fm.Overrides, 1)
+	require.Equal(t, "蓝灯", fm.Overrides[0].Replacement)
+	require.True(t, fm.ReviewedIn("zh"))
+	require.False(t, fm.ReviewedIn("fa"))
+}
+
+func TestParseFrontMatterAbsent(t *testing.T) {
+	for _, doc := range []string{
+		"# Hello World",
+		"---\n# not closed",
+		"",
+		// a thematic break followed by a setext heading
+		"---\nIntroduction\n---\nWelcome to the guide.\n",
+		"---\n- a list\n---\n# Hello",
+		// not valid YAML
+		"---\nkey: [unclosed\n---\n# Hello",
+		"---\n\tindented: tab\n---\n",
+	} {
+		fm, body, err := ParseFrontMatter([]byte(doc))
+		require.NoError(t, err)
+		require.Equal(t, doc, string(body))
+		require.Equal(t, FrontMatter{}, fm)
+		require.True(t, fm.Includes("en"))
+		require.True(t, fm.Translated())
+	}
+}
+
+func TestParseFrontMatterInvalid(t *testing.T) {
+	// front matter with fields of the wrong type
+	_, _, err := ParseFrontMatter([]byte("---\norder: first\n---\n# Hello"))
+	require.Error(t, err)
+}
+
+func TestProtectPhrases(t *testing.T) {
+	doc, err := ProtectPhrases(
+		"<html><head></head><body><p>Lantern Pro is Lantern</p><code>Lantern</code></body></html>",
+		[]string{"Lantern", "Lantern Pro"},
+	)
+	require.NoError(t, err)
+	require.Contains(t, doc,
+		`<p><span class="notranslate" translate="no">Lantern Pro</span> is <span class="notranslate" translate="no">Lantern</span></p>`,
+	)
+}
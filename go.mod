@@ -4,13 +4,16 @@ go 1.24.2
 
 require (
 	cloud.google.com/go/translate v1.12.6
+	codeberg.org/go-pdf/fpdf v0.11.1
 	github.com/go-git/go-git/v5 v5.16.2
 	github.com/manifoldco/promptui v0.9.0
-	github.com/russross/blackfriday/v2 v2.1.0
 	github.com/spf13/cobra v1.9.1
 	github.com/stretchr/testify v1.10.0
+	github.com/yuin/goldmark v1.7.13
+	golang.org/x/image v0.25.0
 	golang.org/x/net v0.41.0
 	golang.org/x/text v0.26.0
+	google.golang.org/api v0.237.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -54,7 +57,6 @@ require (
 	golang.org/x/crypto v0.39.0 // indirect
 	golang.org/x/oauth2 v0.30.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
-	google.golang.org/api v0.237.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250603155806-513f23925822 // indirect
 	google.golang.org/grpc v1.73.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
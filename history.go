@@ -0,0 +1,244 @@
+package illuminated
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// TranslationStatus describes whether a translated page reflects its source.
+type TranslationStatus string
+
+const (
+	StatusUpToDate TranslationStatus = "up to date"
+	StatusStale    TranslationStatus = "stale"
+	StatusMissing  TranslationStatus = "missing"
+)
+
+// PageStatus is the translation status of a single staged page in a single language.
+type PageStatus struct {
+	Language string
+	Page     string
+	Status   TranslationStatus
+	// Commits lists the commits which modified a stale page since it was translated,
+	// newest first. It is empty when the staged source has no git history.
+	Commits []*object.Commit
+}
+
+// History provides the commit history of a staged git repository.
+type History struct {
+	repo *git.Repository
+}
+
+// OpenHistory opens the git repository at dir.
+// A nil History is returned without error if dir is not a git repository,
+// such as when staged from an archive.
+func OpenHistory(dir string) (*History, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		if errors.Is(err, git.ErrRepositoryNotExists) {
+			slog.Debug("no git history available", "dir", dir)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open repository %q: %w", dir, err)
+	}
+	return &History{repo: repo}, nil
+}
+
+// LastCommit returns the hash of the most recent commit which modified file,
+// or an empty string if there is no history.
+func (h *History) LastCommit(file string) (string, error) {
+	if h == nil {
+		return "", nil
+	}
+	iter, err := h.repo.Log(&git.LogOptions{FileName: &file})
+	if err != nil {
+		return "", fmt.Errorf("log %q: %w", file, err)
+	}
+	defer iter.Close()
+	c, err := iter.Next()
+	if err != nil {
+		if err == io.EOF {
+			return "", nil
+		}
+		return "", fmt.Errorf("read log %q: %w", file, err)
+	}
+	return c.Hash.String(), nil
+}
+
+// Head returns the hash of the commit checked out in the repository,
+// or an empty string if there is no history.
+func (h *History) Head() (string, error) {
+	if h == nil {
+		return "", nil
+	}
+	ref, err := h.repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("read HEAD: %w", err)
+	}
+	return ref.Hash().String(), nil
+}
+
+// HeadTime returns the time of the commit checked out in the repository,
+// or the zero time if there is no history.
+func (h *History) HeadTime() (time.Time, error) {
+	if h == nil {
+		return time.Time{}, nil
+	}
+	ref, err := h.repo.Head()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("read HEAD: %w", err)
+	}
+	c, err := h.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("read commit %s: %w", ref.Hash(), err)
+	}
+	return c.Committer.When, nil
+}
+
+// CommitsSince returns the commits which modified file after the given commit, newest first.
+// If commit is not found in the history, all commits which modified file are returned.
+func (h *History) CommitsSince(file string, commit string) ([]*object.Commit, error) {
+	if h == nil {
+		return nil, nil
+	}
+	iter, err := h.repo.Log(&git.LogOptions{FileName: &file})
+	if err != nil {
+		return nil, fmt.Errorf("log %q: %w", file, err)
+	}
+	defer iter.Close()
+	var commits []*object.Commit
+	for {
+		c, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read log %q: %w", file, err)
+		}
+		if c.Hash.String() == commit {
+			break
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// RecordTranslation notes that page was translated into language from its current staged source,
+// unless the same source was already recorded.
+func (s *BuildState) RecordTranslation(language string, page StagedPage) error {
+	source, ok := s.Sources[page.Name]
+	if !ok {
+		return fmt.Errorf("page %q has not been staged", page.Name)
+	}
+	if s.Translations[language] == nil {
+		s.Translations[language] = map[string]Translation{}
+	}
+	if t, ok := s.Translations[language][page.Name]; ok && t.Source == source {
+		return nil
+	}
+	history, err := s.history(page.Origin)
+	if err != nil {
+		return err
+	}
+	commit, err := history.LastCommit(page.File)
+	if err != nil {
+		return fmt.Errorf("find source commit: %w", err)
+	}
+	s.Translations[language][page.Name] = Translation{
+		Source: source,
+		Commit: commit,
+		Origin: page.Origin,
+		File:   page.File,
+	}
+	return nil
+}
+
+// history returns the History of the directory a page was staged from,
+// opening each repository once.
+func (s *BuildState) history(origin string) (*History, error) {
+	if s.histories == nil {
+		s.histories = map[string]*History{}
+	}
+	if h, ok := s.histories[origin]; ok {
+		return h, nil
+	}
+	if origin == "" {
+		return nil, nil
+	}
+	h, err := OpenHistory(origin)
+	if err != nil {
+		return nil, fmt.Errorf("open history of %q: %w", origin, err)
+	}
+	s.histories[origin] = h
+	return h, nil
+}
+
+// Status returns the translation status of every staged page for each language.
+// Staged pages are compared to the sources they were last translated from.
+// If no languages are given, all previously translated languages are used.
+func Status(projectDir string, languages []string) ([]PageStatus, error) {
+	state, err := ReadBuildState(projectDir)
+	if err != nil {
+		return nil, fmt.Errorf("read build state: %w", err)
+	}
+	if len(languages) == 0 {
+		for lang := range state.Translations {
+			languages = append(languages, lang)
+		}
+		sort.Strings(languages)
+	}
+	stagingDir := path.Join(projectDir, DefaultDirNameStaging)
+	files, err := os.ReadDir(stagingDir)
+	if err != nil {
+		return nil, fmt.Errorf("read staging directory: %w", err)
+	}
+
+	var statuses []PageStatus
+	for _, lang := range languages {
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), ".md") {
+				continue
+			}
+			page := file.Name()
+			status := PageStatus{Language: lang, Page: page}
+			t, ok := state.Translations[lang][page]
+			if !ok {
+				status.Status = StatusMissing
+				statuses = append(statuses, status)
+				continue
+			}
+			source, err := HashFile(path.Join(stagingDir, page))
+			if err != nil {
+				return nil, fmt.Errorf("hash staged page: %w", err)
+			}
+			if source == t.Source {
+				status.Status = StatusUpToDate
+				statuses = append(statuses, status)
+				continue
+			}
+			status.Status = StatusStale
+			if t.Commit != "" {
+				history, err := state.history(t.Origin)
+				if err != nil {
+					return nil, err
+				}
+				status.Commits, err = history.CommitsSince(t.File, t.Commit)
+				if err != nil {
+					return nil, fmt.Errorf("find commits since translation of %q: %w", page, err)
+				}
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,73 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+)
+
+// commitFile writes content to name in the repository worktree and commits it.
+func commitFile(t *testing.T, repo *git.Repository, dir, name, content string) {
+	t.Helper()
+	err := os.WriteFile(path.Join(dir, name), []byte(content), 0o644)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = wt.Add(name)
+	require.NoError(t, err)
+	_, err = wt.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+}
+
+func TestStatus(t *testing.T) {
+	projectDir := t.TempDir()
+	stagingDir := path.Join(projectDir, DefaultDirNameStaging)
+	repo, err := git.PlainInit(stagingDir, false)
+	require.NoError(t, err)
+	commitFile(t, repo, stagingDir, "Install.md", "# Install")
+	commitFile(t, repo, stagingDir, "Usage.md", "# Usage")
+
+	state, err := ReadBuildState(projectDir)
+	require.NoError(t, err)
+	for _, page := range []string{"Install.md", "Usage.md"} {
+		state.Sources[page], err = HashFile(path.Join(stagingDir, page))
+		require.NoError(t, err)
+	}
+	// only Install is translated into zh
+	err = state.RecordTranslation("zh", StagedPage{Name: "Install.md", Origin: stagingDir, File: "Install.md"})
+	require.NoError(t, err)
+	err = state.Write()
+	require.NoError(t, err)
+
+	statuses, err := Status(projectDir, []string{"zh"})
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.Equal(t, StatusUpToDate, statuses[0].Status)
+	require.Equal(t, StatusMissing, statuses[1].Status)
+
+	commitFile(t, repo, stagingDir, "Install.md", "# Install\n\nStep one.")
+	commitFile(t, repo, stagingDir, "Install.md", "# Install\n\nStep one, two.")
+
+	statuses, err = Status(projectDir, nil)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.Equal(t, "zh", statuses[0].Language)
+	require.Equal(t, StatusStale, statuses[0].Status)
+	require.Len(t, statuses[0].Commits, 2)
+}
+
+func TestOpenHistoryNotRepository(t *testing.T) {
+	history, err := OpenHistory(t.TempDir())
+	require.NoError(t, err)
+	require.Nil(t, history)
+	commit, err := history.LastCommit("Install.md")
+	require.NoError(t, err)
+	require.Empty(t, commit)
+}
@@ -0,0 +1,93 @@
+package illuminated
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// LanguageInfo describes how a language is written.
+type LanguageInfo struct {
+	Tag       string // canonical BCP 47 tag, such as zh-TW
+	Name      string // name of the language in itself, such as 繁體中文
+	Script    string // ISO 15924 script code, such as Hant
+	Direction string // text direction, ltr or rtl
+}
+
+// rtlScripts are the ISO 15924 scripts written right to left.
+var rtlScripts = map[string]bool{
+	"Adlm": true, // Adlam
+	"Arab": true, // Arabic
+	"Aran": true, // Arabic (Nastaliq)
+	"Armi": true, // Imperial Aramaic
+	"Avst": true, // Avestan
+	"Chrs": true, // Chorasmian
+	"Cprt": true, // Cypriot
+	"Elym": true, // Elymaic
+	"Hatr": true, // Hatran
+	"Hebr": true, // Hebrew
+	"Khar": true, // Kharoshthi
+	"Lydi": true, // Lydian
+	"Mand": true, // Mandaic
+	"Mani": true, // Manichaean
+	"Mend": true, // Mende Kikakui
+	"Narb": true, // Old North Arabian
+	"Nbat": true, // Nabataean
+	"Nkoo": true, // N'Ko
+	"Palm": true, // Palmyrene
+	"Phli": true, // Inscriptional Pahlavi
+	"Phlp": true, // Psalter Pahlavi
+	"Phnx": true, // Phoenician
+	"Prti": true, // Inscriptional Parthian
+	"Rohg": true, // Hanifi Rohingya
+	"Samr": true, // Samaritan
+	"Sarb": true, // Old South Arabian
+	"Sogd": true, // Sogdian
+	"Sogo": true, // Old Sogdian
+	"Syrc": true, // Syriac
+	"Thaa": true, // Thaana
+	"Yezi": true, // Yezidi
+}
+
+// Language returns how the language with BCP 47 tag lang is written,
+// using its script if given or otherwise the most likely script of the language.
+// Unknown languages are assumed to be written left to right.
+func Language(lang string) LanguageInfo {
+	info := LanguageInfo{Tag: lang, Direction: "ltr"}
+	tag, err := language.Parse(strings.ReplaceAll(lang, "_", "-"))
+	if err != nil {
+		slog.Debug("unknown language, assuming left to right", "lang", lang, "error", err)
+		return info
+	}
+	script, _ := tag.Script()
+	info.Tag = tag.String()
+	info.Name = display.Self.Name(tag)
+	info.Script = script.String()
+	if rtlScripts[info.Script] {
+		info.Direction = "rtl"
+	}
+	return info
+}
+
+// SetLanguage declares the language of an HTML document as lang,
+// setting the lang and dir attributes of its root element.
+func SetLanguage(doc string, lang string) (string, error) {
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		return "", fmt.Errorf("parse HTML: %w", err)
+	}
+	err = declareLanguage(lang)(root)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = html.Render(&b, root)
+	if err != nil {
+		return "", fmt.Errorf("render HTML: %w", err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,37 @@
+package illuminated
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanguage(t *testing.T) {
+	for _, tc := range []struct {
+		lang, tag, script, direction string
+	}{
+		{"en", "en", "Latn", "ltr"},
+		{"fa", "fa", "Arab", "rtl"},
+		{"ar", "ar", "Arab", "rtl"},
+		{"he", "he", "Hebr", "rtl"},
+		{"ur", "ur", "Arab", "rtl"},
+		{"dv", "dv", "Thaa", "rtl"},
+		{"zh", "zh", "Hans", "ltr"},
+		{"zh_tw", "zh-TW", "Hant", "ltr"},
+		{"ru", "ru", "Cyrl", "ltr"},
+		{"az-Arab", "az-Arab", "Arab", "rtl"},
+		{"not a language", "not a language", "", "ltr"},
+	} {
+		info := Language(tc.lang)
+		require.Equal(t, tc.tag, info.Tag, tc.lang)
+		require.Equal(t, tc.script, info.Script, tc.lang)
+		require.Equal(t, tc.direction, info.Direction, tc.lang)
+	}
+	require.Equal(t, "فارسی", Language("fa").Name)
+}
+
+func TestSetLanguage(t *testing.T) {
+	doc, err := SetLanguage(`<html lang="en" dir="ltr"><head></head><body><p>سلام</p></body></html>`, "fa")
+	require.NoError(t, err)
+	require.Contains(t, doc, `<html lang="fa" dir="rtl">`)
+}
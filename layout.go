@@ -0,0 +1,117 @@
+package illuminated
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// FilterFileChapters is the pandoc Lua filter starting each chapter of a PDF, DOCX or ODT document on a new page.
+const FilterFileChapters = "chapters.lua"
+
+// PDFLayout describes the page layout of PDF output.
+type PDFLayout struct {
+	Paper          string // paper size, such as a4 or letter
+	Margins        string // page margins, such as 2cm
+	TOCDepth       int    // deepest heading level in the table of contents
+	NumberSections bool   // number sections, such as 1.2
+	// Header and Footer are text repeated on each page, where {page} is the page number.
+	Header string
+	Footer string
+	// Cover places the title on a page of its own, with CoverImage if set.
+	Cover      bool
+	CoverImage string
+}
+
+// pandocArgs returns the pandoc arguments laying out a PDF, applying the Lua filter at filter.
+// Files the arguments refer to are written to dir.
+func (l PDFLayout) pandocArgs(filter, dir string) ([]string, error) {
+	args := []string{"--lua-filter", filter, "--toc", "--toc-depth", fmt.Sprint(l.TOCDepth)}
+	if l.TOCDepth <= 0 {
+		args = []string{"--lua-filter", filter}
+	}
+	variable := func(key, value string) {
+		args = append(args, "--variable", fmt.Sprintf("%s=%s", key, value))
+	}
+	if l.Paper != "" {
+		variable("papersize", l.Paper)
+	}
+	if l.Margins != "" {
+		variable("geometry", "margin="+l.Margins)
+	}
+	if l.NumberSections {
+		args = append(args, "--number-sections")
+	}
+	if l.Header != "" || l.Footer != "" {
+		// fancyhdr replaces the default page number in the footer, so it's kept unless replaced
+		footer := l.Footer
+		if footer == "" {
+			footer = "{page}"
+		}
+		var b strings.Builder
+		b.WriteString(`\usepackage{fancyhdr}\pagestyle{fancy}\fancyhf{}`)
+		if l.Header != "" {
+			fmt.Fprintf(&b, `\fancyhead[C]{%s}`, pageText(l.Header))
+		} else {
+			b.WriteString(`\renewcommand{\headrulewidth}{0pt}`)
+		}
+		fmt.Fprintf(&b, `\fancyfoot[C]{%s}`, pageText(footer))
+		// chapter pages use the plain style, so they get the header and footer too
+		b.WriteString(`\fancypagestyle{plain}{\pagestyle{fancy}}`)
+		variable("header-includes", b.String())
+	}
+	if l.Cover {
+		variable("classoption", "titlepage")
+		if l.CoverImage != "" {
+			image, err := os.ReadFile(l.CoverImage)
+			if err != nil {
+				return nil, fmt.Errorf("read cover image: %w", err)
+			}
+			// the image is copied, as LaTeX paths can't contain every character a file name can
+			coverPath := path.Join(dir, "cover"+strings.ToLower(path.Ext(l.CoverImage)))
+			err = os.WriteFile(coverPath, image, DefaultFilePermissions)
+			if err != nil {
+				return nil, fmt.Errorf("write cover image: %w", err)
+			}
+			coverTeX := path.Join(dir, "cover.tex")
+			cover := fmt.Sprintf(
+				"\\begin{titlepage}\\centering\\vspace*{\\fill}\n"+
+					"\\includegraphics[width=\\textwidth,height=0.8\\textheight,keepaspectratio]{%s}\n"+
+					"\\vspace*{\\fill}\\end{titlepage}\n",
+				coverPath,
+			)
+			err = os.WriteFile(coverTeX, []byte(cover), DefaultFilePermissions)
+			if err != nil {
+				return nil, fmt.Errorf("write cover page: %w", err)
+			}
+			args = append(args, "--include-before-body", coverTeX)
+		}
+	}
+	return args, nil
+}
+
+// pageText returns text for a page header or footer as LaTeX, with {page} replaced by the page number.
+func pageText(text string) string {
+	parts := strings.Split(text, "{page}")
+	for i, part := range parts {
+		parts[i] = escapeLaTeX(part)
+	}
+	return strings.Join(parts, `\thepage{}`)
+}
+
+// escapeLaTeX escapes the characters of text with special meanings in LaTeX.
+func escapeLaTeX(text string) string {
+	return strings.NewReplacer(
+		`\`, `\textbackslash{}`,
+		`{`, `\{`,
+		`}`, `\}`,
+		`$`, `\$`,
+		`&`, `\&`,
+		`#`, `\#`,
+		`%`, `\%`,
+		`_`, `\_`,
+		`^`, `\textasciicircum{}`,
+		`~`, `\textasciitilde{}`,
+	).Replace(text)
+}
@@ -0,0 +1,63 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPDFLayoutArgs(t *testing.T) {
+	dir := t.TempDir()
+	args, err := PDFLayout{TOCDepth: 2}.pandocArgs("chapters.lua", dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"--lua-filter", "chapters.lua", "--toc", "--toc-depth", "2"}, args)
+
+	args, err = PDFLayout{}.pandocArgs("chapters.lua", dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"--lua-filter", "chapters.lua"}, args)
+
+	image := path.Join(dir, "Cover Art.PNG")
+	require.NoError(t, os.WriteFile(image, []byte("png"), 0o644))
+	args, err = PDFLayout{
+		Paper:          "a4",
+		Margins:        "2cm",
+		TOCDepth:       3,
+		NumberSections: true,
+		Header:         "User Guide & FAQ",
+		Cover:          true,
+		CoverImage:     image,
+	}.pandocArgs("chapters.lua", dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"--lua-filter", "chapters.lua", "--toc", "--toc-depth", "3",
+		"--variable", "papersize=a4",
+		"--variable", "geometry=margin=2cm",
+		"--number-sections",
+		"--variable", `header-includes=\usepackage{fancyhdr}\pagestyle{fancy}\fancyhf{}` +
+			`\fancyhead[C]{User Guide \& FAQ}\fancyfoot[C]{\thepage{}}\fancypagestyle{plain}{\pagestyle{fancy}}`,
+		"--variable", "classoption=titlepage",
+		"--include-before-body", path.Join(dir, "cover.tex"),
+	}, args)
+	require.FileExists(t, path.Join(dir, "cover.png"))
+	cover, err := os.ReadFile(path.Join(dir, "cover.tex"))
+	require.NoError(t, err)
+	require.Contains(t, string(cover), path.Join(dir, "cover.png"))
+}
+
+func TestPageText(t *testing.T) {
+	require.Equal(t, `Page \thepage{} of 100\% \{draft\}`, pageText("Page {page} of 100% {draft}"))
+	require.Equal(t, `C:\textbackslash{}docs\_v1 \$5 \textasciitilde{}`, pageText(`C:\docs_v1 $5 ~`))
+}
+
+func TestChaptersFilter(t *testing.T) {
+	filter, err := DefaultTemplates.ReadFile(path.Join("templates", FilterFileChapters))
+	require.NoError(t, err)
+	require.Contains(t, string(filter), `\\clearpage`)
+	require.Contains(t, string(filter), `<w:br w:type="page"/>`)
+	require.Contains(t, string(filter), `text:style-name="Pagebreak"`)
+	// front pages are moved before the table of contents
+	require.Contains(t, string(filter), `'include-before'`)
+	require.Contains(t, string(filter), `'front-pages'`)
+}
@@ -0,0 +1,339 @@
+package illuminated
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// reWikiLink matches GitHub wiki links, [[Page Name]] or [[Link Text|Page Name]].
+var reWikiLink = regexp.MustCompile(`\[\[([^\[\]]+)\]\]`)
+
+// wikiLinksToMarkdown rewrites GitHub wiki links as markdown links,
+// or images when linking to an image, leaving code untouched.
+func wikiLinksToMarkdown(md []byte) []byte {
+	var out bytes.Buffer
+	var fence string
+	for _, line := range bytes.SplitAfter(md, []byte("\n")) {
+		trimmed := strings.TrimLeft(string(line), " ")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			out.Write(line)
+			continue
+		}
+		if len(string(line))-len(trimmed) < 4 {
+			for _, f := range []string{"```", "~~~"} {
+				if strings.HasPrefix(trimmed, f) {
+					fence = f
+				}
+			}
+		}
+		if fence != "" {
+			out.Write(line)
+			continue
+		}
+		out.WriteString(replaceOutsideCode(string(line), replaceWikiLinks))
+	}
+	return out.Bytes()
+}
+
+// replaceOutsideCode applies replace to the parts of a line outside of inline code spans.
+func replaceOutsideCode(line string, replace func(string) string) string {
+	var out strings.Builder
+	for {
+		start := strings.Index(line, "`")
+		if start < 0 {
+			out.WriteString(replace(line))
+			return out.String()
+		}
+		n := len(line[start:]) - len(strings.TrimLeft(line[start:], "`"))
+		ticks := line[start : start+n]
+		end := strings.Index(line[start+n:], ticks)
+		if end < 0 {
+			out.WriteString(replace(line))
+			return out.String()
+		}
+		end += start + n + n
+		out.WriteString(replace(line[:start]))
+		out.WriteString(line[start:end])
+		line = line[end:]
+	}
+}
+
+// imageExtensions lists the extensions of resources which wiki links embed as images, rather than link to.
+var imageExtensions = map[string]struct{}{
+	".png": {}, ".jpg": {}, ".jpeg": {}, ".gif": {}, ".svg": {}, ".webp": {},
+}
+
+// replaceWikiLinks replaces each wiki link in text with a markdown link or image.
+// Images are written as GitHub does, [[image.png]] or [[image.png|alt=Alt Text]],
+// and links to other resources, such as [[Download|lantern.apk]], are kept as plain links.
+func replaceWikiLinks(text string) string {
+	return reWikiLink.ReplaceAllStringFunc(text, func(match string) string {
+		inner := strings.TrimSuffix(strings.TrimPrefix(match, "[["), "]]")
+		label, target, hasLabel := strings.Cut(inner, "|")
+		if !hasLabel {
+			label, target = inner, inner
+		}
+		label, target = strings.TrimSpace(label), strings.TrimSpace(target)
+		if alt, ok := strings.CutPrefix(target, "alt="); hasLabel && ok {
+			// the image comes first, followed by its alt text
+			label, target = strings.TrimSpace(alt), label
+		} else if !hasLabel {
+			label = ""
+		}
+		ext := strings.ToLower(path.Ext(target))
+		if _, ok := imageExtensions[ext]; ok {
+			return fmt.Sprintf("![%s](%s)", label, (&url.URL{Path: target}).String())
+		}
+		if _, ok := resourceExtensions[ext]; ok {
+			return fmt.Sprintf("[%s](%s)", cmp.Or(label, target), (&url.URL{Path: target}).String())
+		}
+		page, fragment, _ := strings.Cut(target, "#")
+		href := (&url.URL{
+			Path:     strings.ReplaceAll(strings.TrimSpace(page), " ", "-"),
+			Fragment: fragment,
+		}).String()
+		return fmt.Sprintf("[%s](%s)", cmp.Or(label, target), href)
+	})
+}
+
+// PageLinks resolves links between staged pages to the files generated for them.
+type PageLinks struct {
+	// Files, if set, returns the file name of each page, linked in the same directory,
+	// rather than <lang>.<page>.html.
+	Files func(page StagedPage) string
+
+	pages map[string]StagedPage        // normalized page name to page
+	ids   map[string]map[string]string // page name to element IDs renamed when joined
+	used  map[string]bool              // element IDs used in the joined document
+}
+
+// MissingLink is a link from a page to another page which does not exist.
+type MissingLink struct {
+	Page string // staged name of the page containing the link
+	Href string
+}
+
+// NewPageLinks returns a PageLinks resolving links between the given pages.
+func NewPageLinks(pages []StagedPage) *PageLinks {
+	l := &PageLinks{
+		pages: map[string]StagedPage{},
+		ids:   map[string]map[string]string{},
+		used:  map[string]bool{},
+	}
+	for _, page := range pages {
+		l.pages[normalizePage(page.Name)] = page
+	}
+	return l
+}
+
+// ReserveIDs reserves the element IDs of pages in the joined document, reading the HTML of each page
+// from the file of the same index in srcs. Page anchors are reserved first, then IDs already used
+// by earlier pages are renamed with a numeric suffix. IDs are the same for every language
+// as long as the same pages are reserved in the same order from the same (base language) HTML.
+func (l *PageLinks) ReserveIDs(pages []StagedPage, srcs []string) error {
+	if len(pages) != len(srcs) {
+		return fmt.Errorf("reserve IDs: %d pages but %d files", len(pages), len(srcs))
+	}
+	for _, page := range pages {
+		l.used[PageAnchor(page.Name)] = true
+	}
+	for i, page := range pages {
+		doc, err := readHTML(srcs[i])
+		if err != nil {
+			return err
+		}
+		renamed := map[string]string{}
+		seen := map[string]bool{}
+		for _, id := range elementIDs(doc) {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			unique := uniqueID(id, l.reserved)
+			l.used[unique] = true
+			if unique != id {
+				renamed[id] = unique
+			}
+		}
+		l.ids[page.Name] = renamed
+	}
+	return nil
+}
+
+// reserved reports whether id is reserved in the joined document, by ReserveIDs or as a page anchor.
+// Nothing is reserved by nil PageLinks.
+func (l *PageLinks) reserved(id string) bool {
+	return l != nil && l.used[id]
+}
+
+// uniqueID returns id or, if it is taken, id with the first numeric suffix which isn't.
+// Element IDs of joined documents are allocated this way by both ReserveIDs and JoinHTML.
+func uniqueID(id string, taken func(string) bool) string {
+	unique := id
+	for n := 1; taken(unique); n++ {
+		unique = fmt.Sprintf("%s-%d", id, n)
+	}
+	return unique
+}
+
+// id returns the ID in the joined document of element id in page.
+func (l *PageLinks) id(page StagedPage, id string) string {
+	if renamed, ok := l.ids[page.Name][id]; ok {
+		return renamed
+	}
+	return id
+}
+
+// elementIDs returns the IDs of elements in doc, in document order.
+func elementIDs(doc *html.Node) []string {
+	var ids []string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for _, a := range n.Attr {
+				if a.Key == "id" && a.Val != "" {
+					ids = append(ids, a.Val)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return ids
+}
+
+// PageAnchor returns the ID of the element marking the start of a page in joined documents.
+func PageAnchor(page string) string {
+	return strings.TrimSuffix(page, ".md")
+}
+
+// target returns the page linked by href from page from.
+// ok is false if href is not a link to a page, such as external links and images,
+// and page is empty if href links to a page which doesn't exist.
+func (l *PageLinks) target(href string, from StagedPage) (page StagedPage, fragment string, ok bool) {
+	name := hrefPage(href)
+	if name == "" {
+		return StagedPage{}, "", false
+	}
+	if u, err := url.Parse(href); err == nil {
+		fragment = u.Fragment
+		if u.IsAbs() {
+			// absolute links only target pages which are part of the document
+			page, found := l.lookup(name, from.Namespace)
+			return page, fragment, found
+		}
+	}
+	page, _ = l.lookup(name, from.Namespace)
+	return page, fragment, true
+}
+
+// lookup finds a page by name, preferring pages of the same namespace.
+func (l *PageLinks) lookup(name string, namespace string) (StagedPage, bool) {
+	if namespace != "" {
+		if page, ok := l.pages[normalizePage(namespace+NamespaceSeparator+name)]; ok {
+			return page, true
+		}
+	}
+	page, ok := l.pages[normalizePage(name)]
+	return page, ok
+}
+
+// Resolve rewrites links to other pages within doc, which is the HTML of page from in language.
+// Links target the generated HTML file of the page in the same language or,
+// when join is set, its anchor within the joined document.
+// Links to pages which don't exist are left unchanged and returned.
+// When joined, element IDs and links to them are renamed as reserved with ReserveIDs.
+func (l *PageLinks) Resolve(doc *html.Node, from StagedPage, language string, join bool) []MissingLink {
+	var missing []MissingLink
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && join {
+			for i, a := range n.Attr {
+				if a.Key == "id" {
+					n.Attr[i].Val = l.id(from, a.Val)
+				}
+			}
+		}
+		if n.Type == html.ElementNode && n.Data == "a" {
+			for i, a := range n.Attr {
+				if a.Key != "href" {
+					continue
+				}
+				if join && strings.HasPrefix(a.Val, "#") {
+					// links within the page
+					fragment, err := url.PathUnescape(strings.TrimPrefix(a.Val, "#"))
+					if id := l.id(from, fragment); err == nil && id != fragment {
+						n.Attr[i].Val = "#" + id
+					}
+					continue
+				}
+				page, fragment, ok := l.target(a.Val, from)
+				if !ok {
+					continue
+				}
+				if page.Name == "" {
+					missing = append(missing, MissingLink{Page: from.Name, Href: a.Val})
+					continue
+				}
+				n.Attr[i].Val = l.href(page, fragment, language, join)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return missing
+}
+
+// href returns the link to fragment of page in language.
+func (l *PageLinks) href(page StagedPage, fragment string, language string, join bool) string {
+	if join {
+		if fragment != "" {
+			return "#" + l.id(page, fragment)
+		}
+		return "#" + PageAnchor(page.Name)
+	}
+	u := url.URL{
+		Path:     fmt.Sprintf("%s.%s.html", language, PageAnchor(page.Name)),
+		Fragment: fragment,
+	}
+	if l.Files != nil {
+		u.Path = l.Files(page)
+	}
+	return u.String()
+}
+
+// ResolveFile writes the HTML file at src to dst,
+// resolving links to other pages as with Resolve.
+func (l *PageLinks) ResolveFile(src, dst string, from StagedPage, language string, join bool) ([]MissingLink, error) {
+	doc, err := readHTML(src)
+	if err != nil {
+		return nil, err
+	}
+	missing := l.Resolve(doc, from, language, join)
+	err = writeHTML(dst, doc)
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// normalizePage returns the name of a page for case-insensitive comparison,
+// as GitHub wikis treat page names.
+func normalizePage(name string) string {
+	name = strings.TrimSuffix(name, ".md")
+	return strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+}
@@ -0,0 +1,125 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestWikiLinksToMarkdown(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"see [[Getting Started]]", "see [Getting Started](Getting-Started)"},
+		{"see [[the guide|Install Guide#Windows]]", "see [the guide](Install-Guide#Windows)"},
+		{"[[logo.png]]", "![](logo.png)"},
+		{"[[images/logo.png|alt=Lantern logo]]", "![Lantern logo](images/logo.png)"},
+		// other resources are linked, not embedded
+		{"[[Download|lantern.apk]]", "[Download](lantern.apk)"},
+		{"[[Release notes.pdf]]", "[Release notes.pdf](Release%20notes.pdf)"},
+		{"[[Manual|guide.html]]", "[Manual](guide.html)"},
+		{"`[[code]]` and [[Page]]", "`[[code]]` and [Page](Page)"},
+		{"```\n[[fenced]]\n```\n[[Page]]", "```\n[[fenced]]\n```\n[Page](Page)"},
+	} {
+		require.Equal(t, tc.want, string(wikiLinksToMarkdown([]byte(tc.in))), tc.in)
+	}
+}
+
+func TestPageLinksResolve(t *testing.T) {
+	pages := []StagedPage{
+		{Name: "Install.md"},
+		{Name: "Usage.md"},
+		{Name: "faq.Install.md", Namespace: "faq"},
+	}
+	links := NewPageLinks(pages)
+	doc := `<p>
+<a href="Install">install</a>
+<a href="usage.md#run">run</a>
+<a href="https://github.com/getlantern/guide/wiki/Usage">usage</a>
+<a href="https://lantern.io">site</a>
+<a href="#top">top</a>
+<a href="picture.png">picture</a>
+<a href="Missing-Page">missing</a>
+</p>`
+
+	for _, tc := range []struct {
+		name string
+		from StagedPage
+		join bool
+		want []string
+	}{
+		{"files", pages[1], false, []string{
+			"zh.Install.html", "zh.Usage.html#run", "zh.Usage.html",
+			"https://lantern.io", "#top", "picture.png", "Missing-Page",
+		}},
+		{"namespace", pages[2], false, []string{
+			"zh.faq.Install.html", "zh.Usage.html#run", "zh.Usage.html",
+			"https://lantern.io", "#top", "picture.png", "Missing-Page",
+		}},
+		{"joined", pages[1], true, []string{
+			"#Install", "#run", "#Usage",
+			"https://lantern.io", "#top", "picture.png", "Missing-Page",
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root, err := html.Parse(strings.NewReader(doc))
+			require.NoError(t, err)
+			missing := links.Resolve(root, tc.from, "zh", tc.join)
+			require.Equal(t, []MissingLink{{Page: tc.from.Name, Href: "Missing-Page"}}, missing)
+
+			var hrefs []string
+			var walk func(*html.Node)
+			walk = func(n *html.Node) {
+				for _, a := range n.Attr {
+					if a.Key == "href" {
+						hrefs = append(hrefs, a.Val)
+					}
+				}
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					walk(c)
+				}
+			}
+			walk(root)
+			require.Equal(t, tc.want, hrefs)
+		})
+	}
+}
+
+func TestPageLinksReserveIDs(t *testing.T) {
+	dir := t.TempDir()
+	pages := []StagedPage{{Name: "Install.md"}, {Name: "Usage.md"}, {Name: "install.md"}}
+	docs := []string{
+		`<h1 id="install">Install</h1><h2 id="windows">Windows</h2><a href="#windows">windows</a>`,
+		`<h1 id="usage">Usage</h1><h2 id="windows">Windows</h2><a href="#windows">windows</a>`,
+		`<h1 id="Install">Install</h1>`,
+	}
+	var files []string
+	for i, doc := range docs {
+		file := path.Join(dir, pages[i].Name+".html")
+		require.NoError(t, os.WriteFile(file, []byte(doc), 0o644))
+		files = append(files, file)
+	}
+	links := NewPageLinks(pages)
+	require.NoError(t, links.ReserveIDs(pages, files))
+
+	for _, tc := range []struct {
+		page int
+		want string
+	}{
+		{0, `<h1 id="install-1">Install</h1><h2 id="windows">Windows</h2><a href="#windows">windows</a>`},
+		{1, `<h1 id="usage">Usage</h1><h2 id="windows-1">Windows</h2><a href="#windows-1">windows</a>`},
+		// page anchors are reserved before heading IDs
+		{2, `<h1 id="Install-1">Install</h1>`},
+	} {
+		root, err := html.Parse(strings.NewReader(docs[tc.page]))
+		require.NoError(t, err)
+		links.Resolve(root, pages[tc.page], "en", true)
+		var b strings.Builder
+		require.NoError(t, html.Render(&b, root))
+		require.Contains(t, b.String(), tc.want)
+	}
+}
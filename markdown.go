@@ -1,49 +1,170 @@
 package illuminated
 
 import (
+	"bytes"
 	"fmt"
+	"html/template"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"unicode"
 
-	"github.com/russross/blackfriday/v2"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
 )
 
 var DefaultDirNameHTML = "html"
 
+// MarkdownRenderer converts a markdown document to an HTML fragment.
+type MarkdownRenderer interface {
+	Render(source []byte) ([]byte, error)
+}
+
+// MarkdownVersion identifies how markdown is rendered, so pages rendered differently are rebuilt.
+var MarkdownVersion = "gfm"
+
+// DefaultMarkdownRenderer renders pages the same as GitHub.
+var DefaultMarkdownRenderer MarkdownRenderer = NewGitHubRenderer()
+
+// gitHubRenderer renders GitHub Flavored Markdown with the extensions GitHub wikis support:
+// tables, task lists, strikethrough, autolinks, footnotes, heading IDs and alerts.
+type gitHubRenderer struct {
+	md goldmark.Markdown
+}
+
+// NewGitHubRenderer returns a MarkdownRenderer matching GitHub's rendering.
+// Raw HTML is passed through, as GitHub wikis permit it.
+func NewGitHubRenderer() MarkdownRenderer {
+	return &gitHubRenderer{
+		md: goldmark.New(
+			goldmark.WithExtensions(extension.GFM, extension.Footnote),
+			goldmark.WithParserOptions(
+				parser.WithAutoHeadingID(),
+				parser.WithASTTransformers(util.Prioritized(&alertTransformer{}, 100)),
+			),
+			goldmark.WithRendererOptions(
+				gmhtml.WithUnsafe(),
+				renderer.WithNodeRenderers(util.Prioritized(&alertRenderer{}, 100)),
+			),
+		),
+	}
+}
+
+// Render converts source to HTML.
+func (r *gitHubRenderer) Render(source []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	ctx := parser.NewContext(parser.WithIDs(&headingIDs{used: map[string]bool{}}))
+	err := r.md.Convert(source, &buf, parser.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("render markdown: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// headingIDs generates heading IDs the same as GitHub, so links to sections keep working.
+type headingIDs struct {
+	used map[string]bool
+}
+
+// Generate returns a unique ID for a heading with text value.
+func (ids *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	id := slug(string(value))
+	if id == "" {
+		id = "heading"
+	}
+	unique := id
+	for i := 1; ids.used[unique]; i++ {
+		unique = id + "-" + strconv.Itoa(i)
+	}
+	ids.used[unique] = true
+	return []byte(unique)
+}
+
+// Put marks an ID as used, such as IDs set explicitly.
+func (ids *headingIDs) Put(value []byte) {
+	ids.used[string(value)] = true
+}
+
+// slug returns the GitHub anchor for heading text: lower case letters, numbers, hyphens
+// and underscores, with spaces replaced by hyphens and other punctuation removed.
+// Letters and combining marks of every script are kept, so headings in any language have anchors.
+func slug(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.In(r, unicode.Mn, unicode.Mc) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
 // markdownToRawHTML reads a file from inputPath, returning an HTML string.
+// Front matter is not included in the HTML.
 func markdownToRawHTML(inputPath string) (string, error) {
 	f, err := os.ReadFile(inputPath)
 	if err != nil {
 		return "", fmt.Errorf("read file %q: %w", inputPath, err)
 	}
-	output := blackfriday.Run(f)
+	_, body, err := ParseFrontMatter(f)
+	if err != nil {
+		return "", fmt.Errorf("%q: %w", inputPath, err)
+	}
+	output, err := DefaultMarkdownRenderer.Render(wikiLinksToMarkdown(body))
+	if err != nil {
+		return "", fmt.Errorf("%q: %w", inputPath, err)
+	}
 
 	return string(output), nil
 }
 
-// MarkdownToHTML reads markdown from inputPath and writes HTML to outputPath.
-func MarkdownToHTML(inputPath string, outputPath string) error {
+// buildTemplate wraps the HTML of a page in the build directory.
+// Output documents are wrapped again with the page or joined document Templates.
+var buildTemplate = template.Must(template.New("build").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}" dir="{{.Dir}}">
+<head>
+    <meta charset="UTF-8">
+{{- with .Title}}
+    <title>{{.}}</title>
+{{- end}}
+</head>
+<body>
+{{.Body}}
+</body>
+</html>`))
+
+// MarkdownToHTML reads markdown from inputPath and writes HTML to outputPath,
+// declaring the language of the document as lang.
+func MarkdownToHTML(inputPath string, outputPath string, lang string) error {
 	doc, err := markdownToRawHTML(inputPath)
 	if err != nil {
 		return err
 	}
+	meta, err := ReadFrontMatter(inputPath)
+	if err != nil {
+		return err
+	}
 	f, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("create output file %q: %w", outputPath, err)
 	}
 	defer f.Close()
 
-	wrapped := fmt.Sprintf(
-		`<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-</head>
-<body>
-%s
-</body>
-</html>`, doc)
-	_, err = f.WriteString(wrapped)
+	info := Language(lang)
+	err = buildTemplate.Execute(f, TemplateData{
+		Lang:  info.Tag,
+		Dir:   info.Direction,
+		Title: meta.Title,
+		Body:  template.HTML(doc),
+	})
 	if err != nil {
 		return fmt.Errorf("write to output file %q: %w", outputPath, err)
 	}
@@ -1,8 +1,11 @@
 package illuminated
 
 import (
+	"flag"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -10,6 +13,32 @@ import (
 
 var testProjectDir = path.Join("test-project")
 
+var update = flag.Bool("update", false, "update golden files")
+
+// TestMarkdownGolden renders each testdata/markdown/*.md file,
+// comparing the HTML to the .html golden file beside it.
+func TestMarkdownGolden(t *testing.T) {
+	inputs, err := filepath.Glob(path.Join("testdata", "markdown", "*.md"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+	for _, input := range inputs {
+		name := strings.TrimSuffix(path.Base(input), ".md")
+		t.Run(name, func(t *testing.T) {
+			html, err := markdownToRawHTML(input)
+			require.NoError(t, err)
+
+			golden := strings.TrimSuffix(input, ".md") + ".html"
+			if *update {
+				err = os.WriteFile(golden, []byte(html), 0o644)
+				require.NoError(t, err)
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			require.Equal(t, string(want), html)
+		})
+	}
+}
+
 func TestMarkdownToRawHTML(t *testing.T) {
 	input := "test.md"
 	err := os.WriteFile(input, []byte("# Hello World"), 0o644)
@@ -18,7 +47,7 @@ func TestMarkdownToRawHTML(t *testing.T) {
 
 	html, err := markdownToRawHTML(input)
 	require.NoError(t, err)
-	require.Contains(t, html, "<h1>Hello World</h1>")
+	require.Contains(t, html, `<h1 id="hello-world">Hello World</h1>`)
 }
 
 func TestMarkdownToHTML(t *testing.T) {
@@ -29,10 +58,28 @@ func TestMarkdownToHTML(t *testing.T) {
 	defer os.Remove(input)
 	defer os.Remove(output)
 
-	err = MarkdownToHTML(input, output)
+	err = MarkdownToHTML(input, output, "en")
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `<h1 id="hello-world">Hello World</h1>`)
+	require.Contains(t, string(content), `<html lang="en" dir="ltr">`)
+}
+
+func TestMarkdownToHTMLFrontMatter(t *testing.T) {
+	input := "test.md"
+	output := "test.html"
+	err := os.WriteFile(input, []byte("---\ntitle: Greeting\n---\n# Hello World"), 0o644)
+	require.NoError(t, err)
+	defer os.Remove(input)
+	defer os.Remove(output)
+
+	err = MarkdownToHTML(input, output, "en")
 	require.NoError(t, err)
 
 	content, err := os.ReadFile(output)
 	require.NoError(t, err)
-	require.Contains(t, string(content), "<h1>Hello World</h1>")
+	require.Contains(t, string(content), "<title>Greeting</title>")
+	require.NotContains(t, string(content), "title: Greeting")
 }
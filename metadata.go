@@ -0,0 +1,116 @@
+package illuminated
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Metadata describes a document in the properties of its PDF, DOCX or ODT file.
+type Metadata struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords []string
+	Lang     string    // BCP 47 tag of the language of the document
+	Date     time.Time // date the document was built, the time it is generated if zero
+}
+
+// pandocArgs returns the arguments setting metadata of pandoc output.
+func (m Metadata) pandocArgs() []string {
+	var args []string
+	meta := func(key, value string) {
+		if value != "" {
+			args = append(args, "--metadata", fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	meta("title", m.Title)
+	meta("author", m.Author)
+	meta("subject", m.Subject)
+	// repeated keys form a list
+	for _, keyword := range m.Keywords {
+		meta("keywords", keyword)
+	}
+	meta("lang", m.Lang)
+	meta("date", m.date().Format("2006-01-02"))
+	return args
+}
+
+// date returns the date of the document, now if not set.
+func (m Metadata) date() time.Time {
+	if m.Date.IsZero() {
+		return time.Now()
+	}
+	return m.Date
+}
+
+// xmp returns an XMP packet of the metadata, which holds the language of PDF documents.
+func (m Metadata) xmp() []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>" +
+		`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:pdf="http://ns.adobe.com/pdf/1.3/" ` +
+		`xmlns:xmp="http://ns.adobe.com/xap/1.0/">`)
+	alt := func(element, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, `<dc:%s><rdf:Alt><rdf:li xml:lang="x-default">%s</rdf:li></rdf:Alt></dc:%s>`, element, html.EscapeString(value), element)
+		}
+	}
+	alt("title", m.Title)
+	alt("description", m.Subject)
+	if m.Author != "" {
+		fmt.Fprintf(&b, `<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>`, html.EscapeString(m.Author))
+	}
+	if m.Lang != "" {
+		fmt.Fprintf(&b, `<dc:language><rdf:Bag><rdf:li>%s</rdf:li></rdf:Bag></dc:language>`, html.EscapeString(m.Lang))
+	}
+	if len(m.Keywords) > 0 {
+		fmt.Fprintf(&b, `<pdf:Keywords>%s</pdf:Keywords>`, html.EscapeString(strings.Join(m.Keywords, ", ")))
+	}
+	date := m.date().UTC().Format(time.RFC3339)
+	fmt.Fprintf(&b, `<xmp:CreateDate>%s</xmp:CreateDate><xmp:ModifyDate>%s</xmp:ModifyDate>`, date, date)
+	b.WriteString(`</rdf:Description></rdf:RDF></x:xmpmeta><?xpacket end="r"?>`)
+	return b.Bytes()
+}
+
+// BuildDate returns the date documents built from pages are dated, so that builds of the same sources
+// are reproducible: the time given in seconds since the Unix epoch by the SOURCE_DATE_EPOCH environment variable,
+// or the time of the latest commit checked out in the repositories pages were staged from,
+// or now if neither is known.
+func BuildDate(pages []StagedPage) (time.Time, error) {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parse SOURCE_DATE_EPOCH %q: %w", epoch, err)
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	var date time.Time
+	opened := map[string]bool{}
+	for _, page := range pages {
+		if page.Origin == "" || opened[page.Origin] {
+			continue
+		}
+		opened[page.Origin] = true
+		history, err := OpenHistory(page.Origin)
+		if err != nil {
+			return time.Time{}, err
+		}
+		head, err := history.HeadTime()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if head.After(date) {
+			date = head
+		}
+	}
+	if date.IsZero() {
+		return time.Now(), nil
+	}
+	return date.UTC(), nil
+}
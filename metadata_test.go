@@ -0,0 +1,67 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataPandocArgs(t *testing.T) {
+	meta := Metadata{
+		Title:    "راهنما",
+		Author:   "Lantern",
+		Keywords: []string{"vpn", "censorship"},
+		Lang:     "fa",
+		Date:     time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	require.Equal(t, []string{
+		"--metadata", "title=راهنما",
+		"--metadata", "author=Lantern",
+		"--metadata", "keywords=vpn",
+		"--metadata", "keywords=censorship",
+		"--metadata", "lang=fa",
+		"--metadata", "date=2024-03-01",
+	}, meta.pandocArgs())
+
+	xmp := string(meta.xmp())
+	require.Contains(t, xmp, `<dc:title><rdf:Alt><rdf:li xml:lang="x-default">راهنما</rdf:li></rdf:Alt></dc:title>`)
+	require.Contains(t, xmp, `<dc:language><rdf:Bag><rdf:li>fa</rdf:li></rdf:Bag></dc:language>`)
+	require.Contains(t, xmp, `<pdf:Keywords>vpn, censorship</pdf:Keywords>`)
+	require.Contains(t, xmp, `<xmp:CreateDate>2024-03-01T12:00:00Z</xmp:CreateDate>`)
+	require.NotContains(t, xmp, "dc:description")
+}
+
+func TestBuildDate(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path.Join(dir, "Home.md"), []byte("# Home"), 0o644))
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = wt.Add("Home.md")
+	require.NoError(t, err)
+	committed := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	_, err = wt.Commit("add Home.md", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: committed},
+	})
+	require.NoError(t, err)
+
+	pages := []StagedPage{{Name: "Home.md", Origin: dir}, {Name: "Archived.md", Origin: t.TempDir()}}
+	date, err := BuildDate(pages)
+	require.NoError(t, err)
+	require.Equal(t, committed, date)
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	date, err = BuildDate(pages)
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1700000000, 0).UTC(), date)
+
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	_, err = BuildDate(pages)
+	require.ErrorContains(t, err, "SOURCE_DATE_EPOCH")
+}
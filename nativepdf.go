@@ -0,0 +1,1252 @@
+package illuminated
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"codeberg.org/go-pdf/fpdf"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// PDFEngineNative is the PDF engine typesetting PDFs in Go, without pandoc or LaTeX.
+const PDFEngineNative = "native"
+
+// Sizes of native PDF output, in points.
+const (
+	nativeFontSize = 11.0
+	nativeCodeSize = 9.5
+	nativePageSize = 9.0  // of headers and footers
+	nativeLeading  = 1.4  // line height, relative to the font size
+	nativeSpacing  = 6.0  // between blocks
+	nativeIndent   = 18.0 // of lists and quotes
+	nativePadding  = 4.0  // of table cells and code
+	nativeMargin   = 72.0 // of pages without margins set
+	nativeNumbers  = 30.0 // width of page numbers in the table of contents
+)
+
+// nativeHeadingSizes are the font sizes of headings, by level.
+var nativeHeadingSizes = []float64{22, 18, 15, 13, 12, 11}
+
+// paperSizes maps paper sizes to their names in fpdf.
+var paperSizes = map[string]string{
+	"a3": "A3", "a4": "A4", "a5": "A5", "letter": "Letter", "legal": "Legal", "tabloid": "Tabloid",
+}
+
+// lengthUnits maps units of length to points.
+var lengthUnits = map[string]float64{"pt": 1, "in": 72, "cm": 72 / 2.54, "mm": 72 / 25.4}
+
+// nativeBlocks are the elements laid out as blocks, rather than within lines of text.
+var nativeBlocks = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true, atom.Details: true,
+	atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Figcaption: true, atom.Figure: true,
+	atom.Footer: true, atom.Form: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Head: true, atom.Header: true, atom.Hr: true, atom.Img: true,
+	atom.Li: true, atom.Main: true, atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true,
+	atom.Script: true, atom.Section: true, atom.Style: true, atom.Summary: true, atom.Table: true,
+	atom.Template: true, atom.Ul: true,
+}
+
+// WriteNativePDF typesets the HTML document at sourcePath as a PDF at outPath without external tools,
+// with the properties of meta, the fonts and direction of profile and laid out with layout.
+// Fonts are named by file or by family, found in FontDirs, with the Go fonts used for those not found;
+// characters missing from the main font are set in the first fallback font that has them.
+// Local JPEG, PNG and GIF images are found relative to resourcePath.
+func WriteNativePDF(sourcePath, outPath, resourcePath string, meta Metadata, profile LanguageProfile, layout PDFLayout) error {
+	slog.Debug("typesetting PDF natively", "source", sourcePath, "out", outPath, "resourcePath", resourcePath)
+	doc, err := readHTML(sourcePath)
+	if err != nil {
+		return err
+	}
+	err = ApplyTransforms(doc, PDFTransforms(profile.Direction)...)
+	if err != nil {
+		return fmt.Errorf("prepare HTML for PDF: %w", err)
+	}
+	meta.Title = cmp.Or(meta.Title, strings.TrimSuffix(path.Base(sourcePath), ".html"))
+	if resourcePath == "" {
+		resourcePath = "."
+	}
+	if _, ok := paperSizes[strings.ToLower(layout.Paper)]; !ok && layout.Paper != "" {
+		slog.Warn("unknown paper size, using letter", "paper", layout.Paper)
+	}
+
+	fonts, err := readProfileFonts(profile)
+	if err != nil {
+		return err
+	}
+	// front pages are set before the table of contents, which leaves out their headings
+	front := detachFrontPages(doc)
+	headings := nativeHeadings(doc, layout.NumberSections)
+	// the document is typeset twice, first finding the pages of headings for the table of contents,
+	// with warnings logged the second time
+	var r *nativeRenderer
+	for pass := range 2 {
+		r, err = newNativeRenderer(profile.Direction, layout, meta, resourcePath, fonts, headings)
+		if err != nil {
+			return err
+		}
+		if pass == 0 {
+			r.warn = func(string, ...any) {}
+		}
+		err = r.render(doc, front)
+		if err != nil {
+			return fmt.Errorf("typeset PDF: %w", err)
+		}
+	}
+	err = r.pdf.OutputFileAndClose(outPath)
+	if err != nil {
+		return fmt.Errorf("write PDF %q: %w", outPath, err)
+	}
+	slog.Info("generated pdf successfully", "name", outPath)
+	return nil
+}
+
+// nativeFonts are the fonts of a language profile.
+type nativeFonts struct {
+	main, mono *fontFiles
+	fallbacks  []*fontFiles
+}
+
+// readProfileFonts finds and reads the fonts of profile, the Go fonts standing in for main and mono fonts not found.
+func readProfileFonts(profile LanguageProfile) (nativeFonts, error) {
+	index := indexFonts(FontDirs)
+	var fonts nativeFonts
+	var err error
+	fonts.main, err = findFont(index, profile.MainFont, goFonts)
+	if err != nil {
+		return fonts, err
+	}
+	fonts.mono, err = findFont(index, profile.MonoFont, goMonoFonts)
+	if err != nil {
+		return fonts, err
+	}
+	for _, name := range profile.FallbackFonts {
+		font, err := findFont(index, name, nil)
+		if err != nil {
+			return fonts, err
+		}
+		if font != nil {
+			fonts.fallbacks = append(fonts.fallbacks, font)
+		}
+	}
+	return fonts, nil
+}
+
+// pageMargin returns margins such as 2cm in points.
+func pageMargin(margins string) (float64, error) {
+	if margins == "" {
+		return nativeMargin, nil
+	}
+	for unit, points := range lengthUnits {
+		if number, ok := strings.CutSuffix(margins, unit); ok {
+			length, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+			if err == nil && length >= 0 {
+				return length * points, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid margins %q, expected a length such as 2cm", margins)
+}
+
+// nativeHeading is a heading of a document, in its table of contents and outline.
+type nativeHeading struct {
+	node   *html.Node
+	level  int
+	number string // section number, if sections are numbered
+	text   string
+	page   int // page the heading is typeset on
+	link   int // internal link to the heading
+}
+
+// nativeHeadings returns the headings of doc, numbered if number is true.
+func nativeHeadings(doc *html.Node, number bool) []nativeHeading {
+	var found []nativeHeading
+	var counters [6]int
+	for _, n := range headings(doc) {
+		level := headingLevels[n.DataAtom]
+		counters[level-1]++
+		clear(counters[level:])
+		heading := nativeHeading{node: n, level: level, text: strings.TrimSpace(collapseSpace(textContent(n)))}
+		if number {
+			parts := make([]string, level)
+			for i, count := range counters[:level] {
+				parts[i] = strconv.Itoa(count)
+			}
+			heading.number = strings.Join(parts, ".")
+		}
+		found = append(found, heading)
+	}
+	return found
+}
+
+// textStyle is the style of text within lines.
+type textStyle struct {
+	bold, italic, mono bool
+	size               float64
+	href               string // external link
+	link               int    // internal link
+}
+
+// span is text in a style, or an image, line break or link target within text.
+type span struct {
+	text  string
+	style textStyle
+	image *html.Node
+	br    bool
+	soft  bool   // breaking only lines with text, as between blocks
+	id    string // id of an element, the target of internal links
+}
+
+// glyphRun is text set in one font and style.
+type glyphRun struct {
+	text  []rune
+	style textStyle
+	font  *pdfFont
+	width float64
+}
+
+// word is text between the places lines may break, such as spaces, or a line break.
+type word struct {
+	runs  []glyphRun
+	space bool // preceded by a space
+	width float64
+	br    bool
+	soft  bool
+}
+
+// nativeRenderer typesets HTML documents as PDF.
+type nativeRenderer struct {
+	pdf       *fpdf.Fpdf
+	layout    PDFLayout
+	title     string
+	rtl       bool
+	resources string
+	main      *pdfFont
+	mono      *pdfFont
+	fallbacks []*pdfFont
+	current   string // font, style and size last set
+	warn      func(msg string, args ...any)
+	missing   map[rune]bool // characters no font has or which can't be set, each warned about once
+
+	left, right, top, bottom float64 // the area of pages text is set in
+	x0, x1, y                float64 // the area of the block being set, and the top of the next line
+	marker                   string  // list item marker set beside the next line
+
+	headings []nativeHeading
+	index    map[*html.Node]int // headings by element
+	outline  int                // level of the last heading in the outline
+	links    map[string]int     // internal links to elements, by id
+	placed   map[string]bool    // ids of elements typeset
+}
+
+// newNativeRenderer returns a renderer of a PDF described by meta with fonts, in direction dir and laid out with layout.
+func newNativeRenderer(
+	dir string, layout PDFLayout, meta Metadata, resources string, fonts nativeFonts, headings []nativeHeading,
+) (*nativeRenderer, error) {
+	paper := cmp.Or(paperSizes[strings.ToLower(layout.Paper)], "Letter")
+	margin, err := pageMargin(layout.Margins)
+	if err != nil {
+		return nil, err
+	}
+	pdf := fpdf.New("P", "pt", paper, "")
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetTitle(meta.Title, true)
+	pdf.SetAuthor(meta.Author, true)
+	pdf.SetSubject(meta.Subject, true)
+	pdf.SetKeywords(strings.Join(meta.Keywords, ", "), true)
+	pdf.SetCreator("illuminated", true)
+	pdf.SetXmpMetadata(meta.xmp())
+	// the same document is written the same way each time
+	pdf.SetCatalogSort(true)
+	pdf.SetCreationDate(meta.date())
+	pdf.SetModificationDate(meta.date())
+	width, height := pdf.GetPageSize()
+	r := &nativeRenderer{
+		pdf:       pdf,
+		layout:    layout,
+		title:     meta.Title,
+		rtl:       dir == "rtl",
+		resources: resources,
+		left:      margin,
+		right:     width - margin,
+		top:       margin,
+		bottom:    height - margin,
+		headings:  headings,
+		index:     map[*html.Node]int{},
+		outline:   -1,
+		links:     map[string]int{},
+		placed:    map[string]bool{},
+		warn:      slog.Warn,
+		missing:   map[rune]bool{},
+	}
+	r.x0, r.x1 = r.left, r.right
+
+	r.main, err = fonts.main.register(pdf, "main")
+	if err != nil {
+		return nil, err
+	}
+	r.mono, err = fonts.mono.register(pdf, "mono")
+	if err != nil {
+		return nil, err
+	}
+	for i, fallback := range fonts.fallbacks {
+		font, err := fallback.register(pdf, fmt.Sprintf("fallback%d", i))
+		if err != nil {
+			return nil, err
+		}
+		r.fallbacks = append(r.fallbacks, font)
+	}
+	for i := range r.headings {
+		r.headings[i].link = pdf.AddLink()
+		r.index[r.headings[i].node] = i
+	}
+	return r, nil
+}
+
+// render typesets doc, after the front pages if any, or else a title or cover page,
+// and the table of contents.
+func (r *nativeRenderer) render(doc, front *html.Node) error {
+	for _, id := range elementIDs(doc) {
+		if _, ok := r.links[id]; !ok {
+			r.links[id] = r.pdf.AddLink()
+		}
+	}
+	if front != nil {
+		// the front pages start with a cover, without the header and footer
+		r.layout.Cover = true
+	}
+	r.newPage()
+	if front != nil {
+		r.blocks(front)
+		if r.y > r.top {
+			r.newPage()
+		}
+	} else if r.layout.Cover {
+		r.coverPage()
+		r.newPage()
+	} else {
+		r.paragraph([]span{{text: r.title, style: textStyle{bold: true, size: 24}}}, "center")
+		r.y += 2 * nativeSpacing
+	}
+	r.contents()
+	if body := findElement(doc, atom.Body); body != nil {
+		r.blocks(body)
+	}
+	// links to elements left out, such as those in tables, lead to the first page
+	for id, link := range r.links {
+		if !r.placed[id] {
+			r.pdf.SetLink(link, 0, 1)
+		}
+	}
+	for _, heading := range r.headings {
+		if heading.page == 0 {
+			r.pdf.SetLink(heading.link, 0, 1)
+		}
+	}
+	return r.pdf.Error()
+}
+
+// coverPage sets the title on a page of its own, above the cover image if any.
+func (r *nativeRenderer) coverPage() {
+	r.y = r.top + (r.bottom-r.top)/4
+	r.paragraph([]span{{text: r.title, style: textStyle{bold: true, size: 28}}}, "center")
+	if r.layout.CoverImage != "" {
+		r.y += 4 * nativeSpacing
+		r.drawImage(r.layout.CoverImage, 0, r.bottom-r.y)
+	}
+}
+
+// frontCover sets the cover of front pages, its lines centered below the top of the page
+// and the cover title large.
+func (r *nativeRenderer) frontCover(n *html.Node) {
+	r.y = max(r.y, r.top+(r.bottom-r.top)/4)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		r.target(attr(c, "id"))
+		switch {
+		case c.DataAtom == atom.Img:
+			if file, ok := r.localImage(attr(c, "src")); ok {
+				r.drawImage(file, 0, (r.bottom-r.top)/4)
+			}
+		case attr(c, "class") == "cover-title":
+			r.paragraph(r.spans(childNodes(c), textStyle{bold: true, size: 28}), "center")
+		default:
+			r.paragraph(r.spans(childNodes(c), textStyle{size: nativeFontSize}), "center")
+		}
+		r.y += 2 * nativeSpacing
+	}
+}
+
+// contents sets the table of contents, of headings up to the depth of the layout,
+// with the pages they're on and links to them.
+func (r *nativeRenderer) contents() {
+	found := false
+	for i := range r.headings {
+		heading := &r.headings[i]
+		if heading.level > r.layout.TOCDepth {
+			continue
+		}
+		found = true
+		style := textStyle{bold: heading.level == 1, size: nativeFontSize}
+		indent := float64(heading.level-1) * nativeIndent
+		x0, x1 := r.x0+indent, r.x1-nativeNumbers
+		if r.rtl {
+			x0, x1 = r.x0+nativeNumbers, r.x1-indent
+		}
+		text := strings.TrimSpace(heading.number + " " + heading.text)
+		lines := r.breakLines(r.words([]span{{text: text, style: style}}), x1-x0)
+		top, page, baseline := r.y, r.pdf.PageNo(), r.y
+		for _, line := range lines {
+			height := r.lineHeight(line)
+			r.ensure(height)
+			if r.pdf.PageNo() != page {
+				top, page = r.y, r.pdf.PageNo()
+			}
+			baseline = r.drawLine(line, x0, x1, r.y, height, r.align(), r.rtl)
+			r.y += height
+		}
+		number := strconv.Itoa(heading.page)
+		r.setFont(r.main, style)
+		x := r.x1 - r.pdf.GetStringWidth(number)
+		if r.rtl {
+			x = r.x0
+		}
+		r.pdf.Text(x, baseline, number)
+		r.pdf.Link(r.x0, top, r.x1-r.x0, r.y-top, heading.link)
+	}
+	if found {
+		r.y += 2 * nativeSpacing
+	}
+}
+
+// newPage starts a page, with the header and footer of the layout unless it's the cover.
+func (r *nativeRenderer) newPage() {
+	r.pdf.AddPage()
+	r.y = r.top
+	if r.layout.Cover && r.pdf.PageNo() == 1 {
+		return
+	}
+	_, height := r.pdf.GetPageSize()
+	if r.layout.Header != "" {
+		r.pageText(r.layout.Header, r.top/2)
+	}
+	r.pageText(cmp.Or(r.layout.Footer, "{page}"), r.bottom+(height-r.bottom)/2)
+}
+
+// pageText sets a line of text centered at y in the margin of the page, with {page} replaced by the page number.
+func (r *nativeRenderer) pageText(text string, y float64) {
+	text = strings.ReplaceAll(text, "{page}", strconv.Itoa(r.pdf.PageNo()))
+	lines := r.breakLines(r.words([]span{{text: text, style: textStyle{size: nativePageSize}}}), r.right-r.left)
+	if len(lines) > 0 {
+		height := r.lineHeight(lines[0])
+		r.drawLine(lines[0], r.left, r.right, y-height/2, height, "center", r.rtl)
+	}
+}
+
+// ensure starts a new page unless there's room for height on this one.
+func (r *nativeRenderer) ensure(height float64) {
+	if r.y+height > r.bottom && r.y > r.top {
+		r.newPage()
+	}
+}
+
+// indent sets content indented at the start of lines.
+func (r *nativeRenderer) indent(content func()) {
+	x0, x1 := r.x0, r.x1
+	if r.rtl {
+		r.x1 -= nativeIndent
+	} else {
+		r.x0 += nativeIndent
+	}
+	content()
+	r.x0, r.x1 = x0, x1
+}
+
+// align returns where lines of text start, left or right.
+func (r *nativeRenderer) align() string {
+	if r.rtl {
+		return "right"
+	}
+	return "left"
+}
+
+// target makes the current position the target of internal links to the element with id.
+func (r *nativeRenderer) target(id string) {
+	if link, ok := r.links[id]; ok && !r.placed[id] {
+		r.pdf.SetLink(link, r.y, -1)
+		r.placed[id] = true
+	}
+}
+
+// blocks sets the children of n, with text and inline elements between blocks set as paragraphs.
+func (r *nativeRenderer) blocks(n *html.Node) {
+	var inline []*html.Node
+	flush := func() {
+		if len(inline) > 0 {
+			r.paragraph(r.spans(inline, textStyle{size: nativeFontSize}), r.align())
+			inline = nil
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && nativeBlocks[c.DataAtom] {
+			flush()
+			r.block(c)
+		} else {
+			inline = append(inline, c)
+		}
+	}
+	flush()
+}
+
+// block sets the block element n.
+func (r *nativeRenderer) block(n *html.Node) {
+	if _, ok := headingLevels[n.DataAtom]; ok {
+		r.heading(n)
+		return
+	}
+	r.target(attr(n, "id"))
+	switch n.DataAtom {
+	case atom.Head, atom.Script, atom.Style, atom.Template:
+	case atom.P:
+		r.paragraph(r.spans(childNodes(n), textStyle{size: nativeFontSize}), r.align())
+		r.y += nativeSpacing
+	case atom.Ul, atom.Ol:
+		r.list(n)
+	case atom.Pre:
+		r.code(n)
+	case atom.Blockquote:
+		r.quote(n)
+	case atom.Table:
+		r.table(n)
+	case atom.Img:
+		r.image(n)
+	case atom.Hr:
+		r.ensure(2 * nativeSpacing)
+		r.pdf.SetDrawColor(180, 180, 180)
+		r.pdf.SetLineWidth(0.5)
+		r.pdf.Line(r.x0, r.y+nativeSpacing, r.x1, r.y+nativeSpacing)
+		r.y += 2 * nativeSpacing
+	case atom.Dt:
+		r.paragraph(r.spans(childNodes(n), textStyle{bold: true, size: nativeFontSize}), r.align())
+	case atom.Dd:
+		r.indent(func() { r.blocks(n) })
+		r.y += nativeSpacing
+	case atom.Section:
+		if attr(n, "class") == "cover" {
+			r.frontCover(n)
+			return
+		}
+		r.blocks(n)
+	case atom.Div:
+		if attr(n, "class") == "page-break" {
+			if r.y > r.top {
+				r.newPage()
+			}
+			return
+		}
+		r.blocks(n)
+	default:
+		r.blocks(n)
+	}
+}
+
+// heading sets a heading, in the outline and the target of its entry in the table of contents.
+// Each chapter, a level 1 heading, starts on a new page.
+func (r *nativeRenderer) heading(n *html.Node) {
+	level := headingLevels[n.DataAtom]
+	style := textStyle{bold: true, size: nativeHeadingSizes[level-1]}
+	if level == 1 && r.y > r.top {
+		r.newPage()
+	} else {
+		if r.y > r.top {
+			r.y += style.size * 0.6
+		}
+		// headings are kept with the lines after them
+		r.ensure(3 * style.size * nativeLeading)
+	}
+	r.target(attr(n, "id"))
+	spans := r.spans(childNodes(n), style)
+	if i, ok := r.index[n]; ok {
+		heading := &r.headings[i]
+		heading.page = r.pdf.PageNo()
+		r.pdf.SetLink(heading.link, r.y, -1)
+		r.outline = min(level-1, r.outline+1)
+		// bookmarks are encoded for the font last set
+		r.setFont(r.main, style)
+		r.pdf.Bookmark(strings.TrimSpace(heading.number+" "+heading.text), r.outline, r.y)
+		if heading.number != "" {
+			spans = append([]span{{text: heading.number + " ", style: style}}, spans...)
+		}
+	}
+	r.paragraph(spans, r.align())
+	r.y += nativeSpacing
+}
+
+// list sets the items of an ordered or unordered list, beside their numbers or bullets.
+func (r *nativeRenderer) list(n *html.Node) {
+	number := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		number = start
+	}
+	for item := n.FirstChild; item != nil; item = item.NextSibling {
+		if item.DataAtom != atom.Li {
+			continue
+		}
+		marker := "•"
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d.", number)
+			number++
+		}
+		r.indent(func() {
+			r.marker = marker
+			r.target(attr(item, "id"))
+			r.blocks(item)
+			r.marker = ""
+		})
+	}
+	r.y += nativeSpacing
+}
+
+// quote sets a block quotation, indented beside a line.
+func (r *nativeRenderer) quote(n *html.Node) {
+	top, page := r.y, r.pdf.PageNo()
+	r.indent(func() { r.blocks(n) })
+	if r.pdf.PageNo() != page {
+		top = r.top
+	}
+	x := r.x0 + nativeIndent/3
+	if r.rtl {
+		x = r.x1 - nativeIndent/3
+	}
+	r.pdf.SetDrawColor(200, 200, 200)
+	r.pdf.SetLineWidth(2)
+	r.pdf.Line(x, top, x, r.y-nativeSpacing)
+}
+
+// code sets preformatted text, left to right in a monospaced font, with its lines broken where they overflow.
+func (r *nativeRenderer) code(n *html.Node) {
+	style := textStyle{mono: true, size: nativeCodeSize}
+	text := strings.TrimSuffix(strings.ReplaceAll(textContent(n), "\t", "    "), "\n")
+	height := nativeCodeSize * nativeLeading
+	r.pdf.SetFillColor(245, 245, 245)
+	for _, line := range strings.Split(text, "\n") {
+		styled := make([]styledRune, 0, len(line))
+		for _, ch := range line {
+			styled = append(styled, styledRune{ch, style})
+		}
+		w := r.word(styled, false)
+		parts := []word{w}
+		if len(w.runs) > 0 {
+			parts = nil
+			for _, l := range r.breakLines([]word{w}, r.x1-r.x0-2*nativePadding) {
+				parts = append(parts, l...)
+			}
+		}
+		for _, part := range parts {
+			r.ensure(height)
+			r.pdf.Rect(r.x0, r.y, r.x1-r.x0, height, "F")
+			r.drawLine([]word{part}, r.x0+nativePadding, r.x1-nativePadding, r.y, height, "left", false)
+			r.y += height
+		}
+	}
+	r.y += nativeSpacing
+}
+
+// table sets a table with columns of equal width, its header cells in bold.
+func (r *nativeRenderer) table(n *html.Node) {
+	var rows [][]*html.Node
+	walkElements(n, func(c *html.Node) {
+		if c.DataAtom != atom.Tr {
+			return
+		}
+		var cells []*html.Node
+		for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+				cells = append(cells, cell)
+			}
+		}
+		rows = append(rows, cells)
+	})
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return
+	}
+	width := (r.x1 - r.x0) / float64(columns)
+	r.pdf.SetDrawColor(180, 180, 180)
+	r.pdf.SetLineWidth(0.5)
+	r.pdf.SetFillColor(240, 240, 240)
+	for _, row := range rows {
+		lines := make([][][]word, len(row))
+		height := 0.0
+		for i, cell := range row {
+			style := textStyle{bold: cell.DataAtom == atom.Th, size: nativeFontSize}
+			lines[i] = r.breakLines(r.words(r.spans(childNodes(cell), style)), width-2*nativePadding)
+			cellHeight := 0.0
+			for _, line := range lines[i] {
+				cellHeight += r.lineHeight(line)
+			}
+			height = max(height, cellHeight)
+		}
+		height += 2 * nativePadding
+		r.ensure(height)
+		for i, cell := range row {
+			x := r.x0 + float64(i)*width
+			if r.rtl {
+				x = r.x1 - float64(i+1)*width
+			}
+			fill := "D"
+			if cell.DataAtom == atom.Th {
+				fill = "FD"
+			}
+			r.pdf.Rect(x, r.y, width, height, fill)
+			align := cmp.Or(cellAlign(cell), r.align())
+			y := r.y + nativePadding
+			for _, line := range lines[i] {
+				lineHeight := r.lineHeight(line)
+				r.drawLine(line, x+nativePadding, x+width-nativePadding, y, lineHeight, align, r.rtl)
+				y += lineHeight
+			}
+		}
+		r.y += height
+	}
+	r.y += nativeSpacing
+}
+
+// cellAlign returns the alignment of a table cell, left, right or center, or an empty string if not aligned.
+func cellAlign(n *html.Node) string {
+	align := strings.ToLower(attr(n, "align"))
+	style := strings.ReplaceAll(strings.ToLower(attr(n, "style")), " ", "")
+	if _, value, ok := strings.Cut(style, "text-align:"); ok {
+		align, _, _ = strings.Cut(value, ";")
+	}
+	switch align {
+	case "left", "right", "center":
+		return align
+	}
+	return ""
+}
+
+// image sets the image of an img element, if it is a local file.
+func (r *nativeRenderer) image(n *html.Node) {
+	file, ok := r.localImage(attr(n, "src"))
+	if !ok {
+		return
+	}
+	width := 0.0
+	if w, err := strconv.ParseFloat(attr(n, "width"), 64); err == nil {
+		width = w * 0.75 // pixels of screens at 96 dpi
+	}
+	r.drawImage(file, width, r.bottom-r.top)
+}
+
+// localImage returns the file of the image at src in the resources, if it's a local file.
+func (r *nativeRenderer) localImage(src string) (string, bool) {
+	u, err := url.Parse(src)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		r.warn("image not in a local file left out of native PDF", "src", src)
+		return "", false
+	}
+	return path.Join(r.resources, u.Path), true
+}
+
+// drawImage sets the JPEG, PNG or GIF image at file centered on its own lines,
+// at most width wide if not 0 and maxHeight high.
+func (r *nativeRenderer) drawImage(file string, width, maxHeight float64) {
+	kind := strings.TrimPrefix(strings.ToLower(path.Ext(file)), ".")
+	if kind == "jpeg" {
+		kind = "jpg"
+	}
+	if kind != "jpg" && kind != "png" && kind != "gif" {
+		r.warn("image type not supported by native PDF, use JPEG, PNG or GIF", "file", file)
+		return
+	}
+	if r.pdf.Err() {
+		return
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		r.warn("image not found", "file", file)
+		return
+	}
+	defer f.Close()
+	options := fpdf.ImageOptions{ImageType: kind}
+	info := r.pdf.RegisterImageOptionsReader(file, options, f)
+	if err := r.pdf.Error(); err != nil || info == nil {
+		r.warn("image can't be read", "file", file, "error", err)
+		r.pdf.ClearError()
+		return
+	}
+	// images are sized for screens at 96 dpi
+	w, h := info.Width()*0.75, info.Height()*0.75
+	if width > 0 {
+		w, h = width, h*width/w
+	}
+	if w > r.x1-r.x0 {
+		w, h = r.x1-r.x0, h*(r.x1-r.x0)/w
+	}
+	if h > maxHeight {
+		w, h = w*maxHeight/h, maxHeight
+	}
+	r.ensure(h)
+	r.pdf.ImageOptions(file, r.x0+(r.x1-r.x0-w)/2, r.y, w, h, false, options, 0, "")
+	r.y += h + nativeSpacing
+}
+
+// childNodes returns the children of n.
+func childNodes(n *html.Node) []*html.Node {
+	var children []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		children = append(children, c)
+	}
+	return children
+}
+
+// spans returns the text of nodes in style, with blocks within them on lines of their own.
+func (r *nativeRenderer) spans(nodes []*html.Node, style textStyle) []span {
+	var spans []span
+	var walk func(*html.Node, textStyle)
+	walk = func(n *html.Node, style textStyle) {
+		if n.Type == html.TextNode {
+			spans = append(spans, span{text: n.Data, style: style})
+			return
+		}
+		if n.Type != html.ElementNode {
+			return
+		}
+		if id := attr(n, "id"); id != "" {
+			spans = append(spans, span{id: id})
+		}
+		switch n.DataAtom {
+		case atom.Script, atom.Style, atom.Template:
+			return
+		case atom.Br:
+			spans = append(spans, span{br: true})
+			return
+		case atom.Img:
+			spans = append(spans, span{image: n})
+			return
+		case atom.Input:
+			if attr(n, "type") == "checkbox" {
+				box := "[ ] "
+				if hasAttr(n, "checked") {
+					box = "[x] "
+				}
+				spans = append(spans, span{text: box, style: style})
+			}
+			return
+		case atom.B, atom.Strong:
+			style.bold = true
+		case atom.I, atom.Em, atom.Cite, atom.Var:
+			style.italic = true
+		case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+			style.mono = true
+		case atom.A:
+			href := attr(n, "href")
+			if id, ok := strings.CutPrefix(href, "#"); ok {
+				style.link = r.links[id]
+			} else if href != "" {
+				style.href = href
+			}
+		}
+		block := nativeBlocks[n.DataAtom]
+		if block {
+			spans = append(spans, span{br: true, soft: true})
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, style)
+		}
+		if block {
+			spans = append(spans, span{br: true, soft: true})
+		}
+	}
+	for _, n := range nodes {
+		walk(n, style)
+	}
+	return spans
+}
+
+// paragraph sets spans as lines of text aligned to align, left, right or center, with images between lines.
+func (r *nativeRenderer) paragraph(spans []span, align string) {
+	start := 0
+	for i, s := range spans {
+		if s.image != nil {
+			r.text(spans[start:i], align)
+			r.image(s.image)
+			start = i + 1
+		}
+	}
+	r.text(spans[start:], align)
+}
+
+// text sets spans as lines of text aligned to align.
+func (r *nativeRenderer) text(spans []span, align string) {
+	var ids []string
+	for _, s := range spans {
+		if s.id != "" {
+			ids = append(ids, s.id)
+		}
+	}
+	lines := r.breakLines(r.words(spans), r.x1-r.x0)
+	for i, line := range lines {
+		height := r.lineHeight(line)
+		r.ensure(height)
+		if i == 0 {
+			for _, id := range ids {
+				r.target(id)
+			}
+		}
+		baseline := r.drawLine(line, r.x0, r.x1, r.y, height, align, r.rtl)
+		if r.marker != "" {
+			r.drawMarker(baseline)
+		}
+		r.y += height
+	}
+	if len(lines) == 0 {
+		for _, id := range ids {
+			r.target(id)
+		}
+	}
+}
+
+// drawMarker sets the marker of a list item before the start of the line at baseline.
+func (r *nativeRenderer) drawMarker(baseline float64) {
+	marker := string(visualText([]rune(r.marker), r.rtl))
+	r.marker = ""
+	style := textStyle{size: nativeFontSize}
+	r.setFont(r.fontFor('•', false), style)
+	r.pdf.SetTextColor(0, 0, 0)
+	width := r.pdf.GetStringWidth(marker)
+	x := r.x0 - width - nativePadding
+	if r.rtl {
+		x = r.x1 + nativePadding
+	}
+	r.pdf.Text(x, baseline, marker)
+}
+
+// styledRune is a character in a style.
+type styledRune struct {
+	r     rune
+	style textStyle
+}
+
+// words splits the text of spans into words, between which lines may break:
+// at spaces, and before and after characters of languages written without spaces such as Chinese.
+func (r *nativeRenderer) words(spans []span) []word {
+	var words []word
+	var text []styledRune
+	space, breakNext := false, false
+	flush := func() {
+		if len(text) > 0 {
+			words = append(words, r.word(text, space))
+			text, space = nil, false
+		}
+	}
+	for _, s := range spans {
+		if s.br {
+			flush()
+			words = append(words, word{br: true, soft: s.soft})
+			space = false
+			continue
+		}
+		for _, ch := range s.text {
+			switch {
+			case unicode.IsSpace(ch):
+				flush()
+				space, breakNext = true, false
+				continue
+			case unicode.IsControl(ch):
+				continue
+			case ch > 0xFFFF:
+				// fpdf sets characters of the Basic Multilingual Plane only, such as no emoji
+				if !r.missing[ch] {
+					r.missing[ch] = true
+					r.warn("character outside the Basic Multilingual Plane replaced, the native PDF engine can't set it",
+						"character", string(ch), "code", fmt.Sprintf("U+%04X", ch))
+				}
+				ch = unicode.ReplacementChar
+			}
+			if isCJK(ch) || (breakNext && !unicode.IsPunct(ch)) {
+				flush()
+			}
+			breakNext = isCJK(ch) || (breakNext && unicode.IsPunct(ch))
+			text = append(text, styledRune{ch, s.style})
+		}
+	}
+	flush()
+	return words
+}
+
+// word returns text as a word, its Arabic letters shaped and its characters set in the fonts that have them.
+func (r *nativeRenderer) word(text []styledRune, space bool) word {
+	var shaped []styledRune
+	for i := 0; i < len(text); {
+		j := i
+		runes := []rune{}
+		for ; j < len(text) && text[j].style == text[i].style; j++ {
+			runes = append(runes, text[j].r)
+		}
+		for _, ch := range shapeArabic(runes, r.covered) {
+			shaped = append(shaped, styledRune{ch, text[i].style})
+		}
+		i = j
+	}
+	w := word{space: space}
+	for _, ch := range shaped {
+		font := r.fontFor(ch.r, ch.style.mono)
+		if n := len(w.runs); n > 0 && w.runs[n-1].font == font && w.runs[n-1].style == ch.style {
+			w.runs[n-1].text = append(w.runs[n-1].text, ch.r)
+			continue
+		}
+		w.runs = append(w.runs, glyphRun{text: []rune{ch.r}, style: ch.style, font: font})
+	}
+	r.measureWord(&w)
+	return w
+}
+
+// measureWord sets the widths of a word and its runs.
+func (r *nativeRenderer) measureWord(w *word) {
+	w.width = 0
+	for i := range w.runs {
+		w.runs[i].width = r.measure(w.runs[i].font, w.runs[i].style, string(w.runs[i].text))
+		w.width += w.runs[i].width
+	}
+}
+
+// fontFor returns the font to set r in: the main or monospaced font,
+// or the first fallback font that has it if that font doesn't.
+// Characters no font has are warned about, once each, and set in the main or monospaced font.
+func (r *nativeRenderer) fontFor(ch rune, mono bool) *pdfFont {
+	font, ok := r.lookupFont(ch, mono)
+	if !ok && !r.missing[ch] {
+		r.missing[ch] = true
+		r.warn("no font has character, add a font with it to the language profile",
+			"character", string(ch), "code", fmt.Sprintf("U+%04X", ch))
+	}
+	return font
+}
+
+// lookupFont returns the font to set ch in, as fontFor does, and whether it has ch.
+func (r *nativeRenderer) lookupFont(ch rune, mono bool) (*pdfFont, bool) {
+	font := r.main
+	if mono {
+		font = r.mono
+	}
+	// format characters, such as bidi marks and joiners, aren't drawn
+	if unicode.IsSpace(ch) || unicode.Is(unicode.Cf, ch) || font.has(ch) {
+		return font, true
+	}
+	for _, fallback := range r.fallbacks {
+		if fallback.has(ch) {
+			return fallback, true
+		}
+	}
+	if mono && r.main.has(ch) {
+		return r.main, true
+	}
+	return font, false
+}
+
+// covered reports whether any font has ch.
+func (r *nativeRenderer) covered(ch rune) bool {
+	_, ok := r.lookupFont(ch, false)
+	return ok
+}
+
+// setFont sets text in font and style.
+func (r *nativeRenderer) setFont(font *pdfFont, style textStyle) {
+	s := ""
+	if style.bold {
+		s += "B"
+	}
+	if style.italic {
+		s += "I"
+	}
+	size := cmp.Or(style.size, nativeFontSize)
+	current := fmt.Sprint(font.family, s, size)
+	if current != r.current {
+		r.pdf.SetFont(font.family, s, size)
+		r.current = current
+	}
+}
+
+// measure returns the width of text in font and style.
+func (r *nativeRenderer) measure(font *pdfFont, style textStyle, text string) float64 {
+	r.setFont(font, style)
+	return r.pdf.GetStringWidth(text)
+}
+
+// spaceWidth returns the width of the space before w.
+func (r *nativeRenderer) spaceWidth(w word) float64 {
+	return r.measure(w.runs[0].font, w.runs[0].style, " ")
+}
+
+// breakLines breaks words into lines at most width wide, breaking words wider than lines where they overflow.
+func (r *nativeRenderer) breakLines(words []word, width float64) [][]word {
+	var lines [][]word
+	var line []word
+	used := 0.0
+	for _, w := range words {
+		if w.br {
+			if len(line) > 0 || !w.soft {
+				lines = append(lines, line)
+			}
+			line, used = nil, 0
+			continue
+		}
+		add := w.width
+		if len(line) > 0 && w.space {
+			add += r.spaceWidth(w)
+		}
+		if len(line) > 0 && used+add > width {
+			lines = append(lines, line)
+			line, add = nil, w.width
+		}
+		if len(line) == 0 {
+			for w.width > width {
+				first, rest := r.splitWord(w, width)
+				if len(rest.runs) == 0 {
+					break
+				}
+				lines = append(lines, []word{first})
+				w = rest
+			}
+			used, add = 0, w.width
+		}
+		line = append(line, w)
+		used += add
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitWord splits w after the characters fitting in width, at least one.
+func (r *nativeRenderer) splitWord(w word, width float64) (word, word) {
+	first, rest := word{space: w.space}, word{}
+	used, split := 0.0, false
+	for _, run := range w.runs {
+		if split {
+			rest.runs = append(rest.runs, run)
+			continue
+		}
+		for i, ch := range run.text {
+			used += r.measure(run.font, run.style, string(ch))
+			if used > width && (i > 0 || len(first.runs) > 0) {
+				if i > 0 {
+					first.runs = append(first.runs, glyphRun{text: run.text[:i:i], style: run.style, font: run.font})
+				}
+				rest.runs = append(rest.runs, glyphRun{text: run.text[i:], style: run.style, font: run.font})
+				split = true
+				break
+			}
+		}
+		if !split {
+			first.runs = append(first.runs, run)
+		}
+	}
+	r.measureWord(&first)
+	r.measureWord(&rest)
+	return first, rest
+}
+
+// lineHeight returns the height of a line, following the size of its largest text.
+func (r *nativeRenderer) lineHeight(line []word) float64 {
+	size := 0.0
+	for _, w := range line {
+		for _, run := range w.runs {
+			size = max(size, cmp.Or(run.style.size, nativeFontSize))
+		}
+	}
+	return cmp.Or(size, nativeFontSize) * nativeLeading
+}
+
+// drawLine sets a line of words between x0 and x1 with its top at y, aligned to align,
+// in display order in a paragraph written right to left if rtl, returning its baseline.
+func (r *nativeRenderer) drawLine(line []word, x0, x1, y, height float64, align string, rtl bool) float64 {
+	// the characters of the line, and the runs they're set in
+	var text []rune
+	var runs []*glyphRun
+	size := 0.0
+	for i := range line {
+		w := &line[i]
+		if i > 0 && w.space && len(w.runs) > 0 {
+			text = append(text, ' ')
+			runs = append(runs, &w.runs[0])
+		}
+		for j := range w.runs {
+			run := &w.runs[j]
+			size = max(size, cmp.Or(run.style.size, nativeFontSize))
+			for _, ch := range run.text {
+				text = append(text, ch)
+				runs = append(runs, run)
+			}
+		}
+	}
+	size = cmp.Or(size, nativeFontSize)
+	baseline := y + (height-size)/2 + size*0.8
+
+	// segments of characters set in the same font and style, next to each other in display order
+	type segment struct {
+		text  []rune
+		run   *glyphRun
+		width float64
+	}
+	var segments []segment
+	levels := bidiLevels(text, rtl)
+	for _, i := range visualOrder(levels) {
+		ch := text[i]
+		if m, ok := mirrored[ch]; ok && levels[i]%2 == 1 {
+			ch = m
+		}
+		if n := len(segments); n > 0 && segments[n-1].run.font == runs[i].font && segments[n-1].run.style == runs[i].style {
+			segments[n-1].text = append(segments[n-1].text, ch)
+			continue
+		}
+		segments = append(segments, segment{text: []rune{ch}, run: runs[i]})
+	}
+	total := 0.0
+	for i := range segments {
+		segments[i].width = r.measure(segments[i].run.font, segments[i].run.style, string(segments[i].text))
+		total += segments[i].width
+	}
+
+	x := x0
+	switch align {
+	case "right":
+		x = x1 - total
+	case "center":
+		x = x0 + (x1-x0-total)/2
+	}
+	for _, s := range segments {
+		style := s.run.style
+		r.setFont(s.run.font, style)
+		if style.href != "" || style.link != 0 {
+			r.pdf.SetTextColor(0, 0, 200)
+		} else {
+			r.pdf.SetTextColor(0, 0, 0)
+		}
+		r.pdf.Text(x, baseline, string(s.text))
+		switch {
+		case style.href != "":
+			r.pdf.LinkString(x, y, s.width, height, style.href)
+		case style.link != 0:
+			r.pdf.Link(x, y, s.width, height, style.link)
+		}
+		x += s.width
+	}
+	return baseline
+}
@@ -0,0 +1,183 @@
+package illuminated
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestWriteNativePDF(t *testing.T) {
+	dir := t.TempDir()
+	file, err := os.Create(path.Join(dir, "picture.png"))
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(file, image.NewRGBA(image.Rect(0, 0, 40, 20))))
+	require.NoError(t, file.Close())
+
+	src := path.Join(dir, "fa.Guide.html")
+	require.NoError(t, os.WriteFile(src, []byte(`<!DOCTYPE html>
+<html lang="fa"><head><title>راهنما</title></head><body>
+<nav class="toc" role="doc-toc"><ul><li><a href="#install">نصب</a></li></ul></nav>
+<h1 id="install">نصب <code>Lantern</code></h1>
+<p>برای نصب <a href="https://getlantern.org">Lantern</a> را دانلود کنید. <a href="#faq">پرسش‌ها</a></p>
+<p><img src="picture.png" alt="تصویر"></p>
+<ol start="3"><li>یک</li><li>دو<ul><li><input type="checkbox" checked> سه</li></ul></li></ol>
+<blockquote><p>نقل قول</p></blockquote>
+<h2 id="faq">پرسش‌ها</h2>
+<table><thead><tr><th>نام</th><th align="right">مقدار</th></tr></thead><tbody><tr><td>中文</td><td>123</td></tr></tbody></table>
+<pre><code>lantern --help
+</code></pre>
+<div class="page-break"></div>
+<h1>日本語のテキスト</h1>
+<p>これは改行をテストするための長い日本語の段落です。これは改行をテストするための長い日本語の段落です。</p>
+<p><img src="missing.jpg"><img src="https://example.com/remote.png"></p>
+</body></html>`), 0o644))
+
+	out := path.Join(dir, "fa.Guide.pdf")
+	// Persian is set in DejaVu Sans, and Japanese and Chinese in Noto Sans SC if installed
+	profile := LanguageProfile{
+		MainFont:      path.Join(dejaVuDir(t), "DejaVuSansCondensed.ttf"),
+		FallbackFonts: []string{DefaultLanguageProfiles.Profile("zh").MainFont},
+		Direction:     "rtl",
+	}
+	layout := PDFLayout{TOCDepth: 2, NumberSections: true, Header: "راهنما", Cover: true, CoverImage: path.Join(dir, "picture.png")}
+	require.NoError(t, WriteNativePDF(src, out, dir, Metadata{Title: "راهنما"}, profile, layout))
+
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	pdf := string(b)
+	require.True(t, strings.HasPrefix(pdf, "%PDF-"))
+	require.Contains(t, pdf, "/URI (https://getlantern.org)")
+	require.Contains(t, pdf, "/Outlines")
+	require.Contains(t, pdf, "/Subtype /Image")
+	require.Contains(t, pdf, "/FontFile2") // embedded TrueType fonts
+	// the cover, the table of contents, and a page for each chapter, the page break not adding another
+	require.Len(t, regexp.MustCompile(`/Type /Page\b`).FindAllString(pdf, -1), 4)
+	// internal links from the table of contents and the text
+	require.GreaterOrEqual(t, len(regexp.MustCompile(`/Dest \[`).FindAllString(pdf, -1)), 4)
+
+	// the HTML is left unchanged
+	doc, err := os.ReadFile(src)
+	require.NoError(t, err)
+	require.Contains(t, string(doc), `role="doc-toc"`)
+
+	// PDFs of profiles with the native engine are typeset natively
+	profile.PDFEngine = PDFEngineNative
+	require.NoError(t, os.Remove(out))
+	require.NoError(t, WritePDF(src, out, dir, Metadata{}, profile, PDFLayout{}))
+	require.FileExists(t, out)
+
+	layout.Margins = "wide"
+	require.Error(t, WriteNativePDF(src, out, dir, Metadata{}, profile, layout))
+}
+
+func TestPageMargin(t *testing.T) {
+	for margins, want := range map[string]float64{"": 72, "1in": 72, "2.54cm": 72, "10mm": 720 / 25.4, "36pt": 36, "0pt": 0} {
+		got, err := pageMargin(margins)
+		require.NoError(t, err)
+		require.InDelta(t, want, got, 0.001, margins)
+	}
+	for _, margins := range []string{"2", "cm", "-1in", "2 furlongs"} {
+		_, err := pageMargin(margins)
+		require.Error(t, err, margins)
+	}
+}
+
+func TestNativeHeadings(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<h1>A</h1><h2>A.1</h2><h3>A.1.1</h3><h2>A.2</h2><h1>B  <em>b</em></h1><h3>B.0.1</h3>`))
+	require.NoError(t, err)
+	var numbers, texts []string
+	for _, heading := range nativeHeadings(doc, true) {
+		numbers = append(numbers, heading.number)
+		texts = append(texts, heading.text)
+	}
+	require.Equal(t, []string{"1", "1.1", "1.1.1", "1.2", "2", "2.0.1"}, numbers)
+	require.Equal(t, "B b", texts[4])
+	require.Empty(t, nativeHeadings(doc, false)[0].number)
+}
+
+func TestWriteNativePDFReproducible(t *testing.T) {
+	dir := t.TempDir()
+	src := path.Join(dir, "en.Home.html")
+	require.NoError(t, os.WriteFile(src, []byte(`<html lang="en"><body><h1>Home</h1><p>Hello</p></body></html>`), 0o644))
+	meta := Metadata{
+		Title:    "Home",
+		Author:   "Lantern",
+		Subject:  "User guide",
+		Keywords: []string{"vpn"},
+		Lang:     "en",
+		Date:     time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	var pdfs []string
+	for _, name := range []string{"first.pdf", "second.pdf"} {
+		out := path.Join(dir, name)
+		require.NoError(t, WriteNativePDF(src, out, dir, meta, LanguageProfile{}, PDFLayout{TOCDepth: 2}))
+		b, err := os.ReadFile(out)
+		require.NoError(t, err)
+		pdfs = append(pdfs, string(b))
+	}
+	require.Equal(t, pdfs[0], pdfs[1])
+	require.Contains(t, pdfs[0], "/Author")
+	require.Contains(t, pdfs[0], "/CreationDate (D:20240301120000")
+	require.Contains(t, pdfs[0], "<dc:language><rdf:Bag><rdf:li>en</rdf:li></rdf:Bag></dc:language>")
+}
+
+// dejaVuDir returns the directory of the DejaVu Sans fonts distributed with fpdf, which have Arabic letters
+// and their presentation forms.
+func dejaVuDir(t *testing.T) string {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "codeberg.org/go-pdf/fpdf").Output()
+	require.NoError(t, err)
+	return path.Join(strings.TrimSpace(string(out)), "font")
+}
+
+// missingGlyphs typesets the HTML body with the fonts of profile, returning the characters no font has.
+func missingGlyphs(t *testing.T, profile LanguageProfile, body string) []string {
+	doc, err := html.Parse(strings.NewReader(body))
+	require.NoError(t, err)
+	fonts, err := readProfileFonts(profile)
+	require.NoError(t, err)
+	r, err := newNativeRenderer(cmp.Or(profile.Direction, "ltr"), PDFLayout{}, Metadata{}, ".", fonts, nil)
+	require.NoError(t, err)
+	var missing []string
+	r.warn = func(msg string, args ...any) {
+		missing = append(missing, fmt.Sprint(args[1]))
+	}
+	require.NoError(t, r.render(doc, nil))
+	return missing
+}
+
+func TestNativePDFGlyphs(t *testing.T) {
+	persian := `<h1>نصب</h1><p>برای نصب Lantern را دانلود کنید.</p>`
+	// the Go fonts have no Arabic letters, each missing letter warned about once
+	missing := missingGlyphs(t, LanguageProfile{Direction: "rtl"}, persian+persian)
+	require.Contains(t, missing, "ن")
+	require.Len(t, missing, len(slices.Compact(slices.Sorted(slices.Values(missing)))))
+
+	profile := LanguageProfile{MainFont: path.Join(dejaVuDir(t), "DejaVuSansCondensed.ttf"), Direction: "rtl"}
+	require.Empty(t, missingGlyphs(t, profile, persian))
+
+	// characters outside the Basic Multilingual Plane are replaced, warned about once each
+	missing = missingGlyphs(t, LanguageProfile{}, `<p>😀 Lantern 😀 𠀋</p>`)
+	require.Equal(t, []string{"😀", "𠀋"}, slices.DeleteFunc(missing, func(ch string) bool { return ch != "😀" && ch != "𠀋" }))
+
+	for _, lang := range []string{"zh", "ja"} {
+		t.Run(lang, func(t *testing.T) {
+			profile := DefaultLanguageProfiles.Profile(lang)
+			if _, ok := indexFonts(FontDirs).find(profile.MainFont, ""); !ok {
+				t.Skipf("%s not installed", profile.MainFont)
+			}
+			require.Empty(t, missingGlyphs(t, profile, `<h1>中文</h1><p>日本語のテキスト、これは段落です。</p>`))
+		})
+	}
+}
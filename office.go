@@ -0,0 +1,217 @@
+package illuminated
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Word processor document formats pandoc writes, by the extension of their files.
+const (
+	FormatDOCX = "docx"
+	FormatODT  = "odt"
+)
+
+// WriteOffice calls pandoc to output an editable DOCX or ODT document, by the extension of outPath,
+// from a source HTML file with the properties of meta, in the text direction of profile. Styles are taken from referenceDoc,
+// or pandoc's default reference document if empty, with their fonts replaced by the fonts of profile.
+// Each chapter after the first starts on a new page, and local images in resourcePath are embedded.
+func WriteOffice(sourcePath, outPath, resourcePath, referenceDoc string, meta Metadata, profile LanguageProfile) error {
+	format := strings.TrimPrefix(path.Ext(outPath), ".")
+	if format != FormatDOCX && format != FormatODT {
+		return fmt.Errorf("unknown word processor format %q, expected %s or %s", format, FormatDOCX, FormatODT)
+	}
+	tmp, err := os.MkdirTemp("", "illuminated-"+format+"-")
+	if err != nil {
+		return fmt.Errorf("create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	intermediatePath := path.Join(tmp, path.Base(sourcePath))
+	err = TransformFile(sourcePath, intermediatePath, OfficeTransforms(profile.Direction)...)
+	if err != nil {
+		return fmt.Errorf("prepare HTML for %s: %w", format, err)
+	}
+	filterPath, err := writeFilter(tmp)
+	if err != nil {
+		return err
+	}
+
+	if referenceDoc == "" {
+		referenceDoc = path.Join(tmp, "default."+format)
+		err = defaultReferenceDoc(format, referenceDoc)
+		if err != nil {
+			return err
+		}
+	}
+	styledPath := path.Join(tmp, "reference."+format)
+	err = styleReferenceDoc(referenceDoc, styledPath, format, profile)
+	if err != nil {
+		return fmt.Errorf("set fonts of reference document %q: %w", referenceDoc, err)
+	}
+
+	args := []string{"--lua-filter", filterPath, "--reference-doc", styledPath}
+	if profile.Direction != "" {
+		args = append(args, "--metadata", "dir="+profile.Direction)
+	}
+	meta.Title = cmp.Or(meta.Title, strings.TrimSuffix(path.Base(sourcePath), ".html"))
+	err = pandoc(intermediatePath, outPath, resourcePath, meta, args...)
+	if err != nil {
+		return err
+	}
+	slog.Info("generated "+format+" successfully", "name", outPath)
+	return nil
+}
+
+// defaultReferenceDoc writes pandoc's default reference document of format to outPath.
+func defaultReferenceDoc(format, outPath string) error {
+	cmd := exec.Command("pandoc", "-o", outPath, "--print-default-data-file", "reference."+format)
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("pandoc: write default reference document: %w", err)
+	}
+	return nil
+}
+
+// styleReferenceDoc copies the reference document at src to dst,
+// replacing the fonts of its styles with those of profile and, for ODT, setting its text direction.
+func styleReferenceDoc(src, dst, format string, profile LanguageProfile) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("open reference document: %w", err)
+	}
+	defer r.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("create reference document: %w", err)
+	}
+	defer out.Close()
+
+	// styles of DOCX documents are in the word directory
+	stylesName := "styles.xml"
+	if format == FormatDOCX {
+		stylesName = "word/styles.xml"
+	}
+	w := zip.NewWriter(out)
+	for _, f := range r.File {
+		if f.Name != stylesName {
+			err = w.Copy(f)
+			if err != nil {
+				return fmt.Errorf("copy %q: %w", f.Name, err)
+			}
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("open %q: %w", f.Name, err)
+		}
+		styles, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("read %q: %w", f.Name, err)
+		}
+		if format == FormatDOCX {
+			styles = docxStyles(styles, profile)
+		} else {
+			styles = odtStyles(styles, profile)
+		}
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: f.Name, Method: f.Method, Modified: f.Modified})
+		if err != nil {
+			return fmt.Errorf("create %q: %w", f.Name, err)
+		}
+		_, err = fw.Write(styles)
+		if err != nil {
+			return fmt.Errorf("write %q: %w", f.Name, err)
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("write reference document: %w", err)
+	}
+	return nil
+}
+
+var (
+	reDOCXFonts      = regexp.MustCompile(`<w:rFonts\b[^>]*/>`)
+	reDOCXVerbatim   = regexp.MustCompile(`(?s)<w:style\b[^>]*w:styleId="(?:VerbatimChar|SourceCode)".*?</w:style>`)
+	reODTFonts       = regexp.MustCompile(`style:font-name(-asian|-complex)?="[^"]*"`)
+	reODTSource      = regexp.MustCompile(`(?s)<style:style\b[^>]*style:name="(?:Source_Text|Preformatted_20_Text)".*?</style:style>`)
+	reODTDefault     = regexp.MustCompile(`(?s)<style:default-style style:family="paragraph">.*?</style:default-style>`)
+	reODTParagraph   = regexp.MustCompile(`<style:paragraph-properties\b`)
+	reODTWritingMode = regexp.MustCompile(` style:writing-mode="[^"]*"`)
+)
+
+// docxStyles replaces the fonts of the styles of a DOCX styles.xml with the main font of profile,
+// and the fonts of code with its mono font. Direction is set by pandoc.
+func docxStyles(styles []byte, profile LanguageProfile) []byte {
+	fonts := func(font string) []byte {
+		font = html.EscapeString(font)
+		return fmt.Appendf(nil, `<w:rFonts w:ascii="%s" w:hAnsi="%s" w:eastAsia="%s" w:cs="%s"/>`, font, font, font, font)
+	}
+	if profile.MainFont != "" {
+		styles = reDOCXFonts.ReplaceAll(styles, fonts(profile.MainFont))
+	}
+	if profile.MonoFont != "" {
+		styles = reDOCXVerbatim.ReplaceAllFunc(styles, func(style []byte) []byte {
+			return reDOCXFonts.ReplaceAll(style, fonts(profile.MonoFont))
+		})
+	}
+	return styles
+}
+
+// odtStyles replaces the fonts of the styles of an ODT styles.xml with the main font of profile,
+// and the fonts of code with its mono font, declaring both. Paragraphs are written right to left
+// if the direction of profile is rtl.
+func odtStyles(styles []byte, profile LanguageProfile) []byte {
+	var decls bytes.Buffer
+	for _, font := range []string{profile.MainFont, profile.MonoFont} {
+		if font != "" && !bytes.Contains(styles, fmt.Appendf(nil, `style:name="%s"`, html.EscapeString(font))) {
+			font = html.EscapeString(font)
+			fmt.Fprintf(&decls, `<style:font-face style:name="%s" svg:font-family="&apos;%s&apos;"/>`, font, font)
+		}
+	}
+	switch {
+	case bytes.Contains(styles, []byte("<office:font-face-decls>")):
+		styles = bytes.Replace(styles, []byte("<office:font-face-decls>"), append([]byte("<office:font-face-decls>"), decls.Bytes()...), 1)
+	case bytes.Contains(styles, []byte("<office:font-face-decls/>")):
+		styles = bytes.Replace(styles, []byte("<office:font-face-decls/>"), fmt.Appendf(nil, "<office:font-face-decls>%s</office:font-face-decls>", decls.Bytes()), 1)
+	default:
+		styles = bytes.Replace(styles, []byte("<office:styles>"), fmt.Appendf(nil, "<office:font-face-decls>%s</office:font-face-decls><office:styles>", decls.Bytes()), 1)
+	}
+	fonts := func(font string) func([]byte) []byte {
+		return func(attr []byte) []byte {
+			name, _, _ := bytes.Cut(attr, []byte("="))
+			return fmt.Appendf(nil, `%s="%s"`, name, html.EscapeString(font))
+		}
+	}
+	if profile.MainFont != "" {
+		styles = reODTFonts.ReplaceAllFunc(styles, fonts(profile.MainFont))
+	}
+	if profile.MonoFont != "" {
+		styles = reODTSource.ReplaceAllFunc(styles, func(style []byte) []byte {
+			return reODTFonts.ReplaceAllFunc(style, fonts(profile.MonoFont))
+		})
+	}
+	if profile.Direction == "rtl" {
+		styles = reODTDefault.ReplaceAllFunc(styles, func(style []byte) []byte {
+			style = reODTWritingMode.ReplaceAll(style, nil)
+			if reODTParagraph.Match(style) {
+				return reODTParagraph.ReplaceAll(style, []byte(`<style:paragraph-properties style:writing-mode="rl-tb"`))
+			}
+			return bytes.Replace(style, []byte(`">`), []byte(`"><style:paragraph-properties style:writing-mode="rl-tb"/>`), 1)
+		})
+	}
+	// chapters and page-break elements start pages with paragraphs of this style
+	return bytes.Replace(styles, []byte("</office:styles>"), []byte(
+		`<style:style style:name="Pagebreak" style:family="paragraph"><style:paragraph-properties fo:break-before="page"/></style:style></office:styles>`,
+	), 1)
+}
@@ -0,0 +1,116 @@
+package illuminated
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDOCXStyles(t *testing.T) {
+	styles := `<w:styles>` +
+		`<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:asciiTheme="minorHAnsi" w:cstheme="minorBidi" /><w:sz w:val="24" /></w:rPr></w:rPrDefault></w:docDefaults>` +
+		`<w:style w:type="paragraph" w:styleId="Heading1"><w:rPr><w:rFonts w:asciiTheme="majorHAnsi" /></w:rPr></w:style>` +
+		`<w:style w:type="character" w:customStyle="1" w:styleId="VerbatimChar"><w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" /></w:rPr></w:style>` +
+		`</w:styles>`
+	got := string(docxStyles([]byte(styles), LanguageProfile{MainFont: "Vazirmatn", MonoFont: "Noto Sans Mono"}))
+	main := `<w:rFonts w:ascii="Vazirmatn" w:hAnsi="Vazirmatn" w:eastAsia="Vazirmatn" w:cs="Vazirmatn"/>`
+	require.Equal(t, `<w:styles>`+
+		`<w:docDefaults><w:rPrDefault><w:rPr>`+main+`<w:sz w:val="24" /></w:rPr></w:rPrDefault></w:docDefaults>`+
+		`<w:style w:type="paragraph" w:styleId="Heading1"><w:rPr>`+main+`</w:rPr></w:style>`+
+		`<w:style w:type="character" w:customStyle="1" w:styleId="VerbatimChar"><w:rPr>`+
+		`<w:rFonts w:ascii="Noto Sans Mono" w:hAnsi="Noto Sans Mono" w:eastAsia="Noto Sans Mono" w:cs="Noto Sans Mono"/></w:rPr></w:style>`+
+		`</w:styles>`, got)
+
+	require.Equal(t, styles, string(docxStyles([]byte(styles), LanguageProfile{})))
+}
+
+func TestODTStyles(t *testing.T) {
+	styles := `<office:document-styles><office:font-face-decls><style:font-face style:name="Liberation Serif"/></office:font-face-decls>` +
+		`<office:styles>` +
+		`<style:default-style style:family="paragraph"><style:paragraph-properties style:writing-mode="page"/>` +
+		`<style:text-properties style:font-name="Liberation Serif" style:font-name-complex="Lohit Devanagari"/></style:default-style>` +
+		`<style:style style:name="Source_Text" style:family="text"><style:text-properties style:font-name="Courier New"/></style:style>` +
+		`</office:styles></office:document-styles>`
+	got := string(odtStyles([]byte(styles), LanguageProfile{MainFont: "Vazirmatn", MonoFont: "Noto Sans Mono", Direction: "rtl"}))
+	require.Equal(t, `<office:document-styles><office:font-face-decls>`+
+		`<style:font-face style:name="Vazirmatn" svg:font-family="&apos;Vazirmatn&apos;"/>`+
+		`<style:font-face style:name="Noto Sans Mono" svg:font-family="&apos;Noto Sans Mono&apos;"/>`+
+		`<style:font-face style:name="Liberation Serif"/></office:font-face-decls>`+
+		`<office:styles>`+
+		`<style:default-style style:family="paragraph"><style:paragraph-properties style:writing-mode="rl-tb"/>`+
+		`<style:text-properties style:font-name="Vazirmatn" style:font-name-complex="Vazirmatn"/></style:default-style>`+
+		`<style:style style:name="Source_Text" style:family="text"><style:text-properties style:font-name="Noto Sans Mono"/></style:style>`+
+		`<style:style style:name="Pagebreak" style:family="paragraph"><style:paragraph-properties fo:break-before="page"/></style:style>`+
+		`</office:styles></office:document-styles>`, got)
+
+	// left to right documents keep their direction, and fonts are declared where there are no declarations
+	got = string(odtStyles([]byte(`<office:styles><style:default-style style:family="paragraph"></style:default-style></office:styles>`),
+		LanguageProfile{MainFont: "Noto Sans", Direction: "ltr"}))
+	require.Equal(t, `<office:font-face-decls><style:font-face style:name="Noto Sans" svg:font-family="&apos;Noto Sans&apos;"/></office:font-face-decls>`+
+		`<office:styles><style:default-style style:family="paragraph"></style:default-style>`+
+		`<style:style style:name="Pagebreak" style:family="paragraph"><style:paragraph-properties fo:break-before="page"/></style:style>`+
+		`</office:styles>`, got)
+}
+
+func TestStyleReferenceDoc(t *testing.T) {
+	dir := t.TempDir()
+	src := path.Join(dir, "reference.docx")
+	f, err := os.Create(src)
+	require.NoError(t, err)
+	w := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"[Content_Types].xml": `<Types/>`,
+		"word/styles.xml":     `<w:styles><w:rFonts w:ascii="Cambria"/></w:styles>`,
+		"styles.xml":          `<w:styles><w:rFonts w:ascii="Cambria"/></w:styles>`,
+	} {
+		fw, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	dst := path.Join(dir, "styled.docx")
+	require.NoError(t, styleReferenceDoc(src, dst, FormatDOCX, LanguageProfile{MainFont: "Noto Sans"}))
+	r, err := zip.OpenReader(dst)
+	require.NoError(t, err)
+	defer r.Close()
+	files := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	require.Equal(t, `<Types/>`, files["[Content_Types].xml"])
+	require.Contains(t, files["word/styles.xml"], `w:ascii="Noto Sans"`)
+	require.Equal(t, `<w:styles><w:rFonts w:ascii="Cambria"/></w:styles>`, files["styles.xml"])
+
+	require.Error(t, styleReferenceDoc(path.Join(dir, "missing.docx"), dst, FormatDOCX, LanguageProfile{}))
+}
+
+func TestWriteOffice(t *testing.T) {
+	dir := t.TempDir()
+	err := WriteOffice(path.Join(dir, "en.Home.html"), path.Join(dir, "en.Home.rtf"), dir, "", Metadata{Title: "Home"}, LanguageProfile{})
+	require.ErrorContains(t, err, `unknown word processor format "rtf"`)
+
+	if _, err := exec.LookPath("pandoc"); err != nil {
+		t.Skip("pandoc not installed")
+	}
+	source := path.Join(dir, "fa.Home.html")
+	require.NoError(t, os.WriteFile(source, []byte(`<html lang="fa"><body><h1>خانه</h1><p>سلام</p></body></html>`), 0o644))
+	for _, format := range []string{FormatDOCX, FormatODT} {
+		outPath := path.Join(dir, "fa.Home."+format)
+		err = WriteOffice(source, outPath, dir, "", Metadata{Title: "خانه"}, LanguageProfile{MainFont: "Vazirmatn", Direction: "rtl"})
+		require.NoError(t, err)
+		require.FileExists(t, outPath)
+	}
+}
@@ -1,17 +1,18 @@
 package illuminated
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path"
-	"regexp"
 	"strings"
-	"time"
 
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 // writeJSON writes a map[string]string to path as JSON.
@@ -33,6 +34,20 @@ func writeJSON(path string, data map[string]string) error {
 	return nil
 }
 
+// readHTML parses the HTML document in the file at path.
+func readHTML(path string) (*html.Node, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %q: %w", path, err)
+	}
+	defer file.Close()
+	doc, err := html.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("parse %q: %w", path, err)
+	}
+	return doc, nil
+}
+
 // writeHTML writes an HTML document to file at path.
 func writeHTML(path string, doc *html.Node) error {
 	file, err := os.Create(path)
@@ -47,73 +62,87 @@ func writeHTML(path string, doc *html.Node) error {
 	return nil
 }
 
-// WritePDF calls pandoc to output a PDF from a source file (HTML expected).
+// WritePDF calls pandoc to output a PDF from a source file (HTML expected),
+// typeset with the fonts, direction and engine of profile and laid out with layout.
+// Each chapter, a level 1 heading, starts on a new page.
 // ResourcePath is used to specify the path for local resources (images, etc.),
 // while internet accessible resources will be fetched automatically.
-func WritePDF(sourcePath, outPath, resourcePath, title string) error {
+// Profiles with the native engine are typeset by WriteNativePDF instead.
+func WritePDF(sourcePath, outPath, resourcePath string, meta Metadata, profile LanguageProfile, layout PDFLayout) error {
+	if profile.PDFEngine == PDFEngineNative {
+		return WriteNativePDF(sourcePath, outPath, resourcePath, meta, profile, layout)
+	}
+	// the HTML prepared for PDF, the filter and other files pandoc reads are written to a temporary directory,
+	// leaving the HTML output unchanged
+	tmp, err := os.MkdirTemp("", "illuminated-pdf-")
+	if err != nil {
+		return fmt.Errorf("create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	intermediatePath := path.Join(tmp, path.Base(sourcePath))
+	err = TransformFile(sourcePath, intermediatePath, PDFTransforms(profile.Direction)...)
+	if err != nil {
+		return fmt.Errorf("prepare HTML for PDF: %w", err)
+	}
+	filterPath, err := writeFilter(tmp)
+	if err != nil {
+		return err
+	}
+	layoutArgs, err := layout.pandocArgs(filterPath, tmp)
+	if err != nil {
+		return err
+	}
+	args := append(layoutArgs, profile.pandocArgs()...)
+	meta.Title = cmp.Or(meta.Title, strings.TrimSuffix(path.Base(sourcePath), ".html"))
+	err = pandoc(intermediatePath, outPath, resourcePath, meta, args...)
+	if err != nil {
+		return err
+	}
+	slog.Info("generated pdf successfully",
+		"name", outPath,
+	)
+	return nil
+}
+
+// writeFilter writes the pandoc filter breaking pages at chapters to dir, returning its path.
+func writeFilter(dir string) (string, error) {
+	filter, err := DefaultTemplates.ReadFile(path.Join("templates", FilterFileChapters))
+	if err != nil {
+		return "", fmt.Errorf("read pandoc filter: %w", err)
+	}
+	filterPath := path.Join(dir, FilterFileChapters)
+	err = os.WriteFile(filterPath, filter, DefaultFilePermissions)
+	if err != nil {
+		return "", fmt.Errorf("write pandoc filter: %w", err)
+	}
+	return filterPath, nil
+}
+
+// pandoc calls pandoc to convert the HTML file at sourcePath to outPath, in the format of its extension,
+// with the properties of meta and any other args. Local resources are found in resourcePath.
+// Pandoc and the programs it runs date output by meta, through SOURCE_DATE_EPOCH, so output is reproducible.
+func pandoc(sourcePath, outPath, resourcePath string, meta Metadata, args ...string) error {
 	slog.Debug("calling pandoc to write from HTML", "source", sourcePath, "out", outPath, "resourcePath", resourcePath)
 	// first verify that pandoc is installed
 	_, err := exec.LookPath("pandoc")
 	if err != nil {
 		return fmt.Errorf("pandoc not found in PATH, install and try again: %w", err)
 	}
-
-	if title == "" {
-		title = strings.TrimSuffix(path.Base(sourcePath), ".html")
-	}
 	if resourcePath == "" {
 		slog.Debug("no resource path provided, assuming '.'")
 		resourcePath = "."
 	}
 
-	parts := strings.Split(path.Base(sourcePath), ".")
-	if len(parts) < 2 {
-		return fmt.Errorf("sourcePath %q does not contain a language prefix", sourcePath)
-	}
-	lang := parts[0]
-
 	// NOTE: Code 43 errors are likely due to LaTeX pdf engine
 	// and unicode support or fonts.
-	var pdfEngine, mainfont, dir string
-	switch lang {
-	case "en":
-		pdfEngine = "xelatex"
-		mainfont = "Noto Sans"
-		dir = "ltr"
-	case "ru":
-		pdfEngine = "xelatex"
-		mainfont = "Noto Sans"
-		dir = "ltr"
-	case "fa", "ar":
-		pdfEngine = "xelatex"
-		mainfont = "Noto Sans Arabic"
-		dir = "rtl"
-	case "zh":
-		pdfEngine = "xelatex"
-		mainfont = "Noto Sans CJK SC"
-		dir = "ltr"
-	default:
-		return fmt.Errorf(
-			"unsupported language prefix %q in sourcePath %q",
-			lang, sourcePath,
-		)
-	}
-	err = format(sourcePath, dir)
-	if err != nil {
-		return fmt.Errorf("format breaks in HTML: %w", err)
-	}
-
-	cmd := exec.Command(
-		"pandoc",
-		"--metadata", fmt.Sprintf("title=%s", path.Base(title)),
-		"--metadata", fmt.Sprintf("date=%s", time.Now().Format("2006-01-02")),
-		"--toc",
-		"--resource-path", resourcePath,
-		"--pdf-engine", pdfEngine,
-		"--variable", fmt.Sprintf("mainfont=%s", mainfont),
-		"--variable", fmt.Sprintf("lang=%s", lang),
-		"--variable", fmt.Sprintf("dir=%s", strings.Trim(dir, " ")),
-		sourcePath, "-o", outPath,
+	meta.Title = path.Base(meta.Title)
+	args = append(meta.pandocArgs(), append([]string{"--resource-path", resourcePath}, args...)...)
+	args = append(args, sourcePath, "-o", outPath)
+	cmd := exec.Command("pandoc", args...)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("SOURCE_DATE_EPOCH=%d", meta.date().Unix()),
+		// LaTeX dates documents by SOURCE_DATE_EPOCH only if forced
+		"FORCE_SOURCE_DATE=1",
 	)
 	slog.Debug("pandoc command", "args", cmd.Args)
 	err = cmd.Run()
@@ -132,142 +161,135 @@ func WritePDF(sourcePath, outPath, resourcePath, title string) error {
 		}
 		return fmt.Errorf("pandoc: %w", err)
 	}
-	slog.Info("generated pdf successfully",
-		"name", outPath,
-	)
 	return nil
 }
 
-// format does html formatting:
-//   - adds a break before each <h1> tag in the HTML file
-//   - centers images
-func format(filepathHTML string, dir string) error {
-	htmlContent, err := os.ReadFile(filepathHTML)
-	if err != nil {
-		return fmt.Errorf("read %q: %w", filepathHTML, err)
-	}
+// JoinedHTMLPath returns the path of the joined HTML file for a given language.
+func JoinedHTMLPath(language string, projectDir string, name string) string {
+	return path.Join(projectDir, DefaultDirNameOutput, fmt.Sprintf("%s.%s.html", language, name))
+}
 
-	// Add a page break before every <h1> tag
-	modifiedHTML := strings.ReplaceAll(
-		string(htmlContent),
-		"<h1>",
-		"<br><h1>", // just use a break for now :(
-		// TODO: format in a way that LaTeX respects as full page break.
-		// add proper page break before each chapter.
-		// Investigate why I am unable to inject a page break into HTML
-		// that pancdoc will respect as a LaTeX page break.
-		//
-		// Graveyard of attempts:
-		// `<div style="display:block; clear:both; page-break-before:always;"></div><h1>`,
-		// `<p>\newpage</p><h1>`,
-		// `<div class="page-break"></div><h1>`,
-		// "\n\n\\newpage\n\n<h1>",
-		// `<b>\newpage</b><h1>`,
-		// `<h1 style="page-break-before: always;">`,
-	)
-	if dir == "rtl" {
-		modifiedHTML = strings.ReplaceAll(modifiedHTML, "<html>", `<html dir="rtl">`)
+// JoinHTML joins the HTML files of pages in language, written to the output directory of projectDir
+// as <language>.<page>.html, into a single document named name, and returns its path.
+// Pages are joined in the given order, each starting with an anchor named after it,
+// the target of links between pages. Elements of the heads of pages, such as stylesheets,
+// are merged into the head of the document, and element IDs already used by an anchor or an earlier page
+// are renamed with a numeric suffix, as are links to them within the page, avoiding IDs reserved by links, if any.
+// The files of pages are kept unless remove is set. This may be an intermediary step before PDF generation.
+func JoinHTML(language string, projectDir string, name string, pages []string, links *PageLinks, remove bool) (string, error) {
+	if len(pages) == 0 {
+		return "", errors.New("no pages to join")
 	}
-	modifiedHTML = strings.ReplaceAll(
-		modifiedHTML,
-		"<img ", `<img style="display: block; margin-left: auto; margin-right: auto;" `,
-	)
-	// NOTE: <p> tags around <img> tags for rtl languages causes problems.
-	modifiedHTML = strings.ReplaceAll(modifiedHTML, "<p><img ", `<img `)
-	modifiedHTML = strings.ReplaceAll(modifiedHTML, "</img></p>", `</img>`)
-
-	// TODO: do something safer?
-	// Write the modified HTML back to the file
-	err = os.WriteFile(filepathHTML, []byte(modifiedHTML), 0o644)
+	info := Language(language)
+	doc, err := html.Parse(strings.NewReader(fmt.Sprintf(
+		"<!DOCTYPE html>\n<html lang=\"%s\" dir=\"%s\">\n<head>\n<meta charset=\"UTF-8\">\n</head>\n<body>\n",
+		html.EscapeString(info.Tag), info.Direction,
+	)))
 	if err != nil {
-		return fmt.Errorf("write modified HTML: %w", err)
+		return "", fmt.Errorf("parse initial HTML structure: %w", err)
 	}
+	head, body := findElement(doc, atom.Head), findElement(doc, atom.Body)
 
-	return nil
-}
-
-// JoinHTML combines all HTML files for a given language (denoted by prefix)
-// into a single HTML file. This may be an intermediary step before PDF generation.
-func JoinHTML(language string, projectDir string, name string) (string, error) {
 	outputDir := path.Join(projectDir, DefaultDirNameOutput)
-	files, err := os.ReadDir(outputDir)
-	if err != nil {
-		return "", fmt.Errorf("read output directory: %w", err)
+	joinedFilePath := JoinedHTMLPath(language, projectDir, name)
+	merged := map[string]bool{}
+	used := map[string]bool{}
+	for _, page := range pages {
+		used[PageAnchor(page)] = true
 	}
-	joinedFilePath := path.Join(outputDir, fmt.Sprintf("%s.%s.html", language, name))
-	joinedFile, err := os.Create(joinedFilePath)
-	if err != nil {
-		return "", fmt.Errorf("create consolidated file: %w", err)
+	var files []string
+	for _, page := range pages {
+		filePath := path.Join(outputDir, fmt.Sprintf("%s.%s.html", language, page))
+		pageDoc, err := readHTML(filePath)
+		if err != nil {
+			return "", fmt.Errorf("read page %q: %w", page, err)
+		}
+		mergeHead(head, pageDoc, merged)
+		pageBody := findElement(pageDoc, atom.Body)
+		uniqueIDs(pageBody, used, links)
+		// mark the start of each page, the target of links between pages
+		body.AppendChild(&html.Node{
+			Type:     html.ElementNode,
+			Data:     "a",
+			DataAtom: atom.A,
+			Attr:     []html.Attribute{{Key: "id", Val: PageAnchor(page)}},
+		})
+		for pageBody.FirstChild != nil {
+			c := pageBody.FirstChild
+			pageBody.RemoveChild(c)
+			body.AppendChild(c)
+		}
+		body.AppendChild(&html.Node{Type: html.TextNode, Data: "\n"})
+		if filePath != joinedFilePath {
+			files = append(files, filePath)
+		}
 	}
-	defer joinedFile.Close()
 
-	var combinedBody strings.Builder
-	_, err = combinedBody.WriteString("<html>\n<head></head>\n<body>\n")
+	err = writeHTML(joinedFilePath, doc)
 	if err != nil {
-		return "", fmt.Errorf("write initial HTML structure: %w", err)
+		return "", fmt.Errorf("write joined HTML: %w", err)
 	}
-
-	reBodyStart := regexp.MustCompile(`<body[^>]*>`)
-	for _, file := range files {
-		if file.IsDir() {
-			slog.Warn("skipping unexpected directory in output dir", "name", file.Name())
-			continue
+	if remove {
+		for _, filePath := range files {
+			err = os.Remove(filePath)
+			if err != nil {
+				return "", fmt.Errorf("delete joined page: %w", err)
+			}
 		}
-		if !strings.HasPrefix(file.Name(), language+".") {
-			// slog.Debug("skipping on language mismatch",
-			// 	"name", file.Name(),
-			// 	"lang", language,
-			// )
-			continue
-		}
-		if !strings.HasSuffix(file.Name(), ".html") {
-			slog.Debug("skipping non-HTML file", "name", file.Name())
+	}
+	return joinedFilePath, nil
+}
+
+// mergeHead moves the head resources of a page to head, unless an identical element is already merged.
+func mergeHead(head, page *html.Node, merged map[string]bool) {
+	for _, n := range headResources(page) {
+		var b strings.Builder
+		err := html.Render(&b, n)
+		if err != nil || merged[b.String()] {
 			continue
 		}
+		merged[b.String()] = true
+		n.Parent.RemoveChild(n)
+		head.AppendChild(n)
+	}
+}
 
-		filePath := path.Join(outputDir, file.Name())
-		content, err := os.ReadFile(filePath)
-		if err != nil {
-			return "", fmt.Errorf("read file %v: %w", file.Name(), err)
+// uniqueIDs renames the element IDs of n already in used with a numeric suffix, along with links to them within n,
+// and adds the IDs of n to used. Renamed IDs are neither used nor reserved by links,
+// so they can't take the ID of an element of a later page.
+func uniqueIDs(n *html.Node, used map[string]bool, links *PageLinks) {
+	renamed := map[string]string{}
+	seen := map[string]bool{}
+	walkElements(n, func(e *html.Node) {
+		id := attr(e, "id")
+		if id == "" {
+			return
 		}
-
-		bodyStart := reBodyStart.FindStringIndex(string(content))
-		bodyEnd := strings.Index(string(content), "</body>")
-		if bodyStart == nil || bodyEnd == -1 {
-			slog.Warn("skipping file with no <body> tag",
-				"name", file.Name(),
-				"bodyStart", bodyStart,
-				"bodyEnd", bodyEnd,
-				"content", string(content),
-			)
-			continue
+		unique := id
+		if used[id] {
+			unique = uniqueID(id, func(id string) bool { return used[id] || links.reserved(id) })
 		}
-		// Extract content between <body> and </body>
-		bodyContent := string(content)[bodyStart[1]:bodyEnd]
-		_, err = combinedBody.WriteString(bodyContent)
-		if err != nil {
-			return "", fmt.Errorf("write body content from file %v: %w", file.Name(), err)
+		used[unique] = true
+		if unique != id {
+			setAttr(e, "id", unique)
+			// links within the page target the first element with the ID
+			if !seen[id] {
+				renamed[id] = unique
+			}
+			slog.Debug("renamed duplicate element ID in joined HTML", "id", id, "renamed", unique)
 		}
-		_, err = combinedBody.WriteString("\n")
-		if err != nil {
-			return "", fmt.Errorf("write newline after body content from file %v: %w", file.Name(), err)
+		seen[id] = true
+	})
+	if len(renamed) == 0 {
+		return
+	}
+	walkElements(n, func(e *html.Node) {
+		href := attr(e, "href")
+		if e.DataAtom != atom.A || !strings.HasPrefix(href, "#") {
+			return
 		}
-
-		err = os.Remove(filePath)
-		if err != nil {
-			return "", fmt.Errorf("delete file %v: %w", file.Name(), err)
+		if unique, ok := renamed[strings.TrimPrefix(href, "#")]; ok {
+			setAttr(e, "href", "#"+unique)
 		}
-	}
-
-	_, err = combinedBody.WriteString("</body>\n</html>")
-	if err != nil {
-		return "", fmt.Errorf("write closing HTML tags: %w", err)
-	}
-
-	// Write the combined HTML to the output file
-	_, err = joinedFile.WriteString(combinedBody.String())
-	if err != nil {
-		return "", fmt.Errorf("write to consolidated file: %w", err)
-	}
-	return joinedFilePath, nil
+	})
 }
@@ -1,7 +1,10 @@
 package illuminated
 
 import (
+	"os"
 	"os/exec"
+	"path"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -17,3 +20,70 @@ func TestCmdExists(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestJoinHTML(t *testing.T) {
+	dir := t.TempDir()
+	output := path.Join(dir, DefaultDirNameOutput)
+	require.NoError(t, os.MkdirAll(output, 0o755))
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(path.Join(output, name), []byte(content), 0o644))
+	}
+	write("fa.Home.html", `<html><head><meta charset="UTF-8"><link rel="stylesheet" href="a.css"></head>`+
+		`<body class="page"><h1 id="intro">خانه</h1><p><a href="#intro">بالا</a></p></body></html>`)
+	write("fa.Install.html", `<html><head><link rel="stylesheet" href="a.css"><link rel="stylesheet" href="b.css"></head>`+
+		`<body><h1 id="intro">نصب</h1><h2 id="Home">خانه</h2><p><a href="#intro">بالا</a> <a href="#Home">خانه</a></p></body></html>`)
+	write("fa.Draft.html", `<html><body><h1>پیش‌نویس</h1></body></html>`)
+
+	// pages are joined in the given order, not directory order
+	joined, err := JoinHTML("fa", dir, "Guide", []string{"Install", "Home"}, nil, false)
+	require.NoError(t, err)
+	require.Equal(t, JoinedHTMLPath("fa", dir, "Guide"), joined)
+	content, err := os.ReadFile(joined)
+	require.NoError(t, err)
+	doc := string(content)
+	require.True(t, strings.HasPrefix(doc, "<!DOCTYPE html>"))
+	require.Contains(t, doc, `<html lang="fa" dir="rtl">`)
+	require.Equal(t, 1, strings.Count(doc, `charset="UTF-8"`))
+	require.Equal(t, 1, strings.Count(doc, `href="a.css"`))
+	require.Contains(t, doc, `<link rel="stylesheet" href="b.css"/>`)
+	require.NotContains(t, doc, "پیش‌نویس")
+	require.Less(t, strings.Index(doc, `<a id="Install"></a>`), strings.Index(doc, `<a id="Home"></a>`))
+
+	// IDs used by the page anchors or an earlier page are renamed, with links to them within the page
+	require.Contains(t, doc, `<h1 id="intro">نصب</h1>`)
+	require.Contains(t, doc, `<h2 id="Home-1">خانه</h2>`)
+	require.Contains(t, doc, `<a href="#intro">بالا</a> <a href="#Home-1">خانه</a>`)
+	require.Contains(t, doc, `<h1 id="intro-1">خانه</h1><p><a href="#intro-1">بالا</a></p>`)
+
+	// the pages are kept unless removed
+	require.FileExists(t, path.Join(output, "fa.Home.html"))
+	_, err = JoinHTML("fa", dir, "Guide", []string{"Install", "Home"}, nil, true)
+	require.NoError(t, err)
+	require.NoFileExists(t, path.Join(output, "fa.Home.html"))
+	require.NoFileExists(t, path.Join(output, "fa.Install.html"))
+	require.FileExists(t, path.Join(output, "fa.Draft.html"))
+	require.FileExists(t, joined)
+
+	// renamed IDs don't take IDs reserved for the elements of later pages
+	write("base.A.html", `<html><body><p id="note">a</p></body></html>`)
+	write("base.B.html", `<html><body><p id="note">b</p></body></html>`)
+	links := NewPageLinks([]StagedPage{{Name: "A"}, {Name: "B"}})
+	require.NoError(t, links.ReserveIDs(
+		[]StagedPage{{Name: "A"}, {Name: "B"}},
+		[]string{path.Join(output, "base.A.html"), path.Join(output, "base.B.html")},
+	))
+	write("fa.A.html", `<html><body><p id="note">a</p><p id="note">a</p></body></html>`)
+	write("fa.B.html", `<html><body><p id="note-1">b</p></body></html>`)
+	joined, err = JoinHTML("fa", dir, "Guide", []string{"A", "B"}, links, false)
+	require.NoError(t, err)
+	content, err = os.ReadFile(joined)
+	require.NoError(t, err)
+	doc = string(content)
+	require.Contains(t, doc, `<p id="note">a</p><p id="note-2">a</p>`)
+	require.Contains(t, doc, `<p id="note-1">b</p>`)
+
+	_, err = JoinHTML("fa", dir, "Guide", []string{"Missing"}, nil, false)
+	require.Error(t, err)
+	_, err = JoinHTML("fa", dir, "Guide", nil, nil, false)
+	require.Error(t, err)
+}
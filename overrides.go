@@ -4,12 +4,13 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
-// override defines a word or phrase that should be overridden if/when it exists in a translation.
-type override struct {
+// Override defines a word or phrase that should be overridden if/when it exists in a translation.
+type Override struct {
 	Title       string `yaml:"title,omitempty"`
 	Language    string `yaml:"language,omitempty"`
 	Original    string `yaml:"original,omitempty"`
@@ -17,7 +18,7 @@ type override struct {
 }
 
 // WriteOverrideFile writes a slice of overrides to a YAML file at path.
-func WriteOverrideFile(path string, overrides []override) error {
+func WriteOverrideFile(path string, overrides []Override) error {
 	f, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("create override file: %w", err)
@@ -39,13 +40,13 @@ func WriteOverrideFile(path string, overrides []override) error {
 }
 
 // ReadOverrideFile reads a slice of overrides from a YAML file at path.
-func ReadOverrideFile(path string) ([]override, error) {
+func ReadOverrideFile(path string) ([]Override, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("open override file: %w", err)
 	}
 	defer f.Close()
-	var overrides []override
+	var overrides []Override
 	decoder := yaml.NewDecoder(f)
 	err = decoder.Decode(&overrides)
 	if err != nil {
@@ -58,3 +59,26 @@ func ReadOverrideFile(path string) ([]override, error) {
 	)
 	return overrides, nil
 }
+
+// ApplyOverrides replaces the original phrase of each override for language in text.
+func ApplyOverrides(text string, language string, overrides []Override) string {
+	for _, override := range overrides {
+		if override.Language != language {
+			continue
+		}
+		if override.Original == "" || override.Replacement == "" {
+			slog.Warn("skipping override with empty original or replacement",
+				"override", override,
+			)
+			continue
+		}
+		text = strings.ReplaceAll(text, override.Original, override.Replacement)
+		slog.Debug("applied override",
+			"title", override.Title,
+			"original", override.Original,
+			"replacement", override.Replacement,
+			"lang", override.Language,
+		)
+	}
+	return text
+}
@@ -5,7 +5,7 @@ import (
 	"testing"
 )
 
-var testOverrides = []override{
+var testOverrides = []Override{
 	{
 		Title:       "Lantern",
 		Language:    "zh",
@@ -0,0 +1,144 @@
+package illuminated
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile is the key of the profile used for languages without their own.
+const DefaultProfile = "default"
+
+// LanguageProfile describes how documents in a language are typeset as PDF.
+// Empty fields are taken from the default profile, except Direction and Hyphenation.
+type LanguageProfile struct {
+	MainFont      string   `yaml:"main_font,omitempty"`
+	MonoFont      string   `yaml:"mono_font,omitempty"`
+	FallbackFonts []string `yaml:"fallback_fonts,omitempty"` // fonts for characters missing from the main font (lualatex and native only)
+	// Direction is ltr or rtl, following the script of the language if empty.
+	Direction string `yaml:"direction,omitempty"`
+	PDFEngine string `yaml:"pdf_engine,omitempty"`
+	// Hyphenation is the BCP 47 tag of the language hyphenation patterns are chosen for, such as de-CH,
+	// the language itself if empty. Pandoc maps the tag to the names of LaTeX hyphenation patterns.
+	Hyphenation string `yaml:"hyphenation,omitempty"`
+	// Variables are passed to pandoc as additional LaTeX template variables.
+	Variables map[string]string `yaml:"variables,omitempty"`
+}
+
+// LanguageProfiles maps BCP 47 language tags, or languages and scripts such as zh-Hant, to their profiles.
+type LanguageProfiles map[string]LanguageProfile
+
+// DefaultLanguageProfiles are the built-in profiles, typeset with Noto fonts.
+// Chinese, Japanese and Korean use the TrueType Noto Sans SC, TC, JP and KR, which the native engine can embed,
+// rather than the OpenType CFF Noto Sans CJK. Profiles with fallback fonts are typeset with lualatex,
+// the only LaTeX engine pandoc sets fallback fonts for.
+var DefaultLanguageProfiles = LanguageProfiles{
+	DefaultProfile: {
+		MainFont:  "Noto Sans",
+		MonoFont:  "Noto Sans Mono",
+		PDFEngine: "xelatex",
+	},
+	"am":      {MainFont: "Noto Sans Ethiopic", FallbackFonts: []string{"Noto Sans"}, PDFEngine: "lualatex"},
+	"ar":      {MainFont: "Noto Sans Arabic", FallbackFonts: []string{"Noto Sans"}, PDFEngine: "lualatex"},
+	"bn":      {MainFont: "Noto Sans Bengali", FallbackFonts: []string{"Noto Sans"}, PDFEngine: "lualatex"},
+	"fa":      {MainFont: "Noto Sans Arabic", FallbackFonts: []string{"Noto Sans"}, PDFEngine: "lualatex"},
+	"he":      {MainFont: "Noto Sans Hebrew", FallbackFonts: []string{"Noto Sans"}, PDFEngine: "lualatex"},
+	"hi":      {MainFont: "Noto Sans Devanagari", FallbackFonts: []string{"Noto Sans"}, PDFEngine: "lualatex"},
+	"ja":      {MainFont: "Noto Sans JP", Variables: map[string]string{"CJKmainfont": "Noto Sans JP"}},
+	"km":      {MainFont: "Noto Sans Khmer", FallbackFonts: []string{"Noto Sans"}, PDFEngine: "lualatex"},
+	"ko":      {MainFont: "Noto Sans KR", Variables: map[string]string{"CJKmainfont": "Noto Sans KR"}},
+	"my":      {MainFont: "Noto Sans Myanmar", FallbackFonts: []string{"Noto Sans"}, PDFEngine: "lualatex"},
+	"th":      {MainFont: "Noto Sans Thai", FallbackFonts: []string{"Noto Sans"}, PDFEngine: "lualatex"},
+	"ur":      {MainFont: "Noto Nastaliq Urdu", FallbackFonts: []string{"Noto Sans"}, PDFEngine: "lualatex"},
+	"zh":      {MainFont: "Noto Sans SC", Variables: map[string]string{"CJKmainfont": "Noto Sans SC"}},
+	"zh-Hant": {MainFont: "Noto Sans TC", Variables: map[string]string{"CJKmainfont": "Noto Sans TC"}},
+}
+
+// ReadLanguageProfiles reads profiles from a YAML file at path, mapping languages to profiles,
+// which replace the built-in profiles of the same languages.
+// The built-in profiles are returned, along with the error, if the file can't be read.
+func ReadLanguageProfiles(path string) (LanguageProfiles, error) {
+	profiles := maps.Clone(DefaultLanguageProfiles)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return profiles, fmt.Errorf("read language profiles: %w", err)
+	}
+	var custom LanguageProfiles
+	err = yaml.Unmarshal(b, &custom)
+	if err != nil {
+		return profiles, fmt.Errorf("decode language profiles %q: %w", path, err)
+	}
+	for lang, profile := range custom {
+		if profile.Direction != "" && profile.Direction != "ltr" && profile.Direction != "rtl" {
+			return profiles, fmt.Errorf("language profile %q: direction %q is not ltr or rtl", lang, profile.Direction)
+		}
+		profiles[lang] = profile
+	}
+	slog.Debug("language profiles read from file", "count", len(custom), "path", path)
+	return profiles, nil
+}
+
+// Profile returns the profile of lang, trying the tag as given, its canonical form,
+// its language and script, then its language alone, with empty fields from the default profile,
+// except the direction and hyphenation, which follow lang unless set by its profile.
+func (p LanguageProfiles) Profile(lang string) LanguageProfile {
+	keys := []string{lang, Language(lang).Tag}
+	if tag, err := language.Parse(strings.ReplaceAll(lang, "_", "-")); err == nil {
+		base, _ := tag.Base()
+		script, _ := tag.Script()
+		keys = append(keys, base.String()+"-"+script.String(), base.String())
+	}
+	profile, ok := LanguageProfile{}, false
+	for _, key := range keys {
+		if profile, ok = p[key]; ok {
+			break
+		}
+	}
+	if !ok {
+		slog.Debug("no language profile, using default", "lang", lang)
+	}
+	def := p[DefaultProfile]
+	profile.MainFont = cmp.Or(profile.MainFont, def.MainFont)
+	profile.MonoFont = cmp.Or(profile.MonoFont, def.MonoFont)
+	if len(profile.FallbackFonts) == 0 {
+		profile.FallbackFonts = def.FallbackFonts
+	}
+	profile.Direction = cmp.Or(profile.Direction, Language(lang).Direction, def.Direction)
+	profile.PDFEngine = cmp.Or(profile.PDFEngine, def.PDFEngine, "xelatex")
+	profile.Hyphenation = cmp.Or(profile.Hyphenation, Language(lang).Tag, def.Hyphenation)
+	variables := maps.Clone(def.Variables)
+	if variables == nil {
+		variables = map[string]string{}
+	}
+	maps.Copy(variables, profile.Variables)
+	profile.Variables = variables
+	return profile
+}
+
+// pandocArgs returns the pandoc arguments typesetting a document with the profile.
+func (p LanguageProfile) pandocArgs() []string {
+	args := []string{"--pdf-engine", p.PDFEngine}
+	variable := func(key, value string) {
+		if value != "" {
+			args = append(args, "--variable", fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	variable("mainfont", p.MainFont)
+	variable("monofont", p.MonoFont)
+	for _, font := range p.FallbackFonts {
+		variable("mainfontfallback", font)
+	}
+	variable("lang", p.Hyphenation)
+	variable("dir", p.Direction)
+	for _, key := range slices.Sorted(maps.Keys(p.Variables)) {
+		variable(key, p.Variables[key])
+	}
+	return args
+}
@@ -0,0 +1,101 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanguageProfile(t *testing.T) {
+	for _, tc := range []struct {
+		lang      string
+		mainFont  string
+		direction string
+		engine    string
+	}{
+		{"en", "Noto Sans", "ltr", "xelatex"},
+		{"es", "Noto Sans", "ltr", "xelatex"},         // no profile of its own
+		{"uk", "Noto Sans", "ltr", "xelatex"},         // no profile of its own
+		{"fa", "Noto Sans Arabic", "rtl", "lualatex"}, // built-in profile, with fallback fonts
+		{"ps", "Noto Sans", "rtl", "xelatex"},         // direction from the script of the language
+		{"zh-TW", "Noto Sans TC", "ltr", "xelatex"},   // language and script
+		{"zh-CN", "Noto Sans SC", "ltr", "xelatex"},   // language alone
+		{"my", "Noto Sans Myanmar", "ltr", "lualatex"},
+		{"xx-unknown", "Noto Sans", "ltr", "xelatex"},
+	} {
+		t.Run(tc.lang, func(t *testing.T) {
+			profile := DefaultLanguageProfiles.Profile(tc.lang)
+			require.Equal(t, tc.mainFont, profile.MainFont)
+			require.Equal(t, tc.direction, profile.Direction)
+			require.Equal(t, tc.engine, profile.PDFEngine)
+			require.Equal(t, "Noto Sans Mono", profile.MonoFont)
+		})
+	}
+	// pandoc sets fallback fonts only with lualatex
+	for lang, profile := range DefaultLanguageProfiles {
+		if len(profile.FallbackFonts) > 0 {
+			require.Equal(t, "lualatex", profile.PDFEngine, lang)
+		}
+	}
+}
+
+func TestReadLanguageProfiles(t *testing.T) {
+	file := path.Join(t.TempDir(), "languages.yml")
+	err := os.WriteFile(file, []byte(`
+default:
+  main_font: DejaVu Sans
+  direction: rtl
+  hyphenation: english
+  variables:
+    geometry: margin=2cm
+vi:
+  main_font: Be Vietnam Pro
+  pdf_engine: lualatex
+  fallback_fonts: [Noto Sans, Noto Sans Symbols]
+  hyphenation: vi
+  variables:
+    fontsize: 12pt
+`), 0o644)
+	require.NoError(t, err)
+	profiles, err := ReadLanguageProfiles(file)
+	require.NoError(t, err)
+
+	vi := profiles.Profile("vi")
+	require.Equal(t, LanguageProfile{
+		MainFont:      "Be Vietnam Pro",
+		MonoFont:      "", // the custom default profile has no mono font
+		FallbackFonts: []string{"Noto Sans", "Noto Sans Symbols"},
+		Direction:     "ltr",
+		PDFEngine:     "lualatex",
+		Hyphenation:   "vi",
+		Variables:     map[string]string{"geometry": "margin=2cm", "fontsize": "12pt"},
+	}, vi)
+	require.Equal(t, []string{
+		"--pdf-engine", "lualatex",
+		"--variable", "mainfont=Be Vietnam Pro",
+		"--variable", "mainfontfallback=Noto Sans",
+		"--variable", "mainfontfallback=Noto Sans Symbols",
+		"--variable", "lang=vi",
+		"--variable", "dir=ltr",
+		"--variable", "fontsize=12pt",
+		"--variable", "geometry=margin=2cm",
+	}, vi.pandocArgs())
+
+	// built-in profiles of other languages are kept, with fields from the custom default profile
+	require.Equal(t, "Noto Sans Arabic", profiles.Profile("ar").MainFont)
+	require.Equal(t, "DejaVu Sans", profiles.Profile("de").MainFont)
+	// the direction and hyphenation follow the language before the custom default profile
+	require.Equal(t, "ltr", profiles.Profile("de").Direction)
+	require.Equal(t, "de", profiles.Profile("de").Hyphenation)
+	require.Equal(t, "rtl", profiles.Profile("ar").Direction)
+
+	_, err = ReadLanguageProfiles(path.Join(t.TempDir(), "missing.yml"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	err = os.WriteFile(file, []byte("he:\n  direction: backwards\n"), 0o644)
+	require.NoError(t, err)
+	_, err = ReadLanguageProfiles(file)
+	require.Error(t, err)
+}
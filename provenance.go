@@ -0,0 +1,109 @@
+package illuminated
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Attributes recording the provenance of translated blocks.
+const (
+	AttrTranslator = "data-translator"  // name of the translation service
+	AttrReview     = "data-review"      // ReviewMachine or ReviewReviewed
+	AttrSourceHash = "data-source-hash" // hash of the text of the block in the base language
+)
+
+// Review statuses of translated blocks.
+const (
+	ReviewMachine  = "machine"  // machine translated, not reviewed
+	ReviewReviewed = "reviewed" // machine translated and reviewed by a person
+)
+
+// ProvenanceVersion identifies how provenance is marked, so translations marked differently are rebuilt.
+var ProvenanceVersion = "1"
+
+// provenanceBlocks are the elements whose text is translated as a unit.
+var provenanceBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Li: true, atom.Dt: true, atom.Dd: true, atom.Th: true, atom.Td: true, atom.Figcaption: true,
+}
+
+// Provenance describes how a page was translated.
+type Provenance struct {
+	Translator string // name of the translation service
+	Reviewed   bool   // the translation was reviewed by a person
+}
+
+// review returns the review status of blocks translated with p.
+func (p Provenance) review() string {
+	if p.Reviewed {
+		return ReviewReviewed
+	}
+	return ReviewMachine
+}
+
+// textBlocks returns the elements of doc translated as a unit, in document order.
+func textBlocks(doc *html.Node) []*html.Node {
+	var found []*html.Node
+	walkElements(doc, func(n *html.Node) {
+		if provenanceBlocks[n.DataAtom] {
+			found = append(found, n)
+		}
+	})
+	return found
+}
+
+// SourceHash returns a short hash of the text of a block, ignoring differences in whitespace.
+func SourceHash(text string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(text), " ")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// MarkProvenance sets the provenance attributes of the blocks of the translated HTML document:
+// the translator, the review status and the hash of the text of the same block of the base document,
+// so reviewers can tell which blocks to check again when the source changes.
+// Blocks are matched in document order, as KeepHeadingIDs matches headings.
+func MarkProvenance(base, translated string, p Provenance) (string, error) {
+	baseDoc, err := html.Parse(strings.NewReader(base))
+	if err != nil {
+		return "", fmt.Errorf("parse base HTML: %w", err)
+	}
+	doc, err := html.Parse(strings.NewReader(translated))
+	if err != nil {
+		return "", fmt.Errorf("parse translated HTML: %w", err)
+	}
+	baseBlocks, txBlocks := textBlocks(baseDoc), textBlocks(doc)
+	if len(baseBlocks) != len(txBlocks) {
+		slog.Warn("translation has a different number of blocks, source hashes may not match",
+			"base", len(baseBlocks),
+			"translated", len(txBlocks),
+		)
+	}
+	for i, n := range txBlocks {
+		setAttr(n, AttrTranslator, p.Translator)
+		setAttr(n, AttrReview, p.review())
+		if i < len(baseBlocks) {
+			setAttr(n, AttrSourceHash, SourceHash(textContent(baseBlocks[i])))
+		}
+	}
+	var b strings.Builder
+	err = html.Render(&b, doc)
+	if err != nil {
+		return "", fmt.Errorf("render HTML: %w", err)
+	}
+	return b.String(), nil
+}
+
+// removeProvenance removes the provenance attributes of the elements of doc.
+func removeProvenance(doc *html.Node) {
+	walkElements(doc, func(n *html.Node) {
+		for _, key := range []string{AttrTranslator, AttrReview, AttrSourceHash} {
+			removeAttr(n, key)
+		}
+	})
+}
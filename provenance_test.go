@@ -0,0 +1,32 @@
+package illuminated
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkProvenance(t *testing.T) {
+	base := `<h1 id="install">Install</h1><p>Run the
+installer.</p><ul><li>Windows</li></ul>`
+	translated := `<h1 id="install">安装</h1><p>运行安装程序。</p><ul><li>视窗</li></ul>`
+	doc, err := MarkProvenance(base, translated, Provenance{Translator: "google"})
+	require.NoError(t, err)
+	require.Contains(t, doc, `<h1 id="install" data-translator="google" data-review="machine" data-source-hash="`+
+		SourceHash("Install")+`">安装</h1>`)
+	require.Contains(t, doc, `<p data-translator="google" data-review="machine" data-source-hash="`+
+		SourceHash("Run the installer.")+`">运行安装程序。</p>`)
+	require.Contains(t, doc, `<li data-translator="google" data-review="machine" data-source-hash="`+
+		SourceHash("Windows")+`">视窗</li>`)
+
+	// blocks the base document doesn't have get no source hash
+	doc, err = MarkProvenance(`<p>One</p>`, `<p>一</p><p>二</p>`, Provenance{Translator: "google", Reviewed: true})
+	require.NoError(t, err)
+	require.Contains(t, doc, `<p data-translator="google" data-review="reviewed">二</p>`)
+}
+
+func TestSourceHash(t *testing.T) {
+	require.Len(t, SourceHash("Install"), 16)
+	require.Equal(t, SourceHash("Run the installer."), SourceHash(" Run the\n  installer. "))
+	require.NotEqual(t, SourceHash("Install"), SourceHash("Uninstall"))
+}
@@ -0,0 +1,210 @@
+package illuminated
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// DefaultPublishAuthor is the author of published commits when git has no user configured.
+var DefaultPublishAuthor = object.Signature{Name: "illuminated", Email: "illuminated@localhost"}
+
+// Publication is a set of output files published to a git repository, such as the wiki of one language.
+type Publication struct {
+	Remote  string            // URL of the repository
+	Files   map[string]string // file names in the repository to the output files written to them
+	Message string            // commit message
+	Author  *object.Signature // commit author, from the git configuration if nil
+	Auth    transport.AuthMethod
+	DryRun  bool // report changes without committing or pushing them
+}
+
+// PublishResult describes the changes published to a repository.
+type PublishResult struct {
+	Added    []string // files new to the repository
+	Modified []string // files which differed in the repository
+	Commit   string   // hash of the commit published, empty if nothing changed or in a dry run
+}
+
+// Changed reports whether publishing changed any files.
+func (r PublishResult) Changed() bool {
+	return len(r.Added) > 0 || len(r.Modified) > 0
+}
+
+// Publish clones the repository of p to dir, writes its files, then commits and pushes any changes.
+// Files in the repository which aren't published are left as they are.
+func Publish(p Publication, dir string) (PublishResult, error) {
+	var result PublishResult
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return result, fmt.Errorf("remove existing directory %q: %w", dir, err)
+	}
+	repo, err := git.PlainClone(dir, false, &git.CloneOptions{URL: p.Remote, Auth: p.Auth})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		// nothing has been published yet
+		repo, err = initRepo(dir, p.Remote)
+	}
+	if err != nil {
+		return result, fmt.Errorf("clone repository %q: %w", p.Remote, err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return result, fmt.Errorf("open worktree: %w", err)
+	}
+
+	names := make([]string, 0, len(p.Files))
+	for name := range p.Files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		dst := path.Join(dir, name)
+		err = os.MkdirAll(path.Dir(dst), DefaultFilePermissions)
+		if err != nil {
+			return result, fmt.Errorf("create directory for %q: %w", name, err)
+		}
+		err = CopyFile(p.Files[name], dst)
+		if err != nil {
+			return result, fmt.Errorf("write %q: %w", name, err)
+		}
+		_, err = wt.Add(name)
+		if err != nil {
+			return result, fmt.Errorf("add %q: %w", name, err)
+		}
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return result, fmt.Errorf("status of worktree: %w", err)
+	}
+	for _, name := range names {
+		switch status.File(name).Staging {
+		case git.Added:
+			result.Added = append(result.Added, name)
+		case git.Modified:
+			result.Modified = append(result.Modified, name)
+		}
+	}
+	if !result.Changed() {
+		slog.Info("nothing to publish, repository is up to date", "remote", p.Remote)
+		return result, nil
+	}
+	if p.DryRun {
+		slog.Info("dry run, not publishing", "remote", p.Remote, "added", result.Added, "modified", result.Modified)
+		return result, nil
+	}
+
+	author := p.Author
+	if author == nil {
+		author = configuredAuthor(repo)
+	}
+	signature := *author
+	signature.When = time.Now()
+	hash, err := wt.Commit(p.Message, &git.CommitOptions{Author: &signature})
+	if err != nil {
+		return result, fmt.Errorf("commit: %w", err)
+	}
+	err = repo.Push(&git.PushOptions{Auth: p.Auth})
+	if err != nil {
+		return result, fmt.Errorf("push to %q: %w", p.Remote, err)
+	}
+	result.Commit = hash.String()
+	slog.Info("published", "remote", p.Remote, "commit", result.Commit,
+		"added", len(result.Added), "modified", len(result.Modified),
+	)
+	return result, nil
+}
+
+// initRepo initializes a repository at dir with remote as its origin, for publishing to an empty remote.
+func initRepo(dir, remote string) (*git.Repository, error) {
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	_, err = repo.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{remote}})
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// configuredAuthor returns the user configured for repo, or DefaultPublishAuthor.
+func configuredAuthor(repo *git.Repository) *object.Signature {
+	author := DefaultPublishAuthor
+	cfg, err := repo.ConfigScoped(config.GlobalScope)
+	if err != nil {
+		slog.Debug("no git configuration, using default author", "error", err)
+		return &author
+	}
+	if cfg.User.Name != "" {
+		author.Name = cfg.User.Name
+	}
+	if cfg.User.Email != "" {
+		author.Email = cfg.User.Email
+	}
+	return &author
+}
+
+// PublishedFiles returns the output files of lang in projectDir published from the markdown or html output,
+// by their file names in the published repository. HTML pages keep their output names, <lang>.<page>.html,
+// which links between them are resolved to, and the joined document isn't published as a page.
+func PublishedFiles(projectDir, lang, from string) (map[string]string, error) {
+	outputDir := path.Join(projectDir, DefaultDirNameOutput)
+	files := map[string]string{}
+	switch from {
+	case "markdown":
+		dir := path.Join(outputDir, DefaultDirNameMarkdown, lang)
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read markdown output directory: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files[entry.Name()] = path.Join(dir, entry.Name())
+			}
+		}
+	case "html":
+		entries, err := os.ReadDir(outputDir)
+		if err != nil {
+			return nil, fmt.Errorf("read output directory: %w", err)
+		}
+		joined := path.Base(JoinedHTMLPath(lang, projectDir, path.Base(projectDir)))
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || name == joined || !strings.HasPrefix(name, lang+".") || !strings.HasSuffix(name, ".html") {
+				continue
+			}
+			files[name] = path.Join(outputDir, name)
+		}
+	default:
+		return nil, fmt.Errorf("unknown output %q, expected markdown or html", from)
+	}
+	return files, nil
+}
+
+// PublishMessage returns the commit message of a publication of language,
+// referencing the commits of the sources it was generated from.
+func PublishMessage(language string, commits []string) string {
+	msg := fmt.Sprintf("Update %s translation", language)
+	if len(commits) == 0 {
+		return msg + "\n"
+	}
+	msg += "\n\nGenerated from source commit"
+	if len(commits) > 1 {
+		msg += "s"
+	}
+	msg += ":\n"
+	for _, commit := range commits {
+		msg += commit + "\n"
+	}
+	return msg
+}
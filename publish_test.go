@@ -0,0 +1,148 @@
+package illuminated
+
+import (
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+// headCommit returns the commit at HEAD of the repository at remote.
+func headCommit(t *testing.T, remote string) *object.Commit {
+	t.Helper()
+	repo, err := git.PlainOpen(remote)
+	require.NoError(t, err)
+	ref, err := repo.Head()
+	require.NoError(t, err)
+	c, err := repo.CommitObject(ref.Hash())
+	require.NoError(t, err)
+	return c
+}
+
+// remoteFile returns the content of file at HEAD of the repository at remote.
+func remoteFile(t *testing.T, remote, name string) string {
+	t.Helper()
+	f, err := headCommit(t, remote).File(name)
+	require.NoError(t, err)
+	content, err := f.Contents()
+	require.NoError(t, err)
+	return content
+}
+
+func TestPublish(t *testing.T) {
+	dir := t.TempDir()
+	remote := path.Join(dir, "guide-zh.wiki.git")
+	_, err := git.PlainInit(remote, true)
+	require.NoError(t, err)
+	output := path.Join(dir, "output")
+	require.NoError(t, os.MkdirAll(output, 0o755))
+	write := func(name, content string) string {
+		file := path.Join(output, name)
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+		return file
+	}
+	author := &object.Signature{Name: "test", Email: "test@example.com"}
+	p := Publication{
+		Remote: remote,
+		Files: map[string]string{
+			"Home.md":    write("Home.md", "# 首页"),
+			"Install.md": write("Install.md", "# 安装"),
+		},
+		Message: PublishMessage("zh", []string{"0123456789abcdef"}),
+		Author:  author,
+	}
+	work := path.Join(dir, "publish", "zh")
+
+	// the first publication initializes the empty remote
+	result, err := Publish(p, work)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Home.md", "Install.md"}, result.Added)
+	require.Empty(t, result.Modified)
+	require.Equal(t, result.Commit, headCommit(t, remote).Hash.String())
+	require.Equal(t, "# 安装", remoteFile(t, remote, "Install.md"))
+	require.Contains(t, headCommit(t, remote).Message, "0123456789abcdef")
+	require.Equal(t, "test", headCommit(t, remote).Author.Name)
+
+	// publishing the same files changes nothing
+	result, err = Publish(p, work)
+	require.NoError(t, err)
+	require.False(t, result.Changed())
+	require.Empty(t, result.Commit)
+
+	// a dry run reports changes without pushing them
+	write("Install.md", "# 安装指南")
+	p.DryRun = true
+	first := headCommit(t, remote).Hash
+	result, err = Publish(p, work)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Install.md"}, result.Modified)
+	require.Empty(t, result.Commit)
+	require.Equal(t, first, headCommit(t, remote).Hash)
+
+	// only published files are changed, others in the repository are kept
+	p.DryRun = false
+	p.Files = map[string]string{"Install.md": p.Files["Install.md"]}
+	result, err = Publish(p, work)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Install.md"}, result.Modified)
+	require.Equal(t, "# 安装指南", remoteFile(t, remote, "Install.md"))
+	require.Equal(t, "# 首页", remoteFile(t, remote, "Home.md"))
+	parent, err := headCommit(t, remote).Parent(0)
+	require.NoError(t, err)
+	require.Equal(t, first, parent.Hash)
+}
+
+func TestPublishMessage(t *testing.T) {
+	require.Equal(t, "Update fa translation\n", PublishMessage("fa", nil))
+	require.Equal(t, "Update fa translation\n\nGenerated from source commit:\nabc\n", PublishMessage("fa", []string{"abc"}))
+	require.Equal(t, "Update fa translation\n\nGenerated from source commits:\nabc\ndef\n",
+		PublishMessage("fa", []string{"abc", "def"}))
+}
+
+func TestPublishedFiles(t *testing.T) {
+	pages := map[string]string{
+		"Home":    `<html><body><h1>Home</h1><p><a href="Install">install</a></p></body></html>`,
+		"Install": `<html><body><h1>Install</h1></body></html>`,
+	}
+	doc, opts := renderTestOptions(t, "en", pages, "Home", "Install")
+	_, err := HTMLRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	opts.Join = true
+	_, err = HTMLRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+
+	// the joined document isn't published as a page
+	files, err := PublishedFiles(opts.ProjectDir, "en", "html")
+	require.NoError(t, err)
+	require.Equal(t, []string{"en.Home.html", "en.Install.html"}, slices.Sorted(maps.Keys(files)))
+	_, err = PublishedFiles(opts.ProjectDir, "en", "pdf")
+	require.Error(t, err)
+
+	// links between published pages lead to published files
+	remote := path.Join(t.TempDir(), "guide.git")
+	_, err = git.PlainInit(remote, true)
+	require.NoError(t, err)
+	_, err = Publish(Publication{
+		Remote:  remote,
+		Files:   files,
+		Message: PublishMessage("en", nil),
+		Author:  &object.Signature{Name: "test", Email: "test@example.com"},
+	}, path.Join(opts.ProjectDir, DefaultDirNamePublish, "en"))
+	require.NoError(t, err)
+	home, err := html.Parse(strings.NewReader(remoteFile(t, remote, "en.Home.html")))
+	require.NoError(t, err)
+	var href string
+	walkElements(home, func(n *html.Node) {
+		if n.Data == "a" && strings.Contains(textContent(n), "install") {
+			href = attr(n, "href")
+		}
+	})
+	require.Contains(t, remoteFile(t, remote, href), "<h1>Install</h1>")
+}
@@ -0,0 +1,471 @@
+package illuminated
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Document is the HTML built for the pages of a language, in page order, which renderers write outputs from.
+type Document struct {
+	Lang  string
+	Pages []DocumentPage
+}
+
+// DocumentPage is the HTML built for a staged page in a single language.
+type DocumentPage struct {
+	Page StagedPage
+	Path string // built HTML of the page
+}
+
+// RenderOptions are the options renderers write outputs with.
+type RenderOptions struct {
+	ProjectDir string         // outputs are written to its output directory
+	Title      string         // title of the documents in the base language
+	Join       bool           // join the pages of each language into one document
+	Base       []DocumentPage // base language HTML of every page, which element IDs of joined documents are reserved from
+	Templates  *Templates
+	Build      BuildInfo
+	State      *BuildState // outputs whose inputs are unchanged since the previous build are skipped
+	Rebuild    bool        // ignore the build state and write every output
+	Force      bool        // overwrite existing PDF, DOCX and ODT files not written by a build
+
+	// TranslateTitle translates a title or other short text from the base language into lang, if set.
+	TranslateTitle func(lang, title string) (string, error)
+
+	Front  *FrontPages // front pages of joined HTML, PDF, DOCX, ODT and EPUB output, if any
+	Banner bool        // add a banner to translated pages of HTML and site output, linking to the original
+
+	ProfilesPath string    // yaml file defining language profiles of PDF output
+	PDFEngine    string    // PDF engine replacing those of language profiles
+	Layout       PDFLayout // page layout of PDF output
+	Metadata     Metadata  // author, subject and keywords of PDF, DOCX and ODT output, the subject in the base language
+	ReferenceDoc string    // DOCX or ODT file whose styles output of the same format uses
+	SiteURL      string    // base URL the site is served from
+	EPUBFonts    []string  // font files embedded in EPUB output
+}
+
+// outputDir returns the directory outputs are written to.
+func (o RenderOptions) outputDir() string {
+	return path.Join(o.ProjectDir, DefaultDirNameOutput)
+}
+
+// title returns the title of the documents, named after the project directory if not set.
+func (o RenderOptions) title() string {
+	return cmp.Or(o.Title, path.Base(o.ProjectDir))
+}
+
+// fresh reports whether the output at filePath can be skipped, as built from the same inputs.
+func (o RenderOptions) fresh(filePath, inputs string) bool {
+	return !o.Rebuild && o.State.Fresh(filePath, inputs)
+}
+
+// translateTitle translates title into lang, if a translator is set.
+func (o RenderOptions) translateTitle(lang, title string) (string, error) {
+	if o.TranslateTitle == nil || title == "" {
+		return title, nil
+	}
+	return o.TranslateTitle(lang, title)
+}
+
+// Renderer writes an output format from the documents of each language.
+type Renderer interface {
+	// Render writes the output of docs, returning any links to pages which don't exist.
+	Render(docs []Document, opts RenderOptions) ([]MissingLink, error)
+}
+
+var (
+	renderers = map[string]Renderer{}
+	formats   []string // formats in the order their renderers were registered
+)
+
+func init() {
+	RegisterRenderer("html", HTMLRenderer{})
+	RegisterRenderer("pdf", PDFRenderer{})
+	RegisterRenderer(FormatDOCX, OfficeRenderer{Format: FormatDOCX})
+	RegisterRenderer(FormatODT, OfficeRenderer{Format: FormatODT})
+	RegisterRenderer("site", SiteRenderer{})
+	RegisterRenderer("epub", EPUBRenderer{})
+	RegisterRenderer("markdown", MarkdownOutputRenderer{})
+}
+
+// RegisterRenderer makes a renderer available by the name of its format.
+// It panics if the format is already registered.
+func RegisterRenderer(format string, r Renderer) {
+	if _, ok := renderers[format]; ok {
+		panic(fmt.Sprintf("renderer already registered for format %q", format))
+	}
+	renderers[format] = r
+	formats = append(formats, format)
+}
+
+// LookupRenderer returns the renderer of format.
+func LookupRenderer(format string) (Renderer, error) {
+	r, ok := renderers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, expected one of: %s", format, strings.Join(formats, ", "))
+	}
+	return r, nil
+}
+
+// Formats returns the formats of registered renderers, in the order they were registered.
+func Formats() []string {
+	return slices.Clone(formats)
+}
+
+// HTMLRenderer writes the HTML of each page into the output directory, or with RenderOptions.Join,
+// a single document per language joined by JoinHTML.
+type HTMLRenderer struct{}
+
+// Render writes the HTML of docs.
+func (HTMLRenderer) Render(docs []Document, opts RenderOptions) ([]MissingLink, error) {
+	var missing []MissingLink
+	for _, doc := range docs {
+		var inputs string
+		if opts.Join {
+			joinedPath := JoinedHTMLPath(doc.Lang, opts.ProjectDir, path.Base(opts.ProjectDir))
+			hashes := []string{opts.ProjectDir, opts.Title, opts.Templates.Digest(), opts.frontDigest(), fmt.Sprint(opts.Banner)}
+			for _, p := range doc.Pages {
+				hashes = append(hashes, p.Page.Name, opts.State.Hash(p.Path))
+			}
+			for _, p := range opts.Base {
+				hashes = append(hashes, opts.State.Hash(p.Path))
+			}
+			inputs = Digest(hashes...)
+			if opts.fresh(joinedPath, inputs) {
+				slog.Debug("skipping unchanged joined HTML", "file", joinedPath)
+				// links are still resolved, so links to missing pages are reported on every build
+				m, err := missingLinks(doc, opts.Join)
+				if err != nil {
+					return nil, err
+				}
+				missing = append(missing, m...)
+				continue
+			}
+		}
+		files, m, err := writeDocumentHTML(doc, opts, opts.ProjectDir, path.Base(opts.ProjectDir))
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, m...)
+		if opts.Join {
+			err = opts.State.Record(files[0], inputs)
+			if err != nil {
+				return nil, fmt.Errorf("record %q in build state: %w", files[0], err)
+			}
+			slog.Debug("joined HTML files", "file", files[0])
+		}
+	}
+	return missing, nil
+}
+
+// writeDocumentHTML writes the pages of doc into the output directory of projectDir,
+// resolving links between them, and returns the files written in page order:
+// a file per page rendered with the page template or, when joining, the document named name
+// rendered with the joined template. When joining, element IDs are reserved from the base HTML of all pages,
+// so anchors are the same in every language, and pages are joined in a temporary directory,
+// leaving any other files in the output directory as they are.
+func writeDocumentHTML(doc Document, opts RenderOptions, projectDir, name string) ([]string, []MissingLink, error) {
+	data, err := documentData(doc, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("template data for language %q: %w", doc.Lang, err)
+	}
+	var pages []StagedPage
+	for _, p := range doc.Pages {
+		pages = append(pages, p.Page)
+	}
+	links := NewPageLinks(pages)
+	if opts.Join {
+		var basePages []StagedPage
+		var baseFiles []string
+		for _, p := range opts.Base {
+			basePages = append(basePages, p.Page)
+			baseFiles = append(baseFiles, p.Path)
+		}
+		err := links.ReserveIDs(basePages, baseFiles)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reserve element IDs: %w", err)
+		}
+	}
+	banner, err := opts.banners(doc.Lang)
+	if err != nil {
+		return nil, nil, fmt.Errorf("banners for language %q: %w", doc.Lang, err)
+	}
+	pagesDir := projectDir
+	if opts.Join {
+		// pages are joined from a temporary directory, so only the joined document is written to the output
+		pagesDir, err = os.MkdirTemp("", "illuminated-join-")
+		if err != nil {
+			return nil, nil, fmt.Errorf("create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(pagesDir)
+		err = os.MkdirAll(path.Join(pagesDir, DefaultDirNameOutput), DefaultFilePermissions)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create temporary output directory: %w", err)
+		}
+	}
+	var files []string
+	var missing []MissingLink
+	for i, p := range doc.Pages {
+		dst := path.Join(pagesDir, DefaultDirNameOutput, path.Base(p.Path))
+		m, err := links.ResolveFile(p.Path, dst, p.Page, doc.Lang, opts.Join)
+		if err != nil {
+			return nil, nil, fmt.Errorf("write %q to output: %w", p.Path, err)
+		}
+		missing = append(missing, m...)
+		if banner != nil {
+			err = addBanner(dst, banner, p.Page, opts.originalHref(p.Page))
+			if err != nil {
+				return nil, nil, fmt.Errorf("add banner to %q: %w", dst, err)
+			}
+		}
+		if opts.Join {
+			continue
+		}
+		pageData := data
+		pageData.Pages = slices.Clone(data.Pages)
+		pageData.Pages[i].Current = true
+		err = opts.Templates.ApplyPage(dst, pageData)
+		if err != nil {
+			return nil, nil, fmt.Errorf("apply template to %q: %w", dst, err)
+		}
+		files = append(files, dst)
+	}
+	if !opts.Join {
+		return files, missing, nil
+	}
+
+	var order []string
+	for _, p := range doc.Pages {
+		order = append(order, strings.TrimSuffix(p.Page.Name, ".md"))
+	}
+	joinedPages, err := JoinHTML(doc.Lang, pagesDir, name, order, links, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("join HTML files for language %q: %w", doc.Lang, err)
+	}
+	joinedFile := JoinedHTMLPath(doc.Lang, projectDir, name)
+	err = CopyFile(joinedPages, joinedFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("write joined HTML for language %q: %w", doc.Lang, err)
+	}
+	data.Title = opts.title()
+	data.Front, err = opts.frontPages(doc.Lang)
+	if err != nil {
+		return nil, nil, fmt.Errorf("front pages for language %q: %w", doc.Lang, err)
+	}
+	err = opts.Templates.ApplyJoined(joinedFile, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("apply template to joined HTML for language %q: %w", doc.Lang, err)
+	}
+	return []string{joinedFile}, missing, nil
+}
+
+// missingLinks returns the links of the pages of doc to pages which don't exist, without writing anything.
+func missingLinks(doc Document, join bool) ([]MissingLink, error) {
+	var pages []StagedPage
+	for _, p := range doc.Pages {
+		pages = append(pages, p.Page)
+	}
+	links := NewPageLinks(pages)
+	var missing []MissingLink
+	for _, p := range doc.Pages {
+		pageDoc, err := readHTML(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, links.Resolve(pageDoc, p.Page, doc.Lang, join)...)
+	}
+	return missing, nil
+}
+
+// documentData returns the template data of doc, listing each page with the title of its HTML.
+func documentData(doc Document, opts RenderOptions) (TemplateData, error) {
+	info := Language(doc.Lang)
+	data := TemplateData{
+		Lang:  info.Tag,
+		Dir:   info.Direction,
+		Build: opts.Build,
+	}
+	for _, p := range doc.Pages {
+		name := strings.TrimSuffix(p.Page.Name, ".md")
+		pageTitle, err := ReadTitle(p.Path)
+		if err != nil {
+			return data, err
+		}
+		if pageTitle == "" {
+			pageTitle = strings.ReplaceAll(name, "-", " ")
+		}
+		href := fmt.Sprintf("%s.%s.html", doc.Lang, name)
+		if opts.Join {
+			href = "#" + PageAnchor(p.Page.Name)
+		}
+		data.Pages = append(data.Pages, TemplatePage{Name: name, Title: pageTitle, Href: href})
+	}
+	return data, nil
+}
+
+// PDFRenderer writes a PDF of each page, or with RenderOptions.Join, a single PDF per language,
+// typeset by WritePDF with the profile of the language.
+type PDFRenderer struct{}
+
+// Render writes the PDFs of docs.
+func (PDFRenderer) Render(docs []Document, opts RenderOptions) ([]MissingLink, error) {
+	profiles, err := readProfiles(opts.ProfilesPath)
+	if err != nil {
+		return nil, err
+	}
+	layout := opts.Layout
+	if opts.Join && opts.Front != nil {
+		// the cover of the front pages replaces the title page
+		layout.Cover = false
+	}
+	coverHash, _ := HashFile(layout.CoverImage)
+	var missing []MissingLink
+	for _, doc := range docs {
+		profile := profiles.Profile(doc.Lang)
+		profile.PDFEngine = cmp.Or(opts.PDFEngine, profile.PDFEngine)
+		m, err := renderFromHTML(doc, opts, "pdf",
+			[]string{fmt.Sprint(profile), fmt.Sprint(layout), coverHash},
+			func(sourcePath, outPath, resources string, meta Metadata) error {
+				return WritePDF(sourcePath, outPath, resources, meta, profile, layout)
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, m...)
+	}
+	return missing, nil
+}
+
+// OfficeRenderer writes an editable document of each page in a word processor format, DOCX or ODT,
+// or with RenderOptions.Join, a single document per language, written by WriteOffice
+// with the fonts and direction of the profile of the language.
+type OfficeRenderer struct {
+	Format string // FormatDOCX or FormatODT
+}
+
+// Render writes the documents of docs.
+func (r OfficeRenderer) Render(docs []Document, opts RenderOptions) ([]MissingLink, error) {
+	profiles, err := readProfiles(opts.ProfilesPath)
+	if err != nil {
+		return nil, err
+	}
+	// reference documents only apply to their own format
+	referenceDoc := opts.ReferenceDoc
+	if referenceDoc != "" && strings.TrimPrefix(path.Ext(referenceDoc), ".") != r.Format {
+		slog.Debug("reference document not used for format", "file", referenceDoc, "format", r.Format)
+		referenceDoc = ""
+	}
+	referenceHash, _ := HashFile(referenceDoc)
+	var missing []MissingLink
+	for _, doc := range docs {
+		profile := profiles.Profile(doc.Lang)
+		m, err := renderFromHTML(doc, opts, r.Format,
+			[]string{profile.MainFont, profile.MonoFont, profile.Direction, referenceHash},
+			func(sourcePath, outPath, resources string, meta Metadata) error {
+				return WriteOffice(sourcePath, outPath, resources, referenceDoc, meta, profile)
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, m...)
+	}
+	return missing, nil
+}
+
+// readProfiles reads the language profiles at profilesPath, using the built-in profiles if there is no such file.
+func readProfiles(profilesPath string) (LanguageProfiles, error) {
+	profiles, err := ReadLanguageProfiles(profilesPath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		slog.Debug("no language profiles file found, using built-in profiles", "expected", profilesPath)
+	}
+	return profiles, nil
+}
+
+// renderFromHTML writes the HTML of doc to a temporary directory, as for HTMLRenderer, and converts each file written
+// to a document with the extension ext by write, described by the metadata of opts in the language of doc
+// and dated by the build, leaving HTML output to HTMLRenderer.
+// Documents whose HTML, title and other inputs are unchanged are skipped,
+// as are existing files not written by a build, unless RenderOptions.Force is set.
+func renderFromHTML(
+	doc Document,
+	opts RenderOptions,
+	ext string,
+	inputs []string,
+	write func(sourcePath, outPath, resources string, meta Metadata) error,
+) ([]MissingLink, error) {
+	slog.Debug("generating "+ext, "lang", doc.Lang)
+	tmp, err := os.MkdirTemp("", "illuminated-html-")
+	if err != nil {
+		return nil, fmt.Errorf("create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	err = os.MkdirAll(path.Join(tmp, DefaultDirNameOutput), DefaultFilePermissions)
+	if err != nil {
+		return nil, fmt.Errorf("create temporary output directory: %w", err)
+	}
+	// banners link to HTML output, which other documents don't include
+	opts.Banner = false
+	files, missing, err := writeDocumentHTML(doc, opts, tmp, path.Base(opts.ProjectDir))
+	if err != nil {
+		return nil, err
+	}
+
+	resources := path.Join(opts.ProjectDir, DefaultDirNameStaging)
+	for i, sourcePath := range files {
+		name := path.Base(sourcePath)
+		// individual pages may define their own title in front matter
+		docTitle := opts.Title
+		if opts.Join {
+			name = strings.ReplaceAll(opts.title(), " ", "_")
+		} else if pageTitle := doc.Pages[i].Page.Meta.Title; pageTitle != "" {
+			docTitle = pageTitle
+		}
+		outPath := path.Join(opts.outputDir(), fmt.Sprintf("%s.%s.%s", doc.Lang, name, ext))
+		sourceHash, err := HashFile(sourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("hash HTML file %q: %w", sourcePath, err)
+		}
+		digest := Digest(append([]string{sourceHash, docTitle, fmt.Sprint(opts.Metadata)}, inputs...)...)
+		if opts.fresh(outPath, digest) {
+			slog.Debug("skipping unchanged "+ext, "file", outPath)
+			continue
+		}
+		// files written by earlier builds are rebuilt when their inputs change, other files are only overwritten if forced
+		if _, err := os.Stat(outPath); !os.IsNotExist(err) && opts.State.Hash(outPath) == "" && !opts.Force {
+			slog.Info("skipping file to avoid clobber, set -f/--force to overwrite", "file", outPath)
+			continue
+		}
+		meta := opts.Metadata
+		meta.Lang = Language(doc.Lang).Tag
+		meta.Date = opts.Build.Date
+		meta.Title, err = opts.translateTitle(doc.Lang, docTitle)
+		if err != nil {
+			return nil, err
+		}
+		meta.Subject, err = opts.translateTitle(doc.Lang, meta.Subject)
+		if err != nil {
+			return nil, err
+		}
+		err = write(sourcePath, outPath, resources, meta)
+		if err != nil {
+			return nil, fmt.Errorf("generate %s for lang %q: %w", ext, doc.Lang, err)
+		}
+		err = opts.State.Record(outPath, digest)
+		if err != nil {
+			return nil, fmt.Errorf("record %q in build state: %w", outPath, err)
+		}
+	}
+	return missing, nil
+}
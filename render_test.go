@@ -0,0 +1,160 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testRenderer struct{}
+
+func (testRenderer) Render(docs []Document, opts RenderOptions) ([]MissingLink, error) {
+	return nil, nil
+}
+
+func TestRegisterRenderer(t *testing.T) {
+	require.Equal(t, []string{"html", "pdf", "docx", "odt", "site", "epub", "markdown"}, Formats())
+
+	r, err := LookupRenderer("pdf")
+	require.NoError(t, err)
+	require.Equal(t, PDFRenderer{}, r)
+	_, err = LookupRenderer("rtf")
+	require.ErrorContains(t, err, `unknown format "rtf", expected one of: html, pdf, docx, odt`)
+
+	RegisterRenderer("test", testRenderer{})
+	t.Cleanup(func() {
+		delete(renderers, "test")
+		formats = formats[:len(formats)-1]
+	})
+	require.Equal(t, "test", Formats()[len(Formats())-1])
+	require.Panics(t, func() { RegisterRenderer("test", testRenderer{}) })
+}
+
+// renderTestOptions builds the HTML of pages in the build directory of a new project,
+// returning their document and the options to render it with, translated from en by a mock translator.
+func renderTestOptions(t *testing.T, lang string, pages map[string]string, order ...string) (Document, RenderOptions) {
+	dir := t.TempDir()
+	for _, d := range []string{DefaultDirNameBuild, DefaultDirNameOutput, DefaultDirNameStaging} {
+		require.NoError(t, os.MkdirAll(path.Join(dir, d), 0o755))
+	}
+	state, err := ReadBuildState(dir)
+	require.NoError(t, err)
+	templates, err := LoadTemplates("", nil)
+	require.NoError(t, err)
+	doc := Document{Lang: lang}
+	for _, name := range order {
+		file := path.Join(dir, DefaultDirNameBuild, lang+"."+name+".html")
+		require.NoError(t, os.WriteFile(file, []byte(pages[name]), 0o644))
+		require.NoError(t, state.Record(file, ""))
+		doc.Pages = append(doc.Pages, DocumentPage{Page: StagedPage{Name: name + ".md"}, Path: file})
+	}
+	return doc, RenderOptions{
+		ProjectDir: dir,
+		Title:      "User Guide",
+		Base:       doc.Pages,
+		Templates:  templates,
+		State:      state,
+		Build:      BuildInfo{Generator: "illuminated", BaseLang: "en", Translator: "mock"},
+		// titles are translated by prefixing the language, kept as they are in the base language
+		TranslateTitle: func(lang, title string) (string, error) {
+			if lang == "en" {
+				return title, nil
+			}
+			return lang + ":" + title, nil
+		},
+	}
+}
+
+func TestHTMLRenderer(t *testing.T) {
+	pages := map[string]string{
+		"Home":    `<html><body><h1>Home</h1><p><a href="Install">install</a> <a href="Missing">missing</a></p></body></html>`,
+		"Install": `<html><body><h1>Install</h1></body></html>`,
+	}
+	doc, opts := renderTestOptions(t, "en", pages, "Home", "Install")
+	output := path.Join(opts.ProjectDir, DefaultDirNameOutput)
+
+	missing, err := HTMLRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	require.Equal(t, []MissingLink{{Page: "Home.md", Href: "Missing"}}, missing)
+	content, err := os.ReadFile(path.Join(output, "en.Home.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), `<a href="en.Install.html">install</a>`)
+	require.FileExists(t, path.Join(output, "en.Install.html"))
+
+	opts.Join = true
+	_, err = HTMLRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	joined := JoinedHTMLPath("en", opts.ProjectDir, path.Base(opts.ProjectDir))
+	content, err = os.ReadFile(joined)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `<title>User Guide</title>`)
+	require.Contains(t, string(content), `<a href="#Install">install</a>`)
+	// only the joined document is written, pages written before are kept as they are
+	entries, err := os.ReadDir(output)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	content, err = os.ReadFile(path.Join(output, "en.Home.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), `<a href="en.Install.html">install</a>`)
+	require.True(t, opts.State.Fresh(joined, opts.State.Artifacts[opts.State.key(joined)].Inputs))
+
+	// links to missing pages are reported when the joined HTML is unchanged
+	missing, err = HTMLRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	require.Equal(t, []MissingLink{{Page: "Home.md", Href: "Missing"}}, missing)
+}
+
+func TestPDFRenderer(t *testing.T) {
+	pages := map[string]string{
+		"Home":    `<html><body><h1>Home</h1><p>Welcome</p></body></html>`,
+		"Install": `<html><body><h1>Install</h1></body></html>`,
+	}
+	doc, opts := renderTestOptions(t, "en", pages, "Home", "Install")
+	output := path.Join(opts.ProjectDir, DefaultDirNameOutput)
+	opts.PDFEngine = PDFEngineNative
+	opts.Join = true
+	var titles []string
+	opts.TranslateTitle = func(lang, title string) (string, error) {
+		titles = append(titles, lang+":"+title)
+		return title, nil
+	}
+
+	_, err := PDFRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	entries, err := os.ReadDir(output)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "only the PDF is written to the output")
+	require.Equal(t, "en.User_Guide.pdf", entries[0].Name())
+	require.Equal(t, []string{"en:User Guide"}, titles)
+
+	// unchanged PDFs are skipped
+	_, err = PDFRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	require.Len(t, titles, 1)
+
+	// PDFs are rebuilt when a page changes, without forcing
+	pdf := path.Join(output, "en.User_Guide.pdf")
+	digest := opts.State.Artifacts[opts.State.key(pdf)].Inputs
+	require.NoError(t, os.WriteFile(doc.Pages[1].Path, []byte(`<html><body><h1>Install</h1><p>Run it</p></body></html>`), 0o644))
+	_, err = PDFRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	require.Len(t, titles, 2)
+	require.NotEqual(t, digest, opts.State.Artifacts[opts.State.key(pdf)].Inputs)
+
+	// files not written by a build are only overwritten if forced
+	opts.State.Forget(pdf)
+	require.NoError(t, os.WriteFile(pdf, []byte("mine"), 0o644))
+	_, err = PDFRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	content, err := os.ReadFile(pdf)
+	require.NoError(t, err)
+	require.Equal(t, "mine", string(content))
+	opts.Force = true
+	_, err = PDFRenderer{}.Render([]Document{doc}, opts)
+	require.NoError(t, err)
+	content, err = os.ReadFile(pdf)
+	require.NoError(t, err)
+	require.NotEqual(t, "mine", string(content))
+}
@@ -0,0 +1,173 @@
+package illuminated
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Search index file names, written to the directory of each language of a site.
+var (
+	DefaultFileNameSearchIndex       = "search-index.json"
+	DefaultFileNameSearchIndexScript = "search-index.js"
+)
+
+// searchIndexVariable is the global variable the search index script assigns the index to,
+// so the index loads from a script element without a server.
+const searchIndexVariable = "illuminatedSearchIndex"
+
+// SearchIndex is an index of the pages of a site in one language, for client side search.
+type SearchIndex struct {
+	Lang string `json:"lang"`
+	// NGram is the length of n-grams CJK text is split into, or 0 if it is split into words.
+	NGram int              `json:"ngram"`
+	Docs  []SearchDoc      `json:"docs"`
+	Terms map[string][]int `json:"terms"` // token to the indexes of the docs containing it
+}
+
+// SearchDoc is a page in a SearchIndex.
+type SearchDoc struct {
+	URL      string          `json:"url"`
+	Title    string          `json:"title"`
+	Headings []SearchHeading `json:"headings,omitempty"`
+	Text     string          `json:"text"`
+}
+
+// SearchHeading is a heading of a page in a SearchIndex, linking to its section.
+type SearchHeading struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// ngramScripts are the scripts written without spaces between words,
+// which are split into n-grams rather than words.
+var ngramScripts = []string{"Hans", "Hant", "Hani", "Jpan", "Hira", "Kana"}
+
+// NewSearchIndex returns an empty search index of pages in language lang.
+func NewSearchIndex(lang string) *SearchIndex {
+	index := &SearchIndex{Lang: lang, Terms: map[string][]int{}}
+	if slices.Contains(ngramScripts, Language(lang).Script) {
+		index.NGram = 2
+	}
+	return index
+}
+
+// Add adds the HTML document doc, linked as url, to the index.
+func (x *SearchIndex) Add(url, title string, doc *html.Node) {
+	d := SearchDoc{URL: url, Title: title}
+	for _, h := range headings(doc) {
+		d.Headings = append(d.Headings, SearchHeading{
+			ID:   attr(h, "id"),
+			Text: strings.TrimSpace(textContent(h)),
+		})
+	}
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	d.Text = strings.Join(strings.Fields(searchText(body)), " ")
+
+	i := len(x.Docs)
+	x.Docs = append(x.Docs, d)
+	seen := map[string]bool{}
+	for _, token := range Tokenize(title+" "+d.Text, x.NGram) {
+		if !seen[token] {
+			seen[token] = true
+			x.Terms[token] = append(x.Terms[token], i)
+		}
+	}
+}
+
+// Write writes the index as JSON to jsonPath, and as a script assigning it to a global variable to scriptPath.
+func (x *SearchIndex) Write(jsonPath, scriptPath string) error {
+	b, err := json.Marshal(x)
+	if err != nil {
+		return fmt.Errorf("marshal search index: %w", err)
+	}
+	err = os.WriteFile(jsonPath, b, DefaultFilePermissions)
+	if err != nil {
+		return fmt.Errorf("write %q: %w", jsonPath, err)
+	}
+	var script bytes.Buffer
+	fmt.Fprintf(&script, "window.%s = %s;\n", searchIndexVariable, b)
+	err = os.WriteFile(scriptPath, script.Bytes(), DefaultFilePermissions)
+	if err != nil {
+		return fmt.Errorf("write %q: %w", scriptPath, err)
+	}
+	return nil
+}
+
+// searchText returns the text of n, excluding elements which aren't content.
+func searchText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	if n.Type == html.ElementNode {
+		switch n.DataAtom {
+		case atom.Script, atom.Style, atom.Nav, atom.Template:
+			return ""
+		}
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(searchText(c))
+		if c.Type == html.ElementNode {
+			// separate the text of blocks, such as table cells
+			b.WriteString(" ")
+		}
+	}
+	return b.String()
+}
+
+// Tokenize splits text into lower case search tokens: words of letters, marks and numbers,
+// with runs of CJK characters split into overlapping n-grams when ngram is set.
+// The search script tokenizes queries the same way.
+func Tokenize(text string, ngram int) []string {
+	var tokens []string
+	var word, cjk []rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+		if len(cjk) > 0 {
+			if len(cjk) < ngram {
+				tokens = append(tokens, string(cjk))
+			}
+			for i := 0; i+ngram <= len(cjk); i++ {
+				tokens = append(tokens, string(cjk[i:i+ngram]))
+			}
+			cjk = cjk[:0]
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case ngram > 0 && isCJK(r):
+			if len(word) > 0 {
+				flush()
+			}
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			if len(cjk) > 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// isCJK reports whether r is a Chinese, Japanese or Korean character.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
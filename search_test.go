@@ -0,0 +1,63 @@
+package illuminated
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestTokenize(t *testing.T) {
+	for _, tc := range []struct {
+		text  string
+		ngram int
+		want  []string
+	}{
+		{"Install Lantern, v7.2!", 0, []string{"install", "lantern", "v7", "2"}},
+		{"نصب لنترن", 0, []string{"نصب", "لنترن"}},
+		{"下载安装", 2, []string{"下载", "载安", "安装"}},
+		{"用 Lantern 翻墙", 2, []string{"用", "lantern", "翻墙"}},
+		{"安装Lantern", 2, []string{"安装", "lantern"}},
+		{"下载安装", 0, []string{"下载安装"}},
+	} {
+		require.Equal(t, tc.want, Tokenize(tc.text, tc.ngram), tc.text)
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	require.Equal(t, 2, NewSearchIndex("zh").NGram)
+	require.Equal(t, 2, NewSearchIndex("zh-TW").NGram)
+	require.Equal(t, 0, NewSearchIndex("fa").NGram)
+
+	index := NewSearchIndex("zh")
+	for _, page := range []struct{ url, doc string }{
+		{"Install.html", `<html><body><h1 id="install">安装</h1><p>下载安装</p><script>ignored()</script></body></html>`},
+		{"Usage.html", `<html><body><h1 id="usage">使用</h1><p>打开 Lantern</p></body></html>`},
+	} {
+		doc, err := html.Parse(strings.NewReader(page.doc))
+		require.NoError(t, err)
+		index.Add(page.url, documentTitle(doc), doc)
+	}
+	require.Equal(t, []int{0}, index.Terms["安装"])
+	require.Equal(t, []int{1}, index.Terms["lantern"])
+	require.NotContains(t, index.Terms, "ignored")
+	require.Equal(t, []SearchHeading{{ID: "install", Text: "安装"}}, index.Docs[0].Headings)
+	require.Equal(t, "安装 下载安装", index.Docs[0].Text)
+
+	dir := t.TempDir()
+	jsonPath, scriptPath := path.Join(dir, "index.json"), path.Join(dir, "index.js")
+	err := index.Write(jsonPath, scriptPath)
+	require.NoError(t, err)
+	b, err := os.ReadFile(jsonPath)
+	require.NoError(t, err)
+	var read SearchIndex
+	require.NoError(t, json.Unmarshal(b, &read))
+	require.Equal(t, *index, read)
+	script, err := os.ReadFile(scriptPath)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(script), "window.illuminatedSearchIndex = {"))
+}
@@ -0,0 +1,260 @@
+package illuminated
+
+import (
+	"slices"
+	"unicode"
+
+	"golang.org/x/text/unicode/bidi"
+)
+
+// arabicForms maps Arabic letters to their isolated, final, initial and medial presentation forms.
+// Letters joining only to the letter before them have no initial or medial forms,
+// and letters joining neither way have only an isolated form.
+var arabicForms = map[rune][]rune{}
+
+func init() {
+	for _, letters := range []struct {
+		first rune // first letter of a sequence in the Arabic block
+		form  rune // isolated form of the first letter
+		forms []int
+	}{
+		// the forms of letters U+0621 to U+064A follow each other in Arabic Presentation Forms-B
+		{0x0621, 0xFE80, []int{1, 2, 2, 2, 2, 4, 2, 4, 2, 4, 4, 4, 4, 4, 2, 2, 2, 2, 4, 4, 4, 4, 4, 4, 4, 4}},
+		{0x0641, 0xFED1, []int{4, 4, 4, 4, 4, 4, 4, 2, 2, 4}},
+	} {
+		form := letters.form
+		for i, n := range letters.forms {
+			for j := range n {
+				arabicForms[letters.first+rune(i)] = append(arabicForms[letters.first+rune(i)], form+rune(j))
+			}
+			form += rune(n)
+		}
+	}
+	// letters of Persian and Urdu, in Arabic Presentation Forms-A
+	for letter, forms := range map[rune][]rune{
+		0x0679: {0xFB66, 0xFB67, 0xFB68, 0xFB69}, // ٹ
+		0x067E: {0xFB56, 0xFB57, 0xFB58, 0xFB59}, // پ
+		0x0686: {0xFB7A, 0xFB7B, 0xFB7C, 0xFB7D}, // چ
+		0x0688: {0xFB88, 0xFB89},                 // ڈ
+		0x0691: {0xFB8C, 0xFB8D},                 // ڑ
+		0x0698: {0xFB8A, 0xFB8B},                 // ژ
+		0x06A9: {0xFB8E, 0xFB8F, 0xFB90, 0xFB91}, // ک
+		0x06AF: {0xFB92, 0xFB93, 0xFB94, 0xFB95}, // گ
+		0x06BA: {0xFB9E, 0xFB9F},                 // ں
+		0x06BE: {0xFBAA, 0xFBAB, 0xFBAC, 0xFBAD}, // ھ
+		0x06C1: {0xFBA6, 0xFBA7, 0xFBA8, 0xFBA9}, // ہ
+		0x06CC: {0xFBFC, 0xFBFD, 0xFBFE, 0xFBFF}, // ی
+		0x06D2: {0xFBAE, 0xFBAF},                 // ے
+	} {
+		arabicForms[letter] = forms
+	}
+}
+
+// lamAlef maps the alefs following lam to the isolated forms of their ligatures,
+// each followed by its final form.
+var lamAlef = map[rune]rune{
+	0x0622: 0xFEF5, // آ
+	0x0623: 0xFEF7, // أ
+	0x0625: 0xFEF9, // إ
+	0x0627: 0xFEFB, // ا
+}
+
+const (
+	tatweel       = 0x0640
+	zeroWidthJoin = 0x200D
+	lam           = 0x0644
+)
+
+// joinsBoth reports whether r joins to the letters on either side of it.
+func joinsBoth(r rune) bool {
+	return len(arabicForms[r]) == 4 || r == tatweel || r == zeroWidthJoin
+}
+
+// joinsBefore reports whether r joins to the letter before it.
+func joinsBefore(r rune) bool {
+	return len(arabicForms[r]) >= 2 || r == tatweel || r == zeroWidthJoin
+}
+
+// transparent reports whether r is skipped when joining letters, such as vowel marks.
+func transparent(r rune) bool {
+	return unicode.Is(unicode.Mn, r)
+}
+
+// shapeArabic replaces the Arabic letters of text with the presentation forms of their positions in words,
+// as fonts are used without their own shaping rules. Forms for which has is false are left unshaped.
+func shapeArabic(text []rune, has func(rune) bool) []rune {
+	neighbour := func(i, step int) rune {
+		for i += step; i >= 0 && i < len(text); i += step {
+			if !transparent(text[i]) {
+				return text[i]
+			}
+		}
+		return 0
+	}
+	shaped := make([]rune, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		r := text[i]
+		forms, ok := arabicForms[r]
+		if !ok {
+			shaped = append(shaped, r)
+			continue
+		}
+		joinPrev := joinsBefore(r) && joinsBoth(neighbour(i, -1))
+		if r == lam {
+			// lam followed by alef is a ligature, with the marks between them after it
+			j := i + 1
+			for j < len(text) && transparent(text[j]) {
+				j++
+			}
+			if j < len(text) && lamAlef[text[j]] != 0 {
+				ligature := lamAlef[text[j]]
+				if joinPrev {
+					ligature++
+				}
+				if has(ligature) {
+					shaped = append(shaped, ligature)
+					shaped = append(shaped, text[i+1:j]...)
+					i = j
+					continue
+				}
+			}
+		}
+		joinNext := joinsBoth(r) && joinsBefore(neighbour(i, 1))
+		form := forms[0]
+		switch {
+		case joinPrev && joinNext:
+			form = forms[3]
+		case joinPrev:
+			form = forms[1]
+		case joinNext:
+			form = forms[2]
+		}
+		if !has(form) {
+			form = r
+		}
+		shaped = append(shaped, form)
+	}
+	return shaped
+}
+
+// mirrored maps brackets to those facing the other way, as displayed in right to left text.
+var mirrored = map[rune]rune{
+	'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{', '<': '>', '>': '<',
+	'«': '»', '»': '«', '‹': '›', '›': '‹',
+}
+
+// bidiLevels returns the embedding level of each character of text in a paragraph written right to left if rtl,
+// following a simplified Unicode bidirectional algorithm: even levels are displayed left to right and odd levels
+// right to left, numbers read left to right, and neutral characters such as spaces take the direction of the text around them.
+func bidiLevels(text []rune, rtl bool) []int {
+	const (
+		left = iota
+		right
+		number
+		neutral
+	)
+	kinds := make([]int, len(text))
+	for i, r := range text {
+		props, _ := bidi.LookupRune(r)
+		switch props.Class() {
+		case bidi.L:
+			kinds[i] = left
+		case bidi.R, bidi.AL:
+			kinds[i] = right
+		case bidi.EN, bidi.AN:
+			kinds[i] = number
+		default:
+			kinds[i] = neutral
+		}
+	}
+	// separators between digits are part of the number, such as 1,000 and 1.5
+	for i := 1; i+1 < len(text); i++ {
+		props, _ := bidi.LookupRune(text[i])
+		if (props.Class() == bidi.CS || props.Class() == bidi.ES) && kinds[i-1] == number && kinds[i+1] == number {
+			kinds[i] = number
+		}
+	}
+	strong := func(i, step int) int {
+		for ; i >= 0 && i < len(text); i += step {
+			if kinds[i] != neutral {
+				return kinds[i]
+			}
+		}
+		return -1
+	}
+
+	base, ltr := 0, 0
+	if rtl {
+		base, ltr = 1, 2
+	}
+	levels := make([]int, len(text))
+	for i, kind := range kinds {
+		switch kind {
+		case left:
+			levels[i] = ltr
+		case right:
+			levels[i] = 1
+		case number:
+			// numbers are left to right, embedded in right to left text when they follow it
+			levels[i] = ltr
+			if strong(i-1, -1) == right {
+				levels[i] = 2
+			}
+		case neutral:
+			// numbers count as right to left text for the neutrals around them
+			before, after := strong(i-1, -1), strong(i+1, 1)
+			if before == number {
+				before = right
+			}
+			if after == number {
+				after = right
+			}
+			switch {
+			case before == after && before == left:
+				levels[i] = ltr
+			case before == after && before == right:
+				levels[i] = 1
+			default:
+				levels[i] = base
+			}
+		}
+	}
+	return levels
+}
+
+// visualOrder returns the indexes of characters at levels in the order they are displayed from left to right,
+// reversing runs at each level and above, from the highest level down to 1.
+func visualOrder(levels []int) []int {
+	order := make([]int, len(levels))
+	for i := range order {
+		order[i] = i
+	}
+	for level := slices.Max(append(levels, 0)); level >= 1; level-- {
+		for i := 0; i < len(order); {
+			if levels[order[i]] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(order) && levels[order[j]] >= level {
+				j++
+			}
+			slices.Reverse(order[i:j])
+			i = j
+		}
+	}
+	return order
+}
+
+// visualText returns text in display order, with brackets mirrored in right to left runs.
+func visualText(text []rune, rtl bool) []rune {
+	levels := bidiLevels(text, rtl)
+	visual := make([]rune, len(text))
+	for i, j := range visualOrder(levels) {
+		visual[i] = text[j]
+		if m, ok := mirrored[text[j]]; ok && levels[j]%2 == 1 {
+			visual[i] = m
+		}
+	}
+	return visual
+}
@@ -0,0 +1,51 @@
+package illuminated
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShapeArabic(t *testing.T) {
+	all := func(rune) bool { return true }
+	for _, tc := range []struct {
+		text string
+		want []rune
+	}{
+		{"سلام", []rune{0xFEB3, 0xFEFC, 0xFEE1}},         // initial seen, final lam-alef, isolated meem
+		{"کتاب", []rune{0xFB90, 0xFE98, 0xFE8E, 0xFE8F}}, // Persian keheh, then medial, final and isolated forms
+		{"در", []rune{0xFEA9, 0xFEAD}},                   // letters joining only to the letter before them
+		{"بَب", []rune{0xFE91, 0x064E, 0xFE90}},          // vowel marks between letters are skipped
+		{"a ب", []rune{'a', ' ', 0xFE8F}},
+	} {
+		t.Run(tc.text, func(t *testing.T) {
+			require.Equal(t, tc.want, shapeArabic([]rune(tc.text), all))
+		})
+	}
+
+	// forms missing from fonts are left unshaped, with lam and alef apart
+	none := func(rune) bool { return false }
+	require.Equal(t, []rune("سلام"), shapeArabic([]rune("سلام"), none))
+}
+
+func TestVisualText(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		rtl  bool
+		want string
+	}{
+		{"abc def", false, "abc def"},
+		{"abc def", true, "abc def"},
+		{"אבג דהו", true, "והד גבא"},
+		{"אבג Lantern VPN דהו", true, "והד Lantern VPN גבא"},
+		{"abc אבג דהו xyz", false, "abc והד גבא xyz"},
+		{"אבג 123 דהו", true, "והד 123 גבא"},
+		{"אבג 1,000.5", true, "1,000.5 גבא"},
+		{"(אבג)", true, "(גבא)"},
+		{"1.", true, ".1"},
+	} {
+		t.Run(tc.text, func(t *testing.T) {
+			require.Equal(t, tc.want, string(visualText([]rune(tc.text), tc.rtl)))
+		})
+	}
+}
@@ -0,0 +1,425 @@
+package illuminated
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultFileNameSitemap is the name of the sitemap written to the root of sites.
+var DefaultFileNameSitemap = "sitemap.xml"
+
+// Site is a static site of pages in several languages,
+// with a directory of pages and an index page per language.
+type Site struct {
+	Dir       string // directory the site is written to
+	URL       string // base URL the site is served from, such as https://example.com/guide
+	Title     string
+	Resources string // directory of files linked from pages, such as images
+	Templates *Templates
+	Build     BuildInfo
+
+	languages []string
+	pages     map[string][]sitePage // language to pages, in page order
+	banners   map[string]bannerFunc // language to the banners of its translated pages, if any
+}
+
+// sitePage is the HTML built for a page in a single language.
+type sitePage struct {
+	page  StagedPage
+	file  string
+	doc   *html.Node
+	title string
+}
+
+// NewSite returns a Site written to dir, rendered with templates.
+func NewSite(dir string, templates *Templates) *Site {
+	return &Site{
+		Dir:       dir,
+		Templates: templates,
+		pages:     map[string][]sitePage{},
+		banners:   map[string]bannerFunc{},
+	}
+}
+
+// SiteRenderer writes a static site of the documents of every language, the first listed first.
+type SiteRenderer struct{}
+
+// Render writes the site of docs to the site directory of the output.
+func (SiteRenderer) Render(docs []Document, opts RenderOptions) ([]MissingLink, error) {
+	s := NewSite(path.Join(opts.outputDir(), DefaultDirNameSite), opts.Templates)
+	s.URL = opts.SiteURL
+	s.Title = opts.title()
+	s.Resources = path.Join(opts.ProjectDir, DefaultDirNameStaging)
+	s.Build = opts.Build
+	for _, doc := range docs {
+		for _, p := range doc.Pages {
+			s.Add(doc.Lang, p.Page, p.Path)
+		}
+		banner, err := opts.banners(doc.Lang)
+		if err != nil {
+			return nil, fmt.Errorf("banners for language %q: %w", doc.Lang, err)
+		}
+		if banner != nil {
+			s.banners[doc.Lang] = banner
+		}
+	}
+	missing, err := s.Write()
+	if err != nil {
+		return nil, fmt.Errorf("write site: %w", err)
+	}
+	slog.Info("site written", "dir", s.Dir)
+	return missing, nil
+}
+
+// Add adds the HTML file of page in language lang to the site.
+// Languages are listed in the order they are first added.
+func (s *Site) Add(lang string, page StagedPage, file string) {
+	if _, ok := s.pages[lang]; !ok {
+		s.languages = append(s.languages, lang)
+	}
+	s.pages[lang] = append(s.pages[lang], sitePage{page: page, file: file})
+}
+
+// Write writes the site, replacing any previous site in its directory.
+// Links to pages which don't exist are returned.
+func (s *Site) Write() ([]MissingLink, error) {
+	err := os.RemoveAll(s.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("remove previous site: %w", err)
+	}
+	for _, lang := range s.languages {
+		err = os.MkdirAll(path.Join(s.Dir, lang), DefaultFilePermissions)
+		if err != nil {
+			return nil, fmt.Errorf("create site directory for language %q: %w", lang, err)
+		}
+		for i, p := range s.pages[lang] {
+			p.doc, err = readHTML(p.file)
+			if err != nil {
+				return nil, err
+			}
+			p.title = documentTitle(p.doc)
+			if p.title == "" {
+				p.title = strings.ReplaceAll(PageAnchor(p.page.Name), "-", " ")
+			}
+			s.pages[lang][i] = p
+		}
+	}
+
+	var missing []MissingLink
+	for _, lang := range s.languages {
+		m, err := s.writeLanguage(lang)
+		if err != nil {
+			return nil, fmt.Errorf("write site in language %q: %w", lang, err)
+		}
+		missing = append(missing, m...)
+	}
+	err = s.writeRoot()
+	if err != nil {
+		return nil, err
+	}
+	err = s.copyResources()
+	if err != nil {
+		return nil, err
+	}
+	searchScript := path.Join(s.Dir, TemplateFileSearch)
+	err = os.WriteFile(searchScript, s.Templates.search, DefaultFilePermissions)
+	if err != nil {
+		return nil, fmt.Errorf("write %q: %w", searchScript, err)
+	}
+	err = s.writeSitemap()
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// writeLanguage writes the pages and index page of a language.
+func (s *Site) writeLanguage(lang string) ([]MissingLink, error) {
+	pages := s.pages[lang]
+	var staged []StagedPage
+	var listed []TemplatePage
+	for _, p := range pages {
+		staged = append(staged, p.page)
+		listed = append(listed, TemplatePage{
+			Name:  PageAnchor(p.page.Name),
+			Title: p.title,
+			Href:  sitePageFile(p.page),
+		})
+	}
+	links := NewPageLinks(staged)
+	links.Files = sitePageFile
+
+	info := Language(lang)
+	data := TemplateData{
+		Lang:      info.Tag,
+		Dir:       info.Direction,
+		Build:     s.Build,
+		SiteTitle: s.Title,
+		Root:      "../",
+	}
+	search := NewSearchIndex(lang)
+	var missing []MissingLink
+	for i, p := range pages {
+		rebaseResources(p.doc, "../")
+		missing = append(missing, links.Resolve(p.doc, p.page, lang, false)...)
+		pageData := data
+		pageData.Pages = make([]TemplatePage, len(listed))
+		copy(pageData.Pages, listed)
+		pageData.Pages[i].Current = true
+		pageData.Languages = s.alternates(p.page.Name, lang, sitePageFile(p.page), "../")
+		// banners aren't content to search, so pages are indexed without them
+		search.Add(sitePageFile(p.page), p.title, p.doc)
+		if banner := s.banners[lang]; banner != nil {
+			var href string
+			if s.has(s.Build.BaseLang, p.page.Name) {
+				href = "../" + s.Build.BaseLang + "/" + url.PathEscape(sitePageFile(p.page))
+			}
+			b, err := banner(p.page, href)
+			if err != nil {
+				return nil, err
+			}
+			if b != "" {
+				err = insertBanner(p.doc, b)
+				if err != nil {
+					return nil, fmt.Errorf("add banner to %q: %w", p.page.Name, err)
+				}
+			}
+		}
+		err := s.render(p.doc, pageData, path.Join(s.Dir, lang, sitePageFile(p.page)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := search.Write(
+		path.Join(s.Dir, lang, DefaultFileNameSearchIndex),
+		path.Join(s.Dir, lang, DefaultFileNameSearchIndexScript),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// the index lists the pages of the language
+	var body strings.Builder
+	fmt.Fprintf(&body, "<h1>%s</h1>\n<ul class=\"index\">\n", html.EscapeString(s.Title))
+	for _, p := range listed {
+		fmt.Fprintf(&body, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(p.Href), html.EscapeString(p.Title))
+	}
+	body.WriteString("</ul>\n")
+	data.Title = s.Title
+	data.Pages = listed
+	data.Languages = s.alternates("", lang, "index.html", "../")
+	err = s.renderBody(body.String(), data, path.Join(s.Dir, lang, "index.html"))
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// writeRoot writes the index page at the root of the site, linking to each language.
+func (s *Site) writeRoot() error {
+	if len(s.languages) == 0 {
+		return nil
+	}
+	languages := s.alternates("", "", "index.html", "")
+	var body strings.Builder
+	fmt.Fprintf(&body, "<h1>%s</h1>\n<ul class=\"languages\">\n", html.EscapeString(s.Title))
+	for _, l := range languages {
+		fmt.Fprintf(&body, "<li><a href=\"%s\" hreflang=\"%s\" lang=\"%s\" dir=\"%s\">%s</a></li>\n",
+			html.EscapeString(l.Href), l.Tag, l.Tag, l.Dir, html.EscapeString(cmp.Or(l.Name, l.Tag)))
+	}
+	body.WriteString("</ul>\n")
+	info := Language(s.languages[0])
+	data := TemplateData{
+		Lang:      info.Tag,
+		Dir:       info.Direction,
+		Title:     s.Title,
+		Build:     s.Build,
+		SiteTitle: s.Title,
+		Languages: languages,
+	}
+	return s.renderBody(body.String(), data, path.Join(s.Dir, "index.html"))
+}
+
+// alternates returns the languages in which page (or, if empty, any index page) is available,
+// linking to file in the directory of each language from prefix, and from the site URL if set.
+func (s *Site) alternates(page, current, file, prefix string) []TemplateLanguage {
+	var languages []TemplateLanguage
+	for _, lang := range s.languages {
+		if page != "" && !s.has(lang, page) {
+			continue
+		}
+		info := Language(lang)
+		languages = append(languages, TemplateLanguage{
+			Tag:     info.Tag,
+			Name:    info.Name,
+			Dir:     info.Direction,
+			Href:    prefix + lang + "/" + url.PathEscape(file),
+			URL:     s.url(lang, file),
+			Current: lang == current,
+		})
+	}
+	return languages
+}
+
+// has reports whether page is available in lang.
+func (s *Site) has(lang, page string) bool {
+	for _, p := range s.pages[lang] {
+		if p.page.Name == page {
+			return true
+		}
+	}
+	return false
+}
+
+// url returns the absolute URL of file in the directory of lang, or an empty string without a site URL.
+func (s *Site) url(lang, file string) string {
+	if s.URL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(s.URL, "/") + "/" + lang + "/" + url.PathEscape(file)
+}
+
+// render writes doc to filePath rendered with the site template.
+func (s *Site) render(doc *html.Node, data TemplateData, filePath string) error {
+	out, err := s.Templates.render(s.Templates.site, doc, data)
+	if err != nil {
+		return fmt.Errorf("%q: %w", filePath, err)
+	}
+	err = os.WriteFile(filePath, out, DefaultFilePermissions)
+	if err != nil {
+		return fmt.Errorf("write %q: %w", filePath, err)
+	}
+	return nil
+}
+
+// renderBody writes the HTML body to filePath rendered with the site template.
+func (s *Site) renderBody(body string, data TemplateData, filePath string) error {
+	doc, err := html.Parse(strings.NewReader("<html><head></head><body>" + body + "</body></html>"))
+	if err != nil {
+		return fmt.Errorf("parse %q: %w", filePath, err)
+	}
+	return s.render(doc, data, filePath)
+}
+
+// copyResources copies files linked from pages, such as images, to the root of the site.
+func (s *Site) copyResources() error {
+	if s.Resources == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(s.Resources)
+	if err != nil {
+		return fmt.Errorf("read resources directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || path.Ext(entry.Name()) == ".md" {
+			continue
+		}
+		err = CopyFile(path.Join(s.Resources, entry.Name()), path.Join(s.Dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("copy resource %q: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// sitemap is a sitemap.xml document, listing each page with its alternate languages.
+type sitemap struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	XHTML   string       `xml:"xmlns:xhtml,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc   string        `xml:"loc"`
+	Links []sitemapLink `xml:"xhtml:link"`
+}
+
+type sitemapLink struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// writeSitemap writes the sitemap of every page and index page in every language.
+// Sitemaps require absolute URLs, so locations are relative to the site root without a URL.
+func (s *Site) writeSitemap() error {
+	if s.URL == "" {
+		slog.Warn("site URL not set, sitemap locations will be relative")
+	}
+	m := sitemap{
+		XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		XHTML: "http://www.w3.org/1999/xhtml",
+	}
+	add := func(lang, page, file string) {
+		u := sitemapURL{Loc: cmp.Or(s.url(lang, file), lang+"/"+url.PathEscape(file))}
+		for _, alt := range s.alternates(page, lang, file, "") {
+			u.Links = append(u.Links, sitemapLink{Rel: "alternate", Hreflang: alt.Tag, Href: cmp.Or(alt.URL, alt.Href)})
+		}
+		m.URLs = append(m.URLs, u)
+	}
+	for _, lang := range s.languages {
+		add(lang, "", "index.html")
+		for _, p := range s.pages[lang] {
+			add(lang, p.page.Name, sitePageFile(p.page))
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	err := enc.Encode(m)
+	if err != nil {
+		return fmt.Errorf("encode sitemap: %w", err)
+	}
+	buf.WriteString("\n")
+	filePath := path.Join(s.Dir, DefaultFileNameSitemap)
+	err = os.WriteFile(filePath, buf.Bytes(), DefaultFilePermissions)
+	if err != nil {
+		return fmt.Errorf("write %q: %w", filePath, err)
+	}
+	return nil
+}
+
+// sitePageFile returns the file name of page within its language directory.
+func sitePageFile(page StagedPage) string {
+	return PageAnchor(page.Name) + ".html"
+}
+
+// rebaseResources prefixes relative links to resources in doc, other than links to pages,
+// with prefix, for pages written to a subdirectory of their resources.
+func rebaseResources(doc *html.Node, prefix string) {
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for i, a := range n.Attr {
+				switch {
+				case a.Key == "src" || a.Key == "poster":
+				case a.Key == "href" && n.DataAtom == atom.A && hrefPage(a.Val) == "":
+				default:
+					continue
+				}
+				u, err := url.Parse(a.Val)
+				if err != nil || u.IsAbs() || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+					continue
+				}
+				n.Attr[i].Val = prefix + a.Val
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+}
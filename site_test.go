@@ -0,0 +1,75 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSiteWrite(t *testing.T) {
+	dir := t.TempDir()
+	resources := path.Join(dir, "staging")
+	require.NoError(t, os.MkdirAll(resources, 0o755))
+	require.NoError(t, os.WriteFile(path.Join(resources, "logo.png"), []byte("png"), 0o644))
+	require.NoError(t, os.WriteFile(path.Join(resources, "Install.md"), []byte("# Install"), 0o644))
+
+	build := func(name, content string) string {
+		file := path.Join(dir, name)
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+		return file
+	}
+	install := StagedPage{Name: "Install.md"}
+	usage := StagedPage{Name: "Usage.md"}
+
+	templates, err := LoadTemplates("", nil)
+	require.NoError(t, err)
+	site := NewSite(path.Join(dir, "site"), templates)
+	site.URL = "https://example.com/guide/"
+	site.Title = "Guide"
+	site.Resources = resources
+	site.Add("en", install, build("en.Install.html",
+		`<html lang="en"><body><h1>Install</h1><p><a href="Usage#run">usage</a><img src="logo.png"></p></body></html>`))
+	site.Add("en", usage, build("en.Usage.html", `<html lang="en"><body><h1>Usage</h1><a href="Missing">missing</a></body></html>`))
+	// usage is not available in fa
+	site.Add("fa", install, build("fa.Install.html", `<html lang="fa"><body><h1>نصب</h1></body></html>`))
+
+	missing, err := site.Write()
+	require.NoError(t, err)
+	require.Equal(t, []MissingLink{{Page: "Usage.md", Href: "Missing"}}, missing)
+
+	for _, file := range []string{
+		"index.html", "sitemap.xml", "logo.png", "search.js",
+		"en/index.html", "en/Install.html", "en/Usage.html", "en/search-index.json", "en/search-index.js",
+		"fa/index.html", "fa/Install.html", "fa/search-index.json", "fa/search-index.js",
+	} {
+		require.FileExists(t, path.Join(dir, "site", file))
+	}
+	require.NoFileExists(t, path.Join(dir, "site", "Install.md"))
+	require.NoFileExists(t, path.Join(dir, "site", "fa", "Usage.html"))
+
+	content, err := os.ReadFile(path.Join(dir, "site", "en", "Install.html"))
+	require.NoError(t, err)
+	page := string(content)
+	require.Contains(t, page, `<html lang="en" dir="ltr">`)
+	require.Contains(t, page, `<a href="Usage.html#run">usage</a>`)
+	require.Contains(t, page, `<img src="../logo.png"/>`)
+	require.Contains(t, page, `<link rel="alternate" hreflang="fa" href="https://example.com/guide/fa/Install.html">`)
+	require.Contains(t, page, `<a href="../fa/Install.html" hreflang="fa" lang="fa" dir="rtl">فارسی</a>`)
+	require.Contains(t, page, `<a href="Install.html" aria-current="page">Install</a>`)
+
+	content, err = os.ReadFile(path.Join(dir, "site", "en", "Usage.html"))
+	require.NoError(t, err)
+	require.NotContains(t, string(content), `hreflang="fa"`)
+
+	content, err = os.ReadFile(path.Join(dir, "site", "fa", "Install.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), `<html lang="fa" dir="rtl">`)
+
+	content, err = os.ReadFile(path.Join(dir, "site", "sitemap.xml"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), `<loc>https://example.com/guide/en/Usage.html</loc>`)
+	require.Contains(t, string(content),
+		`<xhtml:link rel="alternate" hreflang="fa" href="https://example.com/guide/fa/Install.html"></xhtml:link>`)
+}
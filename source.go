@@ -1,6 +1,7 @@
 package illuminated
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -8,6 +9,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/go-git/go-git/v5"
@@ -21,77 +25,228 @@ var WikiIgnore = []string{
 	"_Footer",
 }
 
+// PageIgnore defines file names of wiki pages which aren't content, ignored when staging from any source:
+// the table of contents (see DefaultFileNameTOC) and the footer.
+var PageIgnore = []string{DefaultFileNameTOC, "_Footer.md"}
+
+// NamespaceSeparator joins a source namespace to the names of its staged pages.
+const NamespaceSeparator = "."
+
+// ErrStageConflict is returned when sources stage different files of the same name.
+var ErrStageConflict = errors.New("conflicting files staged from sources")
+
+// Source is a single location staged into the document set.
+type Source struct {
+	// Namespace optionally prefixes the names of pages staged from this source,
+	// avoiding collisions with pages from other sources.
+	Namespace string
+	// Location is a directory, GitHub wiki URL, archive, or "-" for stdin.
+	Location string
+}
+
+// StagedPage describes a markdown page staged from a source.
+type StagedPage struct {
+	Name      string      // staged file name, including any namespace
+	Namespace string      // namespace of the source the page was staged from
+	Origin    string      // directory the page was staged from, which may hold git history
+	File      string      // file name within Origin
+	Meta      FrontMatter // metadata from the front matter of the page
+}
+
+// ParseSource parses a source of the form [namespace=]location.
+func ParseSource(spec string) Source {
+	namespace, location, ok := strings.Cut(spec, "=")
+	if ok && reNamespace.MatchString(namespace) {
+		return Source{Namespace: namespace, Location: location}
+	}
+	return Source{Location: spec}
+}
+
+var reNamespace = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 // Stage fetches new source files for processing,
 // copying them to illuminated.DefaultDirNameStaging.
 // Accepted sources include:
 //   - local directory path
 //   - GitHub wiki URL
+//   - .zip, .tar.gz or .tgz archive (local path or file:// URL)
+//   - "-" for a tar stream on stdin (optionally gzipped)
 func Stage(source string, projectDir string) error {
-	parsedURL, err := url.Parse(source)
-	if err == nil && parsedURL.Scheme != "" && parsedURL.Host != "" {
-		slog.Debug("staging remote wiki", "URL", parsedURL)
-		err = cloneRepo(source, path.Join(projectDir, DefaultDirNameStaging))
+	_, err := StageSources([]Source{ParseSource(source)}, projectDir)
+	return err
+}
+
+// StageSources stages files from each source into illuminated.DefaultDirNameStaging.
+// Staged pages are returned in source order, then in the order of each source's
+// table of contents (see DefaultFileNameTOC), with remaining pages sorted by name,
+// and finally by any order set in page front matter.
+// Files of the same name staged from different sources are reported as conflicts.
+func StageSources(sources []Source, projectDir string) ([]StagedPage, error) {
+	stagingDir := path.Join(projectDir, DefaultDirNameStaging)
+	// start empty so pages removed from a source are no longer processed
+	err := os.RemoveAll(stagingDir)
+	if err != nil {
+		return nil, fmt.Errorf("clear staging: %w", err)
+	}
+	err = os.MkdirAll(stagingDir, 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("create staging: %w", err)
+	}
+
+	var pages []StagedPage
+	var conflicts []string
+	stagedFrom := map[string]string{} // staged file name to source location
+	for i, src := range sources {
+		origin, ignore, err := fetch(src, projectDir, i)
+		if err != nil {
+			return nil, fmt.Errorf("fetch source %q: %w", src.Location, err)
+		}
+		toc, err := tableOfContents(origin)
 		if err != nil {
-			return fmt.Errorf("clone repo: %w", err)
+			return nil, fmt.Errorf("read table of contents of %q: %w", src.Location, err)
 		}
-		// remove ignored files
-		dir, err := os.ReadDir(path.Join(projectDir, DefaultDirNameStaging))
+		entries, err := os.ReadDir(origin)
 		if err != nil {
-			return fmt.Errorf("read staging dir: %w", err)
+			return nil, fmt.Errorf("read dir: %w", err)
 		}
-		for _, entry := range dir {
+		var sourcePages []StagedPage
+		for _, entry := range entries {
 			if entry.IsDir() {
+				slog.Debug("ignoring directory", "name", entry.Name())
 				continue
 			}
-			for _, ignore := range WikiIgnore {
-				if strings.Contains(entry.Name(), ignore) {
-					slog.Debug("removing ignored file", "name", entry.Name())
-					err = os.Remove(path.Join(projectDir, DefaultDirNameStaging, entry.Name()))
-					if err != nil {
-						return fmt.Errorf("remove ignored file: %w", err)
-					}
-				}
+			if ignored(entry.Name(), ignore) || slices.Contains(PageIgnore, entry.Name()) {
+				slog.Debug("ignoring file", "name", entry.Name())
+				continue
 			}
-		}
-	} else {
-		slog.Debug("staging local source", "source", source)
-		err = os.MkdirAll(path.Join(projectDir, DefaultDirNameStaging), 0o750)
-		if err != nil {
-			return fmt.Errorf("create staging: %w", err)
-		}
-		info, err := os.Stat(source)
-		if err != nil {
-			return fmt.Errorf("invalid source: %w", err)
-		}
-		if info.IsDir() {
-			entries, err := os.ReadDir(source)
-			if err != nil {
-				return fmt.Errorf("read dir: %w", err)
+			isPage := strings.HasSuffix(entry.Name(), ".md")
+			name := entry.Name()
+			if isPage && src.Namespace != "" {
+				name = src.Namespace + NamespaceSeparator + name
 			}
-			for _, entry := range entries {
-				if entry.IsDir() {
-					slog.Debug("ignoring directory", "name", entry.Name())
+			sourcePath := filepath.Join(origin, entry.Name())
+			stagedPath := filepath.Join(stagingDir, name)
+			if prev, ok := stagedFrom[name]; ok {
+				// identical resources, such as shared images, don't conflict
+				if !isPage && sameContent(sourcePath, stagedPath) {
 					continue
 				}
-				slog.Debug("copying file", "name", entry.Name())
-				err = copy(
-					filepath.Join(source, entry.Name()),
-					filepath.Join(projectDir, DefaultDirNameStaging, entry.Name()),
-				)
+				conflicts = append(conflicts, fmt.Sprintf("%q from %q and %q", name, prev, src.Location))
+				continue
+			}
+			stagedFrom[name] = src.Location
+
+			slog.Debug("copying file", "name", entry.Name(), "staged", name)
+			err = CopyFile(sourcePath, stagedPath)
+			if err != nil {
+				return nil, fmt.Errorf("stage file %q from dir: %w", entry.Name(), err)
+			}
+			if isPage {
+				meta, err := ReadFrontMatter(stagedPath)
 				if err != nil {
-					return fmt.Errorf("stage file %q from dir: %w", entry.Name(), err)
+					return nil, fmt.Errorf("stage file %q: %w", entry.Name(), err)
 				}
+				sourcePages = append(sourcePages, StagedPage{
+					Name:      name,
+					Namespace: src.Namespace,
+					Origin:    origin,
+					File:      entry.Name(),
+					Meta:      meta,
+				})
 			}
-		} else {
-			return fmt.Errorf("source is not a directory: %v", source)
 		}
+		pages = append(pages, orderPages(sourcePages, toc)...)
 	}
-	slog.Info("staging complete", "dir", path.Join(projectDir, DefaultDirNameStaging))
-	return nil
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrStageConflict, strings.Join(conflicts, ", "))
+	}
+	slog.Info("staging complete", "dir", stagingDir, "pages", len(pages))
+	return pages, nil
+}
+
+// fetch retrieves a source, returning the directory holding its files
+// and the file names to ignore when staging from it.
+// Remote and archive sources are fetched into illuminated.DefaultDirNameSources,
+// in ns-<namespace> or, without a namespace, src-<index>, so the two can't share a directory.
+func fetch(src Source, projectDir string, index int) (string, []string, error) {
+	key := "src-" + strconv.Itoa(index)
+	if src.Namespace != "" {
+		key = "ns-" + src.Namespace
+	}
+	sourceDir := path.Join(projectDir, DefaultDirNameSources, key)
+
+	location := src.Location
+	parsedURL, err := url.Parse(location)
+	if err == nil && parsedURL.Scheme == "file" {
+		// file:// URLs are staged the same as local paths
+		location = parsedURL.Path
+	}
+
+	switch {
+	case location == StdinSource:
+		slog.Debug("staging archive from stdin")
+		root, err := extractArchive(os.Stdin, ".tar", sourceDir)
+		if err != nil {
+			return "", nil, fmt.Errorf("stage stdin: %w", err)
+		}
+		return root, nil, nil
+	case archiveExtension(location) != "":
+		slog.Debug("staging local archive", "source", location)
+		f, err := os.Open(location)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid source: %w", err)
+		}
+		defer f.Close()
+		root, err := extractArchive(f, archiveExtension(location), sourceDir)
+		if err != nil {
+			return "", nil, fmt.Errorf("stage archive %q: %w", location, err)
+		}
+		return root, nil, nil
+	case err == nil && parsedURL.Scheme != "" && parsedURL.Host != "":
+		slog.Debug("staging remote wiki", "URL", parsedURL)
+		err = cloneRepo(location, sourceDir)
+		if err != nil {
+			return "", nil, fmt.Errorf("clone repo: %w", err)
+		}
+		return sourceDir, WikiIgnore, nil
+	default:
+		slog.Debug("staging local source", "source", location)
+		info, err := os.Stat(location)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid source: %w", err)
+		}
+		if !info.IsDir() {
+			return "", nil, fmt.Errorf("source is not a directory: %v", location)
+		}
+		return location, nil, nil
+	}
+}
+
+// ignored reports whether name matches any of the ignore patterns.
+func ignored(name string, ignore []string) bool {
+	for _, i := range ignore {
+		if strings.Contains(name, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameContent reports whether the files at a and b have identical content.
+func sameContent(a, b string) bool {
+	hashA, err := HashFile(a)
+	if err != nil {
+		return false
+	}
+	hashB, err := HashFile(b)
+	if err != nil {
+		return false
+	}
+	return hashA == hashB
 }
 
-// copy a single file from src to dst
-func copy(src, dst string) error {
+// CopyFile copies a single file from src to dst.
+func CopyFile(src, dst string) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("open file: %w", err)
@@ -111,7 +266,8 @@ func copy(src, dst string) error {
 	return nil
 }
 
-// cloneRepo shallow clones a Git repository from the given URL to the specified path.
+// cloneRepo clones a Git repository from the given URL to the specified path.
+// The full history is fetched so translations can be compared to later revisions.
 func cloneRepo(url, path string) error {
 	if _, err := os.Stat(path); !os.IsNotExist(err) {
 		slog.Warn("repo already exists, replacing", "path", path)
@@ -124,7 +280,6 @@ func cloneRepo(url, path string) error {
 	_, err := git.PlainClone(path, false, &git.CloneOptions{
 		URL:      url,
 		Progress: os.Stdout,
-		Depth:    1,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to clone repository: %w", err)
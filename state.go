@@ -0,0 +1,167 @@
+package illuminated
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// BuildState records content hashes of staged sources and generated artifacts,
+// allowing subsequent builds to skip work whose inputs have not changed.
+type BuildState struct {
+	// Sources maps staged markdown file names to their content hash.
+	Sources map[string]string `json:"sources"`
+	// Artifacts maps generated files, relative to the project directory,
+	// to the digest of the inputs they were built from.
+	Artifacts map[string]Artifact `json:"artifacts"`
+	// Translations maps languages to staged markdown file names
+	// to the source each translated page was produced from.
+	Translations map[string]map[string]Translation `json:"translations"`
+
+	projectDir string
+	histories  map[string]*History // opened repositories by directory
+}
+
+// Artifact describes a single generated file.
+type Artifact struct {
+	Inputs string `json:"inputs"` // digest of all inputs used to build the file
+	Hash   string `json:"hash"`   // content hash of the file when it was built
+}
+
+// Translation describes the source a translated page was produced from.
+type Translation struct {
+	Source string `json:"source"`           // content hash of the staged markdown
+	Commit string `json:"commit,omitempty"` // last commit modifying the source, if staged from git
+	Origin string `json:"origin,omitempty"` // directory the page was staged from
+	File   string `json:"file,omitempty"`   // file name within Origin
+}
+
+// ReadBuildState reads the build state of projectDir,
+// returning an empty state if none has been written yet.
+func ReadBuildState(projectDir string) (*BuildState, error) {
+	s := &BuildState{
+		Sources:      map[string]string{},
+		Artifacts:    map[string]Artifact{},
+		Translations: map[string]map[string]Translation{},
+		projectDir:   projectDir,
+	}
+	statePath := path.Join(projectDir, DefaultFileNameBuildState)
+	b, err := os.ReadFile(statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			slog.Debug("no build state found, starting fresh", "path", statePath)
+			return s, nil
+		}
+		return nil, fmt.Errorf("read build state %q: %w", statePath, err)
+	}
+	err = json.Unmarshal(b, s)
+	if err != nil {
+		return nil, fmt.Errorf("decode build state %q: %w", statePath, err)
+	}
+	if s.Sources == nil {
+		s.Sources = map[string]string{}
+	}
+	if s.Artifacts == nil {
+		s.Artifacts = map[string]Artifact{}
+	}
+	if s.Translations == nil {
+		s.Translations = map[string]map[string]Translation{}
+	}
+	slog.Debug("build state read from file",
+		"sources", len(s.Sources),
+		"artifacts", len(s.Artifacts),
+		"path", statePath,
+	)
+	return s, nil
+}
+
+// Write saves the build state to the project directory.
+func (s *BuildState) Write() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal build state: %w", err)
+	}
+	statePath := path.Join(s.projectDir, DefaultFileNameBuildState)
+	err = os.WriteFile(statePath, b, 0o644)
+	if err != nil {
+		return fmt.Errorf("write build state %q: %w", statePath, err)
+	}
+	slog.Debug("build state written to file", "path", statePath)
+	return nil
+}
+
+// Fresh reports whether the artifact at filePath exists unmodified
+// and was built from inputs matching the given digest.
+func (s *BuildState) Fresh(filePath string, inputs string) bool {
+	a, ok := s.Artifacts[s.key(filePath)]
+	if !ok || a.Inputs != inputs {
+		return false
+	}
+	hash, err := HashFile(filePath)
+	if err != nil {
+		return false
+	}
+	return hash == a.Hash
+}
+
+// Record stores the current content hash of the artifact at filePath
+// along with the digest of the inputs it was built from.
+func (s *BuildState) Record(filePath string, inputs string) error {
+	hash, err := HashFile(filePath)
+	if err != nil {
+		return fmt.Errorf("hash artifact: %w", err)
+	}
+	s.Artifacts[s.key(filePath)] = Artifact{Inputs: inputs, Hash: hash}
+	return nil
+}
+
+// Hash returns the recorded content hash of the artifact at filePath,
+// or an empty string if it has not been recorded.
+func (s *BuildState) Hash(filePath string) string {
+	return s.Artifacts[s.key(filePath)].Hash
+}
+
+// Forget removes the artifact at filePath from the build state.
+func (s *BuildState) Forget(filePath string) {
+	delete(s.Artifacts, s.key(filePath))
+}
+
+// key returns the path of an artifact relative to the project directory.
+func (s *BuildState) key(filePath string) string {
+	rel, err := filepath.Rel(s.projectDir, filePath)
+	if err != nil {
+		return filepath.ToSlash(filePath)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// HashFile returns the hex encoded SHA-256 hash of the file at path.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open file %q: %w", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", fmt.Errorf("hash file %q: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Digest returns a single hash identifying all given parts, in order.
+func Digest(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		// length prefix prevents ambiguity between e.g. ("ab", "c") and ("a", "bc")
+		fmt.Fprintf(h, "%d:%s;", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,45 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildState(t *testing.T) {
+	dir := t.TempDir()
+	artifact := path.Join(dir, "en.test.html")
+	err := os.WriteFile(artifact, []byte("<h1>Hello World</h1>"), 0o644)
+	require.NoError(t, err)
+
+	state, err := ReadBuildState(dir)
+	require.NoError(t, err)
+	inputs := Digest("source", "en")
+	require.False(t, state.Fresh(artifact, inputs), "unrecorded artifact should not be fresh")
+
+	err = state.Record(artifact, inputs)
+	require.NoError(t, err)
+	require.True(t, state.Fresh(artifact, inputs))
+	require.False(t, state.Fresh(artifact, Digest("source", "zh")), "changed inputs should not be fresh")
+
+	err = state.Write()
+	require.NoError(t, err)
+
+	state, err = ReadBuildState(dir)
+	require.NoError(t, err)
+	require.True(t, state.Fresh(artifact, inputs), "state should persist between reads")
+
+	err = os.WriteFile(artifact, []byte("<h1>Modified</h1>"), 0o644)
+	require.NoError(t, err)
+	require.False(t, state.Fresh(artifact, inputs), "modified artifact should not be fresh")
+
+	state.Forget(artifact)
+	require.Empty(t, state.Hash(artifact))
+}
+
+func TestDigest(t *testing.T) {
+	require.Equal(t, Digest("a", "b"), Digest("a", "b"))
+	require.NotEqual(t, Digest("ab", "c"), Digest("a", "bc"))
+}
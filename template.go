@@ -0,0 +1,309 @@
+package illuminated
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Template file names, which a template directory may provide in place of the defaults.
+const (
+	TemplateFilePage   = "page.html"
+	TemplateFileJoined = "joined.html"
+	TemplateFileSite   = "site.html"
+	TemplateFileFront  = "front.html"
+	TemplateFileBanner = "banner.html"
+	TemplateFileStyle  = "style.css"
+	TemplateFileSearch = "search.js"
+)
+
+// DefaultTemplates are the templates and stylesheet of the default theme.
+//
+//go:embed templates
+var DefaultTemplates embed.FS
+
+// TemplateData is the data available to page and joined document templates.
+type TemplateData struct {
+	Lang   string         // language of the document
+	Dir    string         // text direction of the language, ltr or rtl
+	Title  string         // title of the page or joined document
+	Body   template.HTML  // content of the page or joined pages
+	TOC    template.HTML  // table of contents, if enabled
+	Front  template.HTML  // front pages of joined documents, if any
+	Pages  []TemplatePage // pages in the same language, in page order
+	Styles []template.CSS // stylesheets, the theme followed by any custom stylesheets
+	Head   template.HTML  // elements of the head of the document, such as stylesheets it links to
+	Build  BuildInfo
+
+	// Site output only
+	SiteTitle string             // title of the site
+	Languages []TemplateLanguage // languages the page is available in, including its own
+	Root      string             // relative path to the root of the site, such as "../"
+}
+
+// TemplatePage is a page listed in TemplateData.
+type TemplatePage struct {
+	Name    string // staged page name, without extension
+	Title   string
+	Href    string // link to the page, a file or an anchor in a joined document
+	Current bool   // the page being rendered
+}
+
+// TemplateLanguage is a language listed in TemplateData.
+type TemplateLanguage struct {
+	Tag     string // BCP 47 tag
+	Name    string // name of the language in itself
+	Dir     string
+	Href    string // relative link to the same page in the language
+	URL     string // absolute URL of the same page in the language, if the site URL is set
+	Current bool   // the language being rendered
+}
+
+// BuildInfo describes the build generating a document.
+type BuildInfo struct {
+	Generator  string
+	Date       time.Time
+	BaseLang   string
+	Translator string
+}
+
+// Templates render pages and joined documents into complete HTML documents.
+type Templates struct {
+	page     *template.Template
+	joined   *template.Template
+	site     *template.Template
+	front    *template.Template
+	banner   *template.Template
+	styles   []template.CSS
+	search   []byte // site search script
+	digest   string
+	TOCDepth int // deepest heading level in the table of contents, none if 0
+}
+
+// LoadTemplates reads the page, joined document, site, front pages and banner templates, theme stylesheet
+// and site search script from dir,
+// using the default for any not found, followed by the stylesheets at css.
+// An empty dir uses the defaults.
+func LoadTemplates(dir string, css []string) (*Templates, error) {
+	read := func(name string) ([]byte, error) {
+		if dir != "" {
+			b, err := os.ReadFile(path.Join(dir, name))
+			if err == nil {
+				return b, nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("read template %q: %w", name, err)
+			}
+		}
+		return DefaultTemplates.ReadFile(path.Join("templates", name))
+	}
+
+	t := &Templates{}
+	var sources []string
+	for _, tc := range []struct {
+		name string
+		tmpl **template.Template
+	}{
+		{TemplateFilePage, &t.page},
+		{TemplateFileJoined, &t.joined},
+		{TemplateFileSite, &t.site},
+		{TemplateFileFront, &t.front},
+		{TemplateFileBanner, &t.banner},
+	} {
+		b, err := read(tc.name)
+		if err != nil {
+			return nil, err
+		}
+		*tc.tmpl, err = template.New(tc.name).Parse(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("parse template %q: %w", tc.name, err)
+		}
+		sources = append(sources, string(b))
+	}
+	style, err := read(TemplateFileStyle)
+	if err != nil {
+		return nil, err
+	}
+	t.styles = append(t.styles, template.CSS(style))
+	for _, file := range css {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read stylesheet %q: %w", file, err)
+		}
+		t.styles = append(t.styles, template.CSS(b))
+	}
+	for _, style := range t.styles {
+		sources = append(sources, string(style))
+	}
+	t.search, err = read(TemplateFileSearch)
+	if err != nil {
+		return nil, err
+	}
+	sources = append(sources, string(t.search))
+	t.digest = Digest(sources...)
+	return t, nil
+}
+
+// Digest identifies the templates and stylesheets, so documents are regenerated when they change.
+func (t *Templates) Digest() string {
+	return Digest(t.digest, fmt.Sprint(t.TOCDepth))
+}
+
+// ApplyPage renders the HTML file of a page at filePath with the page template, in place.
+func (t *Templates) ApplyPage(filePath string, data TemplateData) error {
+	return t.apply(t.page, filePath, data)
+}
+
+// ApplyJoined renders the joined HTML document at filePath with the joined template, in place.
+func (t *Templates) ApplyJoined(filePath string, data TemplateData) error {
+	return t.apply(t.joined, filePath, data)
+}
+
+// frontPagesClass is the class of the element holding the front pages of a document.
+const frontPagesClass = "front-pages"
+
+// RenderFront renders the front pages of a document with the front pages template.
+func (t *Templates) RenderFront(data FrontData) (template.HTML, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, `<div class="%s">`, frontPagesClass)
+	err := t.front.Execute(&out, data)
+	if err != nil {
+		return "", fmt.Errorf("execute template %q: %w", t.front.Name(), err)
+	}
+	out.WriteString("</div>")
+	return template.HTML(out.String()), nil
+}
+
+// apply renders the body of the HTML file at filePath with tmpl, in place.
+func (t *Templates) apply(tmpl *template.Template, filePath string, data TemplateData) error {
+	doc, err := readHTML(filePath)
+	if err != nil {
+		return err
+	}
+	out, err := t.render(tmpl, doc, data)
+	if err != nil {
+		return fmt.Errorf("%q: %w", filePath, err)
+	}
+	err = os.WriteFile(filePath, out, DefaultFilePermissions)
+	if err != nil {
+		return fmt.Errorf("write %q: %w", filePath, err)
+	}
+	return nil
+}
+
+// render renders the body of doc with tmpl.
+// The title of the document is used if data has no title,
+// and the language of the document is used if declared.
+func (t *Templates) render(tmpl *template.Template, doc *html.Node, data TemplateData) ([]byte, error) {
+	if data.Title == "" {
+		data.Title = documentTitle(doc)
+	}
+	// pages kept in the base language declare so, rather than the language of the output
+	if root := findElement(doc, atom.Html); root != nil && attr(root, "lang") != "" {
+		info := Language(attr(root, "lang"))
+		data.Lang, data.Dir = info.Tag, info.Direction
+	}
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		return nil, errors.New("no body")
+	}
+	var b strings.Builder
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		err := html.Render(&b, c)
+		if err != nil {
+			return nil, fmt.Errorf("render body: %w", err)
+		}
+	}
+	data.Body = template.HTML(b.String())
+	b.Reset()
+	for _, n := range headResources(doc) {
+		err := html.Render(&b, n)
+		if err != nil {
+			return nil, fmt.Errorf("render head: %w", err)
+		}
+		b.WriteString("\n")
+	}
+	data.Head = template.HTML(b.String())
+	if toc := HeadingTOC(doc, t.TOCDepth); toc != nil {
+		b.Reset()
+		err := html.Render(&b, toc)
+		if err != nil {
+			return nil, fmt.Errorf("render table of contents: %w", err)
+		}
+		data.TOC = template.HTML(b.String())
+	}
+	data.Styles = t.styles
+
+	var out bytes.Buffer
+	err := tmpl.Execute(&out, data)
+	if err != nil {
+		return nil, fmt.Errorf("execute template %q: %w", tmpl.Name(), err)
+	}
+	return out.Bytes(), nil
+}
+
+// headResources returns the elements of the head of doc which templates don't provide themselves,
+// such as stylesheets and scripts: all but the character set, viewport, generator and title.
+func headResources(doc *html.Node) []*html.Node {
+	head := findElement(doc, atom.Head)
+	if head == nil {
+		return nil
+	}
+	var found []*html.Node
+	for c := head.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom == atom.Title || hasAttr(c, "charset") {
+			continue
+		}
+		if c.DataAtom == atom.Meta && (attr(c, "name") == "viewport" || attr(c, "name") == "generator") {
+			continue
+		}
+		found = append(found, c)
+	}
+	return found
+}
+
+// ReadTitle returns the title of the HTML document at filePath,
+// or the text of its first top-level heading.
+func ReadTitle(filePath string) (string, error) {
+	doc, err := readHTML(filePath)
+	if err != nil {
+		return "", err
+	}
+	return documentTitle(doc), nil
+}
+
+// documentTitle returns the title of an HTML document, or the text of its first top-level heading.
+func documentTitle(doc *html.Node) string {
+	if title := findElement(doc, atom.Title); title != nil {
+		if text := strings.TrimSpace(textContent(title)); text != "" {
+			return text
+		}
+	}
+	if h1 := findElement(doc, atom.H1); h1 != nil {
+		return strings.TrimSpace(textContent(h1))
+	}
+	return ""
+}
+
+// findElement returns the first element of type a in doc, or nil.
+func findElement(doc *html.Node, a atom.Atom) *html.Node {
+	if doc.Type == html.ElementNode && doc.DataAtom == a {
+		return doc
+	}
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if n := findElement(c, a); n != nil {
+			return n
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testPage is a page in the build directory, as written by MarkdownToHTML.
+const testPage = `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+</head>
+<body>
+<h1 id="install">نصب</h1>
+<h2 id="windows">ویندوز</h2>
+</body>
+</html>`
+
+func TestApplyPage(t *testing.T) {
+	dir := t.TempDir()
+	css := path.Join(dir, "custom.css")
+	err := os.WriteFile(css, []byte("body { color: red; }"), 0o644)
+	require.NoError(t, err)
+	templates, err := LoadTemplates("", []string{css})
+	require.NoError(t, err)
+	templates.TOCDepth = DefaultTOCDepth
+
+	file := path.Join(dir, "fa.Install.html")
+	err = os.WriteFile(file, []byte(testPage), 0o644)
+	require.NoError(t, err)
+	err = templates.ApplyPage(file, TemplateData{Lang: "fa", Dir: Language("fa").Direction})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `<html lang="fa" dir="rtl">`)
+	require.Contains(t, string(content), `<meta charset="UTF-8">`)
+	require.Contains(t, string(content), `<title>نصب</title>`)
+	require.Contains(t, string(content), `<style>body { color: red; }</style>`)
+	require.Contains(t, string(content), `<a href="#windows">ویندوز</a>`)
+	require.Contains(t, string(content), `<h1 id="install">نصب</h1>`)
+	require.NotContains(t, string(content), `<link`)
+
+	// resources the page links to from its head are kept
+	err = os.WriteFile(file, []byte(`<html><head><meta charset="UTF-8"><title>Old</title>`+
+		`<link rel="stylesheet" href="extra.css"></head><body><h1>نصب</h1></body></html>`), 0o644)
+	require.NoError(t, err)
+	err = templates.ApplyPage(file, TemplateData{Lang: "fa", Dir: Language("fa").Direction, Title: "نصب"})
+	require.NoError(t, err)
+	content, err = os.ReadFile(file)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `<link rel="stylesheet" href="extra.css"/>`)
+	require.NotContains(t, string(content), `Old`)
+	require.Equal(t, 1, strings.Count(string(content), `charset`))
+}
+
+func TestLoadTemplatesCustom(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(
+		path.Join(dir, TemplateFileJoined),
+		[]byte(`<html lang="{{.Lang}}"><body>{{range .Pages}}<a href="{{.Href}}">{{.Title}}</a>{{end}}{{.Body}}</body></html>`),
+		0o644,
+	)
+	require.NoError(t, err)
+	templates, err := LoadTemplates(dir, nil)
+	require.NoError(t, err)
+	defaults, err := LoadTemplates("", nil)
+	require.NoError(t, err)
+	require.NotEqual(t, defaults.Digest(), templates.Digest())
+
+	file := path.Join(dir, "en.docs.html")
+	err = os.WriteFile(file, []byte(`<html><body><p>Joined</p></body></html>`), 0o644)
+	require.NoError(t, err)
+	err = templates.ApplyJoined(file, TemplateData{
+		Lang:  "en",
+		Pages: []TemplatePage{{Name: "Install", Title: "Install", Href: "#Install"}},
+	})
+	require.NoError(t, err)
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, `<html lang="en"><body><a href="#Install">Install</a><p>Joined</p></body></html>`, string(content))
+}
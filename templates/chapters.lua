@@ -0,0 +1,59 @@
+-- chapters.lua is a pandoc filter for PDF, DOCX and ODT output:
+-- each chapter, a level 1 heading, starts on a new page,
+-- elements with the page-break class break the page where they are,
+-- and front pages precede the table of contents of PDFs.
+
+-- the first chapter of word processor documents follows the title on the first page
+local chapters = 0
+
+local function newpage(command)
+  if FORMAT == 'docx' then
+    return pandoc.RawBlock('openxml', '<w:p><w:r><w:br w:type="page"/></w:r></w:p>')
+  elseif FORMAT == 'odt' then
+    return pandoc.RawBlock('opendocument', '<text:p text:style-name="Pagebreak"/>')
+  end
+  return pandoc.RawBlock('latex', command)
+end
+
+function Header(el)
+  if el.level == 1 then
+    chapters = chapters + 1
+    if chapters == 1 and (FORMAT == 'docx' or FORMAT == 'odt') then
+      return el
+    end
+    return { newpage('\\clearpage'), el }
+  end
+end
+
+function Div(el)
+  if el.classes:includes('page-break') then
+    return { newpage('\\newpage') }
+  end
+end
+
+-- front pages, such as a cover and a license, precede the table of contents of PDFs,
+-- their cover replacing the title page
+function Pandoc(doc)
+  if not FORMAT:match('latex') then
+    return doc
+  end
+  local front
+  doc = doc:walk({
+    Div = function(el)
+      if front == nil and el.classes:includes('front-pages') then
+        front = el
+        return {}
+      end
+    end,
+  })
+  if front == nil then
+    return doc
+  end
+  doc.meta['include-before'] = pandoc.MetaBlocks(front.content)
+  if doc.meta.title then
+    -- the title is kept in the properties of the PDF
+    doc.meta['title-meta'] = pandoc.utils.stringify(doc.meta.title)
+    doc.meta.title = nil
+  end
+  return doc
+end
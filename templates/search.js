@@ -0,0 +1,141 @@
+// Client side search of a site generated by illuminated.
+// The index of each language is loaded from search-index.js, which works without a server.
+(function () {
+    "use strict";
+
+    var index = window.illuminatedSearchIndex;
+    var input = document.getElementById("search");
+    var list = document.getElementById("search-results");
+    if (!index || !input || !list) {
+        return;
+    }
+    var terms = Object.keys(index.terms);
+    var cjk = /[\p{Script=Han}\p{Script=Hiragana}\p{Script=Katakana}\p{Script=Hangul}]/u;
+    var wordChar = /[\p{L}\p{M}\p{N}]/u;
+
+    // tokenize splits text the same as Tokenize when the index was generated.
+    function tokenize(text, ngram) {
+        var tokens = [];
+        var word = [];
+        var run = [];
+        function flush() {
+            if (word.length) {
+                tokens.push(word.join(""));
+                word = [];
+            }
+            if (run.length) {
+                if (run.length < ngram) {
+                    tokens.push(run.join(""));
+                }
+                for (var i = 0; i + ngram <= run.length; i++) {
+                    tokens.push(run.slice(i, i + ngram).join(""));
+                }
+                run = [];
+            }
+        }
+        Array.from(text.toLowerCase()).forEach(function (c) {
+            if (ngram > 0 && cjk.test(c)) {
+                if (word.length) {
+                    flush();
+                }
+                run.push(c);
+            } else if (wordChar.test(c)) {
+                if (run.length) {
+                    flush();
+                }
+                word.push(c);
+            } else {
+                flush();
+            }
+        });
+        flush();
+        return tokens;
+    }
+
+    // matches returns the docs containing token, or a word starting with it if prefix is set.
+    function matches(token, prefix) {
+        var docs = {};
+        (index.terms[token] || []).forEach(function (d) { docs[d] = true; });
+        if (prefix) {
+            terms.forEach(function (term) {
+                if (term.length > token.length && term.indexOf(token) === 0) {
+                    index.terms[term].forEach(function (d) { docs[d] = true; });
+                }
+            });
+        }
+        return docs;
+    }
+
+    // search returns the docs containing every token of query, best matches first.
+    function search(query) {
+        var tokens = tokenize(query, index.ngram);
+        if (!tokens.length) {
+            return [];
+        }
+        var found = null;
+        tokens.forEach(function (token, i) {
+            var docs = matches(token, i === tokens.length - 1);
+            if (found === null) {
+                found = docs;
+                return;
+            }
+            Object.keys(found).forEach(function (d) {
+                if (!docs[d]) {
+                    delete found[d];
+                }
+            });
+        });
+        var q = query.trim().toLowerCase();
+        return Object.keys(found).map(function (d) {
+            var doc = index.docs[d];
+            var score = 0;
+            var heading = null;
+            if (doc.title.toLowerCase().indexOf(q) >= 0) {
+                score += 10;
+            }
+            (doc.headings || []).forEach(function (h) {
+                if (!heading && h.text.toLowerCase().indexOf(q) >= 0) {
+                    heading = h;
+                    score += 5;
+                }
+            });
+            return { doc: doc, heading: heading, score: score };
+        }).sort(function (a, b) {
+            return b.score - a.score;
+        });
+    }
+
+    // snippet returns the text of doc around the first occurrence of query.
+    function snippet(doc, query) {
+        var text = doc.text;
+        var i = text.toLowerCase().indexOf(query.trim().toLowerCase());
+        if (i < 0) {
+            return text.slice(0, 160);
+        }
+        var start = Math.max(0, i - 60);
+        return (start > 0 ? "…" : "") + text.slice(start, i + 100) + "…";
+    }
+
+    input.addEventListener("input", function () {
+        list.textContent = "";
+        var query = input.value;
+        var results = search(query).slice(0, 20);
+        list.hidden = !query.trim();
+        results.forEach(function (result) {
+            var item = document.createElement("li");
+            var link = document.createElement("a");
+            link.href = result.doc.url + (result.heading && result.heading.id ? "#" + result.heading.id : "");
+            link.textContent = result.heading ? result.doc.title + " › " + result.heading.text : result.doc.title;
+            var text = document.createElement("p");
+            text.textContent = snippet(result.doc, query);
+            item.appendChild(link);
+            item.appendChild(text);
+            list.appendChild(item);
+        });
+        if (!results.length && query.trim()) {
+            var none = document.createElement("li");
+            none.textContent = "∅";
+            list.appendChild(none);
+        }
+    });
+})();
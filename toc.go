@@ -0,0 +1,125 @@
+package illuminated
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// reTOCLink matches wiki links, [[Page]] or [[Text|Page]], and markdown links, [Text](Page).
+var reTOCLink = regexp.MustCompile(`\[\[([^\]]+)\]\]|\[[^\]]*\]\(\s*<?([^)\s>]+)>?[^)]*\)`)
+
+// tableOfContents returns the page file names linked from the table of contents
+// (DefaultFileNameTOC) in dir, in order of appearance.
+// No pages are returned if dir has no table of contents.
+func tableOfContents(dir string) ([]string, error) {
+	b, err := os.ReadFile(path.Join(dir, DefaultFileNameTOC))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read %q: %w", DefaultFileNameTOC, err)
+	}
+	var pages []string
+	for _, m := range reTOCLink.FindAllStringSubmatch(string(b), -1) {
+		var page string
+		if m[1] != "" {
+			page = wikiLinkPage(m[1])
+		} else {
+			page = hrefPage(m[2])
+		}
+		if page != "" {
+			pages = append(pages, page+".md")
+		}
+	}
+	return pages, nil
+}
+
+// wikiLinkPage returns the page name of a wiki link, [[Page Name]] or [[Link Text|Page Name]],
+// the same as GitHub which replaces spaces with hyphens.
+func wikiLinkPage(link string) string {
+	if before, target, ok := strings.Cut(link, "|"); ok {
+		link = target
+		if strings.HasPrefix(strings.TrimSpace(target), "alt=") {
+			// images are written [[image.png|alt=Alt Text]]
+			link = before
+		}
+	}
+	link, _, _ = strings.Cut(link, "#")
+	return strings.ReplaceAll(strings.TrimSpace(link), " ", "-")
+}
+
+// hrefPage returns the page name of a link to another wiki page,
+// either relative (Page, ./Page.md) or absolute (https://github.com/org/repo/wiki/Page).
+// An empty string is returned for links to anything other than a page.
+func hrefPage(href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	p := u.Path
+	if u.Scheme != "" || u.Host != "" {
+		_, after, ok := strings.Cut(p, "/wiki/")
+		if !ok {
+			return ""
+		}
+		p = after
+	}
+	if p == "" || strings.HasPrefix(p, "/") {
+		return ""
+	}
+	p = strings.TrimPrefix(path.Clean(p), "./")
+	if strings.Contains(p, "/") || strings.HasPrefix(p, "..") {
+		return ""
+	}
+	ext := path.Ext(p)
+	if ext != "" && ext != ".md" {
+		// dots are permitted in page names, but known file extensions are not pages
+		if _, ok := resourceExtensions[strings.ToLower(ext)]; ok {
+			return ""
+		}
+	} else {
+		p = strings.TrimSuffix(p, ".md")
+	}
+	return p
+}
+
+// resourceExtensions lists extensions of files linked from pages which are not pages themselves.
+var resourceExtensions = map[string]struct{}{
+	".png": {}, ".jpg": {}, ".jpeg": {}, ".gif": {}, ".svg": {}, ".webp": {},
+	".pdf": {}, ".zip": {}, ".gz": {}, ".txt": {}, ".html": {}, ".htm": {},
+	".apk": {}, ".exe": {}, ".dmg": {},
+}
+
+// orderPages sorts pages into the order of toc, followed by any pages missing from it, by name.
+// Pages are then sorted by the order of their front matter, keeping this order among equals.
+func orderPages(pages []StagedPage, toc []string) []StagedPage {
+	position := map[string]int{}
+	for i, file := range toc {
+		if _, ok := position[file]; !ok {
+			position[file] = i
+		}
+	}
+	ordered := make([]StagedPage, len(pages))
+	copy(ordered, pages)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		pi, iok := position[ordered[i].File]
+		pj, jok := position[ordered[j].File]
+		switch {
+		case iok && jok:
+			return pi < pj
+		case iok != jok:
+			return iok
+		default:
+			return ordered[i].File < ordered[j].File
+		}
+	})
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Meta.Order < ordered[j].Meta.Order
+	})
+	return ordered
+}
@@ -0,0 +1,74 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeSource writes files to a new source directory.
+func writeSource(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		err := os.WriteFile(path.Join(dir, name), []byte(content), 0o644)
+		require.NoError(t, err)
+	}
+	return dir
+}
+
+func TestTableOfContents(t *testing.T) {
+	dir := writeSource(t, map[string]string{
+		DefaultFileNameTOC: `* [[Getting Started]]
+* [[Install guide|Install]]
+* [Usage](Usage.md)
+* [FAQ](https://github.com/getlantern/guide/wiki/FAQ)
+* [Lantern](https://lantern.io)
+* ![logo](logo.png)`,
+	})
+	toc, err := tableOfContents(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Getting-Started.md", "Install.md", "Usage.md", "FAQ.md"}, toc)
+}
+
+func TestStageSources(t *testing.T) {
+	guide := writeSource(t, map[string]string{
+		DefaultFileNameTOC: "[[Usage]]\n[[Install]]",
+		"Install.md":       "# Install",
+		"Usage.md":         "# Usage",
+		"About.md":         "# About",
+		"_Footer.md":       "Lantern",
+		"logo.png":         "png",
+	})
+	faq := writeSource(t, map[string]string{
+		"Install.md": "# Install FAQ",
+		"logo.png":   "png",
+	})
+	projectDir := t.TempDir()
+
+	_, err := StageSources([]Source{{Location: guide}, {Location: faq}}, projectDir)
+	require.ErrorIs(t, err, ErrStageConflict)
+	require.Contains(t, err.Error(), `"Install.md"`)
+	require.NotContains(t, err.Error(), "logo.png", "identical resources should not conflict")
+
+	pages, err := StageSources([]Source{{Location: guide}, ParseSource("faq=" + faq)}, projectDir)
+	require.NoError(t, err)
+	var names []string
+	for _, page := range pages {
+		names = append(names, page.Name)
+	}
+	require.Equal(t, []string{"Usage.md", "Install.md", "About.md", "faq.Install.md"}, names)
+	_, err = os.Stat(path.Join(projectDir, DefaultDirNameStaging, "faq.Install.md"))
+	require.NoError(t, err)
+	// the table of contents and footer aren't staged as pages from any source
+	require.NoFileExists(t, path.Join(projectDir, DefaultDirNameStaging, DefaultFileNameTOC))
+	require.NoFileExists(t, path.Join(projectDir, DefaultDirNameStaging, "_Footer.md"))
+}
+
+func TestParseSource(t *testing.T) {
+	require.Equal(t, Source{Namespace: "faq", Location: "../faq"}, ParseSource("faq=../faq"))
+	require.Equal(t, Source{Location: "https://example.com/wiki?a=b"}, ParseSource("https://example.com/wiki?a=b"))
+	require.Equal(t, Source{Location: "docs"}, ParseSource("docs"))
+}
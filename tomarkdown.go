@@ -0,0 +1,650 @@
+package illuminated
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// inlineElements lists elements rendered as inline markdown rather than blocks.
+var inlineElements = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Br: true, atom.Code: true, atom.Del: true,
+	atom.Em: true, atom.I: true, atom.Img: true, atom.Input: true, atom.Kbd: true, atom.Mark: true,
+	atom.S: true, atom.Samp: true, atom.Small: true, atom.Span: true, atom.Strong: true,
+	atom.Sub: true, atom.Sup: true, atom.U: true, atom.Var: true,
+}
+
+// HTMLToMarkdown converts an HTML document, as generated from GitHub Flavored Markdown, back to markdown.
+// Links, images, tables, code blocks, task lists, alerts and footnotes are kept as markdown,
+// and elements markdown can't express are kept as raw HTML.
+func HTMLToMarkdown(doc *html.Node) (string, error) {
+	unwrapProtected(doc)
+	// provenance describes the translation, not the page, so markdown is left without it
+	removeProvenance(doc)
+	// headings with the IDs GitHub generates from their text need no anchors of their own
+	ids := &headingIDs{used: map[string]bool{}}
+	for _, h := range headings(doc) {
+		if id := ids.Generate([]byte(textContent(h)), ast.KindHeading); string(id) == attr(h, "id") {
+			removeAttr(h, "id")
+		}
+	}
+	root := findElement(doc, atom.Body)
+	if root == nil {
+		root = doc
+	}
+	blocks, err := markdownBlocks(root)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(blocks, "\n\n") + "\n", nil
+}
+
+// WriteMarkdown converts the HTML file at inputPath to markdown written to outputPath.
+func WriteMarkdown(inputPath, outputPath string) error {
+	doc, err := readHTML(inputPath)
+	if err != nil {
+		return err
+	}
+	md, err := HTMLToMarkdown(doc)
+	if err != nil {
+		return fmt.Errorf("convert %q to markdown: %w", inputPath, err)
+	}
+	err = os.WriteFile(outputPath, []byte(md), DefaultFilePermissions)
+	if err != nil {
+		return fmt.Errorf("write markdown file %q: %w", outputPath, err)
+	}
+	slog.Debug("markdown output generated", "input", inputPath, "output", outputPath)
+	return nil
+}
+
+// MarkdownOutputRenderer writes the pages of each language as markdown, in a directory per language
+// of the markdown directory of the output, with the file names of the source pages.
+// Links are kept as in the source, so pages can be pushed to a wiki per language.
+type MarkdownOutputRenderer struct{}
+
+// Render writes the markdown of docs.
+func (MarkdownOutputRenderer) Render(docs []Document, opts RenderOptions) ([]MissingLink, error) {
+	for _, doc := range docs {
+		dir := path.Join(opts.outputDir(), DefaultDirNameMarkdown, doc.Lang)
+		err := os.MkdirAll(dir, DefaultFilePermissions)
+		if err != nil {
+			return nil, fmt.Errorf("create directory %q: %w", dir, err)
+		}
+		for _, p := range doc.Pages {
+			outPath := path.Join(dir, p.Page.Name)
+			inputs := Digest(opts.State.Hash(p.Path))
+			if opts.fresh(outPath, inputs) {
+				slog.Debug("skipping unchanged markdown", "file", outPath)
+				continue
+			}
+			err = WriteMarkdown(p.Path, outPath)
+			if err != nil {
+				return nil, fmt.Errorf("write markdown for language %q: %w", doc.Lang, err)
+			}
+			err = opts.State.Record(outPath, inputs)
+			if err != nil {
+				return nil, fmt.Errorf("record %q in build state: %w", outPath, err)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// unwrapProtected replaces the elements ProtectPhrases marks phrases with by their content,
+// as they aren't part of the source.
+func unwrapProtected(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		unwrapProtected(c)
+		if c.Type == html.ElementNode && c.DataAtom == atom.Span && len(c.Attr) == 2 &&
+			attr(c, "class") == "notranslate" && attr(c, "translate") == "no" {
+			for c.FirstChild != nil {
+				child := c.FirstChild
+				c.RemoveChild(child)
+				n.InsertBefore(child, c)
+			}
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+// markdownBlocks returns the markdown blocks of the children of n.
+// Consecutive inline content is joined into one paragraph.
+func markdownBlocks(n *html.Node) ([]string, error) {
+	var blocks []string
+	var inline []*html.Node
+	flush := func() {
+		text := strings.TrimSpace(markdownInlines(inline))
+		if text != "" {
+			blocks = append(blocks, escapeLineStart(text))
+		}
+		inline = nil
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode || (c.Type == html.ElementNode && inlineElements[c.DataAtom]) {
+			inline = append(inline, c)
+			continue
+		}
+		flush()
+		if c.Type != html.ElementNode {
+			continue
+		}
+		block, err := markdownBlock(c)
+		if err != nil {
+			return nil, err
+		}
+		if block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	flush()
+	return blocks, nil
+}
+
+// markdownBlock returns the markdown of block element n.
+func markdownBlock(n *html.Node) (string, error) {
+	if level, ok := headingLevels[n.DataAtom]; ok {
+		text := strings.TrimSpace(markdownContent(n))
+		// GitHub generates anchors from the translated text, so anchors of the base language are kept
+		if id := attr(n, "id"); id != "" {
+			text = fmt.Sprintf(`<a name="%s"></a>%s`, html.EscapeString(id), text)
+		}
+		return strings.Repeat("#", level) + " " + text, nil
+	}
+	switch n.DataAtom {
+	case atom.Head, atom.Title, atom.Meta, atom.Link, atom.Script, atom.Style, atom.Template:
+		return "", nil
+	case atom.Html, atom.Body, atom.Main, atom.Article, atom.Section:
+		if len(n.Attr) == 0 || n.DataAtom == atom.Html || n.DataAtom == atom.Body {
+			blocks, err := markdownBlocks(n)
+			return strings.Join(blocks, "\n\n"), err
+		}
+	case atom.P:
+		if len(n.Attr) == 0 {
+			return escapeLineStart(strings.TrimSpace(markdownContent(n))), nil
+		}
+	case atom.Hr:
+		return "---", nil
+	case atom.Pre:
+		return markdownCodeBlock(n), nil
+	case atom.Blockquote:
+		blocks, err := markdownBlocks(n)
+		if err != nil {
+			return "", err
+		}
+		return prefixLines(strings.Join(blocks, "\n\n"), "> ", ">"), nil
+	case atom.Ul, atom.Ol:
+		return markdownList(n)
+	case atom.Table:
+		if md, ok := markdownTable(n); ok {
+			return md, nil
+		}
+	case atom.Div:
+		classes := strings.Fields(attr(n, "class"))
+		switch {
+		case len(classes) == 2 && classes[0] == "markdown-alert":
+			return markdownAlert(n, strings.TrimPrefix(classes[1], "markdown-alert-"))
+		case len(classes) == 1 && classes[0] == "footnotes":
+			return markdownFootnotes(n)
+		}
+	}
+	return renderRawHTML(n)
+}
+
+// markdownAlert returns the markdown of a GitHub alert of kind, such as "> [!NOTE]".
+func markdownAlert(n *html.Node, kind string) (string, error) {
+	var blocks []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && attr(c, "class") == "markdown-alert-title" {
+			continue
+		}
+		if c.Type != html.ElementNode {
+			continue
+		}
+		block, err := markdownBlock(c)
+		if err != nil {
+			return "", err
+		}
+		blocks = append(blocks, block)
+	}
+	body := "[!" + strings.ToUpper(kind) + "]"
+	if len(blocks) > 0 {
+		body += "\n" + strings.Join(blocks, "\n\n")
+	}
+	return prefixLines(body, "> ", ">"), nil
+}
+
+// markdownFootnotes returns footnote definitions for the footnotes section of a document.
+func markdownFootnotes(n *html.Node) (string, error) {
+	list := findElement(n, atom.Ol)
+	if list == nil {
+		return "", nil
+	}
+	var notes []string
+	for li := list.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+		removeBacklinks(li)
+		blocks, err := markdownBlocks(li)
+		if err != nil {
+			return "", err
+		}
+		label := strings.TrimPrefix(attr(li, "id"), "fn:")
+		notes = append(notes, fmt.Sprintf("[^%s]: %s", label, indentLines(strings.Join(blocks, "\n\n"), "    ")))
+	}
+	return strings.Join(notes, "\n"), nil
+}
+
+// removeBacklinks removes links from a footnote back to its references, and the space before them.
+func removeBacklinks(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && c.DataAtom == atom.A && attr(c, "class") == "footnote-backref" {
+			if prev := c.PrevSibling; prev != nil && prev.Type == html.TextNode {
+				prev.Data = strings.TrimRight(prev.Data, "\u00a0 ")
+			}
+			n.RemoveChild(c)
+		} else {
+			removeBacklinks(c)
+		}
+		c = next
+	}
+}
+
+// markdownList returns the markdown of an ordered or unordered list, including task lists.
+func markdownList(n *html.Node) (string, error) {
+	start := 1
+	if s, err := strconv.Atoi(attr(n, "start")); err == nil {
+		start = s
+	}
+	// lists are loose, with blank lines between items, when items contain paragraphs
+	loose := false
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type == html.ElementNode && findElement(li, atom.P) != nil {
+			loose = true
+		}
+	}
+	var items []string
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", start+len(items))
+		}
+		task := ""
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode && strings.TrimSpace(c.Data) == "" {
+				continue
+			}
+			if c.Type == html.ElementNode && c.DataAtom == atom.Input && attr(c, "type") == "checkbox" {
+				task = "[ ] "
+				if hasAttr(c, "checked") {
+					task = "[x] "
+				}
+				li.RemoveChild(c)
+			}
+			break
+		}
+		blocks, err := markdownBlocks(li)
+		if err != nil {
+			return "", err
+		}
+		sep := "\n"
+		if loose {
+			sep = "\n\n"
+		}
+		content := task + strings.Join(blocks, sep)
+		items = append(items, marker+indentLines(content, strings.Repeat(" ", len(marker))))
+	}
+	if loose {
+		return strings.Join(items, "\n\n"), nil
+	}
+	return strings.Join(items, "\n"), nil
+}
+
+// markdownCodeBlock returns a fenced code block for pre element n,
+// with the language of its code element.
+func markdownCodeBlock(n *html.Node) string {
+	lang := ""
+	if code := findElement(n, atom.Code); code != nil {
+		for _, class := range strings.Fields(attr(code, "class")) {
+			if l, ok := strings.CutPrefix(class, "language-"); ok {
+				lang = l
+			}
+		}
+	}
+	text := strings.TrimSuffix(textContent(n), "\n")
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + text + "\n" + fence
+}
+
+// markdownTable returns a GitHub table for table element n.
+// ok is false for tables markdown can't express, such as those with merged or block cells.
+func markdownTable(n *html.Node) (md string, ok bool) {
+	var rows [][]*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Tr {
+			var cells []*html.Node
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode && (c.DataAtom == atom.Th || c.DataAtom == atom.Td) {
+					cells = append(cells, c)
+				}
+			}
+			rows = append(rows, cells)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return "", false
+	}
+	for _, row := range rows {
+		for _, cell := range row {
+			if attr(cell, "colspan") != "" || attr(cell, "rowspan") != "" {
+				return "", false
+			}
+			for c := cell.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode && !inlineElements[c.DataAtom] {
+					return "", false
+				}
+			}
+		}
+	}
+	line := func(row []*html.Node) string {
+		var cells []string
+		for _, cell := range row {
+			text := strings.TrimSpace(markdownContent(cell))
+			text = strings.ReplaceAll(text, "\\\n", "<br>")
+			cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+		}
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+	var lines []string
+	lines = append(lines, line(rows[0]))
+	var align []string
+	for _, cell := range rows[0] {
+		style := strings.ReplaceAll(attr(cell, "style"), " ", "")
+		switch {
+		case strings.Contains(style, "text-align:left"):
+			align = append(align, ":---")
+		case strings.Contains(style, "text-align:right"):
+			align = append(align, "---:")
+		case strings.Contains(style, "text-align:center"):
+			align = append(align, ":---:")
+		default:
+			align = append(align, "---")
+		}
+	}
+	lines = append(lines, "| "+strings.Join(align, " | ")+" |")
+	for _, row := range rows[1:] {
+		lines = append(lines, line(row))
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// markdownContent returns the inline markdown of the children of n.
+func markdownContent(n *html.Node) string {
+	var children []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		children = append(children, c)
+	}
+	return markdownInlines(children)
+}
+
+// markdownInlines returns the inline markdown of nodes.
+func markdownInlines(nodes []*html.Node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(markdownInline(n))
+	}
+	return b.String()
+}
+
+// markdownInline returns the inline markdown of n.
+func markdownInline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeMarkdown(collapseSpace(n.Data))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	switch n.DataAtom {
+	case atom.Em, atom.I:
+		return wrapInline(markdownContent(n), "*")
+	case atom.Strong, atom.B:
+		return wrapInline(markdownContent(n), "**")
+	case atom.Del, atom.S:
+		return wrapInline(markdownContent(n), "~~")
+	case atom.Code:
+		return markdownCodeSpan(textContent(n))
+	case atom.Br:
+		return "\\\n"
+	case atom.Sup:
+		if a := findElement(n, atom.A); a != nil && attr(a, "class") == "footnote-ref" {
+			return "[^" + strings.TrimPrefix(attr(a, "href"), "#fn:") + "]"
+		}
+	case atom.A:
+		if onlyAttrs(n, "href", "title") && attr(n, "href") != "" {
+			href := attr(n, "href")
+			text := markdownContent(n)
+			if text == escapeMarkdown(href) && strings.Contains(href, "://") && attr(n, "title") == "" {
+				return "<" + href + ">"
+			}
+			return "[" + text + "](" + markdownDestination(href, attr(n, "title")) + ")"
+		}
+	case atom.Img:
+		if onlyAttrs(n, "src", "alt", "title") {
+			return "![" + escapeMarkdown(attr(n, "alt")) + "](" + markdownDestination(attr(n, "src"), attr(n, "title")) + ")"
+		}
+		md, _ := renderRawHTML(n)
+		return md
+	}
+	// other inline elements are kept as HTML, with markdown content
+	var b strings.Builder
+	b.WriteString("<" + n.Data)
+	for _, a := range n.Attr {
+		fmt.Fprintf(&b, ` %s="%s"`, a.Key, html.EscapeString(a.Val))
+	}
+	b.WriteString(">")
+	if n.FirstChild == nil && isVoid(n.DataAtom) {
+		return b.String()
+	}
+	b.WriteString(markdownContent(n))
+	b.WriteString("</" + n.Data + ">")
+	return b.String()
+}
+
+// markdownDestination returns the destination of a link or image, with an optional title.
+func markdownDestination(href, title string) string {
+	if strings.ContainsAny(href, " ()<>") {
+		href = "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(href) + ">"
+	}
+	if title != "" {
+		href += ` "` + strings.ReplaceAll(title, `"`, `\"`) + `"`
+	}
+	return href
+}
+
+// markdownCodeSpan returns text as a code span, with enough backticks to contain any in text.
+func markdownCodeSpan(text string) string {
+	ticks := "`"
+	for strings.Contains(text, ticks) {
+		ticks += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		return ticks + " " + text + " " + ticks
+	}
+	return ticks + text + ticks
+}
+
+// wrapInline wraps text with delimiter, keeping surrounding spaces outside of it, as markdown requires.
+func wrapInline(text, delimiter string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	start := text[:strings.Index(text, trimmed)]
+	end := text[len(start)+len(trimmed):]
+	return start + delimiter + trimmed + delimiter + end
+}
+
+// escapeMarkdown escapes characters of text which would otherwise be read as markdown.
+func escapeMarkdown(text string) string {
+	var b strings.Builder
+	for i, r := range text {
+		switch r {
+		case '\\', '`', '*', '[', ']', '<', '~':
+			b.WriteRune('\\')
+		case '_':
+			// underscores within words don't emphasize
+			before, _ := utf8.DecodeLastRuneInString(text[:i])
+			after, _ := utf8.DecodeRuneInString(text[i+1:])
+			if !isWordRune(before) || !isWordRune(after) {
+				b.WriteRune('\\')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// escapeLineStart escapes characters at the start of a paragraph which would start another block.
+func escapeLineStart(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		switch {
+		case strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, ">"), strings.HasPrefix(trimmed, "+"), strings.HasPrefix(trimmed, "="),
+			strings.HasPrefix(trimmed, "- "), trimmed == "-", strings.HasPrefix(trimmed, "---"):
+			lines[i] = "\\" + trimmed
+		default:
+			digits := strings.TrimLeftFunc(trimmed, unicode.IsDigit)
+			if len(digits) < len(trimmed) && (strings.HasPrefix(digits, ". ") || strings.HasPrefix(digits, ") ")) {
+				n := len(trimmed) - len(digits)
+				lines[i] = trimmed[:n] + "\\" + digits
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isWordRune reports whether r is part of a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// collapseSpace replaces runs of white space in text with one space, as HTML renders them.
+func collapseSpace(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range text {
+		if r == ' ' || r == '\n' || r == '\t' || r == '\r' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteRune(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteRune(' ')
+	}
+	return b.String()
+}
+
+// prefixLines prefixes each line of text, using empty for empty lines.
+func prefixLines(text, prefix, empty string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = empty
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indentLines indents lines of text after the first, leaving empty lines empty.
+func indentLines(text, indent string) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// onlyAttrs reports whether element n has no attributes other than keys.
+func onlyAttrs(n *html.Node, keys ...string) bool {
+	for _, a := range n.Attr {
+		found := false
+		for _, key := range keys {
+			found = found || a.Key == key
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// removeAttr removes attribute key of n.
+func removeAttr(n *html.Node, key string) {
+	n.Attr = slices.DeleteFunc(n.Attr, func(a html.Attribute) bool { return a.Key == key })
+}
+
+// hasAttr reports whether element n has attribute key.
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isVoid reports whether elements of a have no content or end tag.
+func isVoid(a atom.Atom) bool {
+	switch a {
+	case atom.Area, atom.Br, atom.Col, atom.Embed, atom.Hr, atom.Img, atom.Input,
+		atom.Link, atom.Meta, atom.Source, atom.Track, atom.Wbr:
+		return true
+	}
+	return false
+}
+
+// renderRawHTML returns n as HTML, kept as is in markdown.
+func renderRawHTML(n *html.Node) (string, error) {
+	var b strings.Builder
+	err := html.Render(&b, n)
+	if err != nil {
+		return "", fmt.Errorf("render HTML: %w", err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,101 @@
+package illuminated
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+// toMarkdown converts an HTML fragment to markdown.
+func toMarkdown(t *testing.T, fragment string) string {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(fragment))
+	require.NoError(t, err)
+	md, err := HTMLToMarkdown(doc)
+	require.NoError(t, err)
+	return md
+}
+
+// normalizeHTML returns an HTML fragment rendered by the HTML package with white space collapsed,
+// for comparing documents regardless of white space and void element syntax.
+func normalizeHTML(t *testing.T, fragment string) string {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(fragment))
+	require.NoError(t, err)
+	var b strings.Builder
+	require.NoError(t, html.Render(&b, doc))
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// TestHTMLToMarkdownRoundTrip converts the HTML of each golden file back to markdown,
+// which must render the same HTML again, other than white space.
+func TestHTMLToMarkdownRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "markdown", "*.md"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			require.NoError(t, err)
+			want, err := DefaultMarkdownRenderer.Render(wikiLinksToMarkdown(src))
+			require.NoError(t, err)
+			md := toMarkdown(t, string(want))
+			got, err := DefaultMarkdownRenderer.Render([]byte(md))
+			require.NoError(t, err)
+			require.Equal(t, normalizeHTML(t, string(want)), normalizeHTML(t, string(got)), md)
+		})
+	}
+}
+
+func TestHTMLToMarkdown(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			"code block",
+			"<pre><code class=\"language-go\">fmt.Println(\"```\")\n</code></pre>",
+			"````go\nfmt.Println(\"```\")\n````\n",
+		},
+		{
+			"translated heading keeps anchor",
+			`<h2 id="install">安装</h2><p><a href="Usage#run" title="Run it">运行</a></p>`,
+			"## <a name=\"install\"></a>安装\n\n[运行](Usage#run \"Run it\")\n",
+		},
+		{
+			"protected phrases",
+			`<p>使用 <span class="notranslate" translate="no">Lantern</span> 应用</p>`,
+			"使用 Lantern 应用\n",
+		},
+		{
+			"provenance",
+			`<p data-translator="google" data-review="machine" data-source-hash="0123456789abcdef">文本</p>`,
+			"文本\n",
+		},
+		{
+			"loose ordered list",
+			`<ol start="3"><li><p>One</p><pre><code>a
+</code></pre></li><li><p>Two</p></li></ol>`,
+			"3. One\n\n   ```\n   a\n   ```\n\n4. Two\n",
+		},
+		{
+			"escaped text",
+			`<p>1. *not* [a link] snake_case _x_ <b> bold </b></p>`,
+			"1\\. \\*not\\* \\[a link\\] snake_case \\_x\\_  **bold**\n",
+		},
+		{
+			"image with attributes",
+			`<p><img src="a b.png" alt="A"> <img src="c.png" width="10"></p>`,
+			"![A](<a b.png>) <img src=\"c.png\" width=\"10\"/>\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, toMarkdown(t, tc.html))
+		})
+	}
+}
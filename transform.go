@@ -0,0 +1,176 @@
+package illuminated
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Transform modifies an HTML document in place.
+type Transform func(doc *html.Node) error
+
+// ApplyTransforms applies transforms to doc in order, stopping at the first error.
+func ApplyTransforms(doc *html.Node, transforms ...Transform) error {
+	for _, transform := range transforms {
+		err := transform(doc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TransformFile applies transforms to the HTML file at src, writing the result to dst.
+// The file at src is left unchanged unless dst is the same file.
+func TransformFile(src, dst string, transforms ...Transform) error {
+	doc, err := readHTML(src)
+	if err != nil {
+		return err
+	}
+	err = ApplyTransforms(doc, transforms...)
+	if err != nil {
+		return fmt.Errorf("transform %q: %w", src, err)
+	}
+	return writeHTML(dst, doc)
+}
+
+// PDFTransforms are the transforms preparing HTML for PDF output in direction dir, ltr or rtl.
+func PDFTransforms(dir string) []Transform {
+	return []Transform{
+		SetDirection(dir),
+		RemoveTOC,
+		PageBreaks,
+		CenterImages,
+	}
+}
+
+// OfficeTransforms are the transforms preparing HTML for DOCX and ODT output in direction dir, ltr or rtl.
+func OfficeTransforms(dir string) []Transform {
+	return []Transform{
+		SetDirection(dir),
+		RemoveTOC,
+		PageBreaks,
+	}
+}
+
+// SetDirection returns a transform setting the text direction of the document to dir.
+func SetDirection(dir string) Transform {
+	return func(doc *html.Node) error {
+		root := findElement(doc, atom.Html)
+		if root == nil {
+			return fmt.Errorf("no html element")
+		}
+		setAttr(root, "dir", dir)
+		return nil
+	}
+}
+
+// declareLanguage returns a transform declaring the language of the document as lang,
+// with the text direction of its script.
+func declareLanguage(lang string) Transform {
+	return func(doc *html.Node) error {
+		root := findElement(doc, atom.Html)
+		if root == nil {
+			return nil
+		}
+		info := Language(lang)
+		setAttr(root, "lang", info.Tag)
+		setAttr(root, "dir", info.Direction)
+		return nil
+	}
+}
+
+// RemoveTOC removes tables of contents generated for HTML, as PDFs have their own.
+func RemoveTOC(doc *html.Node) error {
+	removeElements(doc, func(n *html.Node) bool {
+		return n.DataAtom == atom.Nav && attr(n, "role") == "doc-toc"
+	})
+	return nil
+}
+
+// PageBreaks marks elements styled to break the page before or after them
+// with page-break elements, which PDF output breaks the page at.
+func PageBreaks(doc *html.Node) error {
+	walkElements(doc, func(n *html.Node) {
+		style := strings.ReplaceAll(strings.ToLower(attr(n, "style")), " ", "")
+		if n.Parent == nil || style == "" {
+			return
+		}
+		if strings.Contains(style, "page-break-before:always") || strings.Contains(style, "break-before:page") {
+			n.Parent.InsertBefore(pageBreak(), n)
+		}
+		if strings.Contains(style, "page-break-after:always") || strings.Contains(style, "break-after:page") {
+			n.Parent.InsertBefore(pageBreak(), n.NextSibling)
+		}
+	})
+	return nil
+}
+
+// pageBreak returns an element breaking the page.
+func pageBreak() *html.Node {
+	return &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+		Attr:     []html.Attribute{{Key: "class", Val: "page-break"}},
+	}
+}
+
+// CenterImages centers images on their own lines, removing paragraphs containing only an image,
+// which misplace images in right to left languages.
+func CenterImages(doc *html.Node) error {
+	walkElements(doc, func(n *html.Node) {
+		if n.DataAtom != atom.Img {
+			return
+		}
+		style := strings.TrimSuffix(strings.TrimSpace(attr(n, "style")), ";")
+		if style != "" {
+			style += "; "
+		}
+		setAttr(n, "style", style+"display: block; margin-left: auto; margin-right: auto;")
+
+		p := n.Parent
+		if p == nil || p.DataAtom != atom.P || p.Parent == nil || len(p.Attr) > 0 {
+			return
+		}
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			if c != n && !(c.Type == html.TextNode && strings.TrimSpace(c.Data) == "") {
+				return
+			}
+		}
+		p.RemoveChild(n)
+		p.Parent.InsertBefore(n, p)
+		p.Parent.RemoveChild(p)
+	})
+	return nil
+}
+
+// walkElements calls visit for each element of doc, in document order.
+// Elements are found before any are visited, so visit may move or remove them.
+func walkElements(doc *html.Node, visit func(*html.Node)) {
+	var elements []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			elements = append(elements, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	for _, n := range elements {
+		visit(n)
+	}
+}
+
+// removeElements removes the elements of doc for which match is true.
+func removeElements(doc *html.Node, match func(*html.Node) bool) {
+	walkElements(doc, func(n *html.Node) {
+		if n.Parent != nil && match(n) {
+			n.Parent.RemoveChild(n)
+		}
+	})
+}
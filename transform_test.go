@@ -0,0 +1,70 @@
+package illuminated
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+// transformHTML applies transforms to an HTML document, returning its body.
+func transformHTML(t *testing.T, doc string, transforms ...Transform) string {
+	t.Helper()
+	root, err := html.Parse(strings.NewReader(doc))
+	require.NoError(t, err)
+	require.NoError(t, ApplyTransforms(root, transforms...))
+	var b strings.Builder
+	require.NoError(t, html.Render(&b, root))
+	return b.String()
+}
+
+func TestCenterImages(t *testing.T) {
+	got := transformHTML(t,
+		`<p><img src="a.png" alt="A"></p><p>Text <img src="b.png" style="width: 10px;"></p><p align="center"><img src="c.png"></p>`,
+		CenterImages,
+	)
+	const center = "display: block; margin-left: auto; margin-right: auto;"
+	// paragraphs of only an image are removed, including those with attributes on the image
+	require.Contains(t, got, `<body><img src="a.png" alt="A" style="`+center+`"/><p>Text`)
+	require.Contains(t, got, `<img src="b.png" style="width: 10px; `+center+`"/></p>`)
+	require.Contains(t, got, `<p align="center"><img src="c.png" style="`+center+`"/></p>`)
+}
+
+func TestPageBreaks(t *testing.T) {
+	got := transformHTML(t,
+		`<h2 style="page-break-before: always">A</h2><p style="break-after:page">B</p><p>C</p>`,
+		PageBreaks,
+	)
+	require.Contains(t, got,
+		`<div class="page-break"></div><h2 style="page-break-before: always">A</h2>`+
+			`<p style="break-after:page">B</p><div class="page-break"></div><p>C</p>`)
+}
+
+func TestPDFTransforms(t *testing.T) {
+	got := transformHTML(t,
+		`<html lang="fa"><body><nav class="toc" role="doc-toc"><ul><li>A</li></ul></nav><h1>A</h1></body></html>`,
+		PDFTransforms("rtl")...,
+	)
+	require.Equal(t, `<html lang="fa" dir="rtl"><head></head><body><h1>A</h1></body></html>`, got)
+}
+
+func TestTransformFile(t *testing.T) {
+	dir := t.TempDir()
+	src := path.Join(dir, "fa.Install.html")
+	const doc = `<html lang="fa"><head></head><body><p><img src="a.png"></p></body></html>`
+	require.NoError(t, os.WriteFile(src, []byte(doc), 0o644))
+	dst := path.Join(dir, "pdf.html")
+	require.NoError(t, TransformFile(src, dst, PDFTransforms("rtl")...))
+
+	// the source is left unchanged
+	b, err := os.ReadFile(src)
+	require.NoError(t, err)
+	require.Equal(t, doc, string(b))
+	b, err = os.ReadFile(dst)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `<html lang="fa" dir="rtl">`)
+	require.Contains(t, string(b), `<body><img src="a.png" style=`)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"slices"
 	"strings"
 
 	"golang.org/x/net/html"
@@ -27,6 +28,9 @@ func (m *mockTranslator) Translate(ctx context.Context, targetLang string, texts
 
 		var substituteText func(*html.Node)
 		substituteText = func(n *html.Node) {
+			if n.Type == html.ElementNode && noTranslate(n) {
+				return
+			}
 			if n.Type == html.TextNode {
 				translatedText, ok := loremIpsum[targetLang]
 				if ok {
@@ -50,6 +54,20 @@ func (m *mockTranslator) Translate(ctx context.Context, targetLang string, texts
 
 func (m *mockTranslator) Close(ctx context.Context) {}
 
+// noTranslate reports whether element n is marked to be left untranslated,
+// as respected by translation services.
+func noTranslate(n *html.Node) bool {
+	for _, a := range n.Attr {
+		if a.Key == "translate" && a.Val == "no" {
+			return true
+		}
+		if a.Key == "class" && slices.Contains(strings.Fields(a.Val), "notranslate") {
+			return true
+		}
+	}
+	return false
+}
+
 var loremIpsum = map[string]string{
 	"en": "The quick brown fox jumps over the lazy dog.",
 	"es": "El veloz zorro marrón salta sobre el perro perezoso.",